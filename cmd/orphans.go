@@ -0,0 +1,521 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ludviglundgren/qbittorrent-cli/internal/config"
+	"github.com/ludviglundgren/qbittorrent-cli/internal/fs"
+	"github.com/ludviglundgren/qbittorrent-cli/internal/pool"
+	"github.com/ludviglundgren/qbittorrent-cli/pkg/utils"
+
+	"github.com/autobrr/go-qbittorrent"
+	"github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	OrphanActionReport = "report"
+	OrphanActionMove   = "move"
+	OrphanActionDelete = "delete"
+
+	// DefaultOrphansConcurrency is the default number of torrents to get the files of at the same time
+	DefaultOrphansConcurrency = 5
+
+	// incompleteFileExt is appended by qBittorrent to incomplete files when enabled in the preferences
+	incompleteFileExt = ".!qB"
+)
+
+// RunOrphans cmd for orphaned file actions
+func RunOrphans() *cobra.Command {
+	var command = &cobra.Command{
+		Use:   "orphans",
+		Short: "Orphans subcommand",
+		Long:  `Find files in the save paths that are not part of any torrent`,
+	}
+
+	command.AddCommand(RunOrphansScan())
+
+	return command
+}
+
+// RunOrphansScan cmd to find, move or delete orphaned files
+func RunOrphansScan() *cobra.Command {
+	var (
+		dry         bool
+		action      string
+		recycleDir  string
+		include     []string
+		exclude     []string
+		minAge      time.Duration
+		output      string
+		concurrency int
+	)
+
+	var command = &cobra.Command{
+		Use:   "scan",
+		Short: "Find files not referenced by any torrent",
+		Long: `Walk the default save path and the save paths of all categories and find files and directories
+that are not part of any torrent, like data left behind by torrents removed without their files.
+
+Directories with only orphaned files are reported as one directory. Files changed within --min-age are never
+orphaned so data being written is left alone. Globs in --include and --exclude are matched against the path
+relative to the save path: a glob without / matches the file name, and a glob ending with / matches a directory.
+
+The save paths must be readable from where qbt runs, with the same paths as in qBittorrent.
+Use --action move to move orphans to --recycle-dir with the same structure, or --action delete to delete them.
+--recycle-dir defaults to dir from [recycle] in the config.`,
+		Example: `  qbt orphans scan
+  qbt orphans scan --exclude "*.nfo" --min-age 72h --output json
+  qbt orphans scan --action move --recycle-dir /mnt/recycle
+  qbt orphans scan --action delete --include "*.mkv" --dry-run`,
+	}
+
+	command.Flags().BoolVar(&dry, "dry-run", false, "Run without moving or deleting anything")
+	command.Flags().StringVar(&action, "action", OrphanActionReport, "Action for orphans: report, move or delete")
+	command.Flags().StringVar(&recycleDir, "recycle-dir", "", "Directory to move orphans to with --action move. Defaults to dir from [recycle] in the config")
+	command.Flags().StringArrayVar(&include, "include", []string{}, "Only orphan files matching glob. Can be repeated")
+	command.Flags().StringArrayVar(&exclude, "exclude", []string{}, "Never orphan files matching glob. Can be repeated")
+	command.Flags().DurationVar(&minAge, "min-age", 24*time.Hour, "Only orphan files not changed within this duration")
+	command.Flags().StringVar(&output, "output", "", "Print orphans as [formatted text (default), json]")
+	command.Flags().IntVar(&concurrency, "concurrency", DefaultOrphansConcurrency, "Number of torrents to get the files of at the same time")
+
+	command.RunE = func(cmd *cobra.Command, args []string) error {
+		switch action {
+		case OrphanActionReport, OrphanActionDelete, OrphanActionMove:
+		default:
+			return errors.Errorf("invalid --action: %s. Possible values: report, move, delete", action)
+		}
+
+		if output != "" && output != "json" {
+			return errors.Errorf("invalid --output: %s. Possible values: json", output)
+		}
+
+		if minAge < 0 {
+			return errors.Errorf("invalid --min-age: %s", minAge)
+		}
+
+		if concurrency < 1 {
+			return errors.Errorf("invalid --concurrency: %d", concurrency)
+		}
+
+		config.InitConfig()
+
+		if action == OrphanActionMove {
+			if recycleDir == "" {
+				recycleDir = config.Recycle.Dir
+			}
+
+			if recycleDir == "" {
+				return errors.New("--recycle-dir or dir in [recycle] in the config is required with --action move")
+			}
+
+			dir, err := utils.ExpandTilde(recycleDir)
+			if err != nil {
+				return errors.Wrapf(err, "could not expand recycle dir: %s", recycleDir)
+			}
+
+			recycleDir = dir
+		}
+
+		qbtSettings := qbittorrent.Config{
+			Host:      config.Qbit.Addr,
+			APIKey:    config.Qbit.APIKey,
+			Username:  config.Qbit.Login,
+			Password:  config.Qbit.Password,
+			BasicUser: config.Qbit.BasicUser,
+			BasicPass: config.Qbit.BasicPass,
+		}
+
+		qb := qbittorrent.NewClient(qbtSettings)
+
+		ctx := cmd.Context()
+
+		if err := qb.LoginCtx(ctx); err != nil {
+			return errors.Wrap(err, "could not login to qbit")
+		}
+
+		prefs, err := qb.GetAppPreferencesCtx(ctx)
+		if err != nil {
+			return errors.Wrap(err, "could not get preferences")
+		}
+
+		categories, err := qb.GetCategoriesCtx(ctx)
+		if err != nil {
+			return errors.Wrap(err, "could not get categories")
+		}
+
+		torrents, err := qb.GetTorrentsCtx(ctx, qbittorrent.TorrentFilterOptions{})
+		if err != nil {
+			return errors.Wrap(err, "could not get torrents")
+		}
+
+		if len(torrents) == 0 && action != OrphanActionReport {
+			return errors.Errorf("found no torrents, refusing to %s every file in the save paths", action)
+		}
+
+		torrentFiles, err := fetchTorrentFiles(ctx, qb, torrents, concurrency)
+		if err != nil {
+			return err
+		}
+
+		referenced := map[string]struct{}{}
+
+		for i, t := range torrents {
+			files := torrentFiles[i]
+			if files == nil {
+				continue
+			}
+
+			for _, f := range *files {
+				addReferencedFile(referenced, t.SavePath, f.Name)
+
+				if t.DownloadPath != "" {
+					addReferencedFile(referenced, t.DownloadPath, f.Name)
+				}
+			}
+		}
+
+		scanner := &orphanScanner{
+			referenced: referenced,
+			include:    include,
+			exclude:    exclude,
+			before:     time.Now().Add(-minAge),
+		}
+
+		if recycleDir != "" {
+			scanner.skipDir = filepath.Clean(recycleDir)
+		}
+
+		var orphans []orphan
+
+		for _, root := range orphanRoots(prefs.SavePath, categories) {
+			if _, err := os.Stat(root); err != nil {
+				if os.IsNotExist(err) {
+					log.Printf("save path %s not found, skipping\n", root)
+					continue
+				}
+
+				return errors.Wrapf(err, "could not read save path: %s", root)
+			}
+
+			found, err := scanner.scan(root)
+			if err != nil {
+				return errors.Wrapf(err, "could not scan save path: %s", root)
+			}
+
+			orphans = append(orphans, found...)
+		}
+
+		var total int64
+		for _, o := range orphans {
+			total += o.Size
+		}
+
+		switch output {
+		case "json":
+			if orphans == nil {
+				orphans = []orphan{}
+			}
+
+			res, err := json.Marshal(orphans)
+			if err != nil {
+				return errors.Wrap(err, "could not marshal orphans to json")
+			}
+
+			fmt.Println(string(res))
+
+		default:
+			for _, o := range orphans {
+				kind := "file"
+				if o.Dir {
+					kind = "dir"
+				}
+
+				fmt.Printf("%s\t%s\t%s\n", kind, humanize.Bytes(uint64(o.Size)), o.Path)
+			}
+		}
+
+		log.Printf("found (%d) orphan(s) with %s\n", len(orphans), humanize.Bytes(uint64(total)))
+
+		if action == OrphanActionReport || len(orphans) == 0 {
+			return nil
+		}
+
+		failed := 0
+
+		for _, o := range orphans {
+			switch action {
+			case OrphanActionMove:
+				dst := filepath.Join(recycleDir, strings.TrimPrefix(o.Path, filepath.VolumeName(o.Path)))
+
+				if dry {
+					log.Printf("dry-run: move %s to %s\n", o.Path, dst)
+					continue
+				}
+
+				if err := fs.Move(o.Path, dst); err != nil {
+					log.Printf("could not move %s: %q\n", o.Path, err)
+					failed++
+					continue
+				}
+
+				log.Printf("moved %s to %s\n", o.Path, dst)
+
+			case OrphanActionDelete:
+				if dry {
+					log.Printf("dry-run: delete %s\n", o.Path)
+					continue
+				}
+
+				if err := os.RemoveAll(o.Path); err != nil {
+					log.Printf("could not delete %s: %q\n", o.Path, err)
+					failed++
+					continue
+				}
+
+				log.Printf("deleted %s\n", o.Path)
+			}
+		}
+
+		if failed > 0 {
+			cmd.SilenceUsage = true
+			return errors.Errorf("could not %s %d orphan(s)", action, failed)
+		}
+
+		return nil
+	}
+
+	return command
+}
+
+// torrentFilesClient is the part of the qBittorrent client used to get the files of torrents
+type torrentFilesClient interface {
+	GetFilesInformationCtx(ctx context.Context, hash string) (*qbittorrent.TorrentFiles, error)
+}
+
+// fetchTorrentFiles gets the files of the torrents with at most concurrency requests at a time
+// and returns them in the order of torrents. It stops at the first error.
+func fetchTorrentFiles(ctx context.Context, client torrentFilesClient, torrents []qbittorrent.Torrent, concurrency int) ([]*qbittorrent.TorrentFiles, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	files := make([]*qbittorrent.TorrentFiles, len(torrents))
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+	)
+
+	err := pool.Run(ctx, len(torrents), pool.Options{Concurrency: concurrency}, func(ctx context.Context, i int) {
+		f, err := client.GetFilesInformationCtx(ctx, torrents[i].Hash)
+		if err == nil {
+			files[i] = f
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if firstErr == nil {
+			firstErr = errors.Wrapf(err, "could not get files of torrent: %s", torrents[i].Hash)
+			cancel()
+		}
+	})
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// orphan is a file or directory in a save path that is not part of any torrent
+type orphan struct {
+	Path    string    `json:"path"`
+	Dir     bool      `json:"dir"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// addReferencedFile adds the torrent file in the save path, and its incomplete name, to referenced
+func addReferencedFile(referenced map[string]struct{}, savePath, name string) {
+	p := filepath.Join(savePath, filepath.FromSlash(name))
+
+	referenced[p] = struct{}{}
+	referenced[p+incompleteFileExt] = struct{}{}
+}
+
+// orphanRoots returns the save paths to scan. Categories without a save path use a subfolder of the default save path,
+// and relative save paths are relative to it. Save paths inside another save path are scanned with it.
+func orphanRoots(defaultSavePath string, categories map[string]qbittorrent.Category) []string {
+	var paths []string
+
+	if defaultSavePath != "" {
+		paths = append(paths, filepath.Clean(defaultSavePath))
+	}
+
+	for name, c := range categories {
+		p := c.SavePath
+		if p == "" {
+			p = name
+		}
+
+		if !filepath.IsAbs(p) {
+			if defaultSavePath == "" {
+				continue
+			}
+
+			p = filepath.Join(defaultSavePath, p)
+		}
+
+		paths = append(paths, filepath.Clean(p))
+	}
+
+	sort.Strings(paths)
+
+	var roots []string
+
+	for _, p := range paths {
+		if len(roots) > 0 {
+			last := roots[len(roots)-1]
+			if p == last || strings.HasPrefix(p, strings.TrimSuffix(last, string(filepath.Separator))+string(filepath.Separator)) {
+				continue
+			}
+		}
+
+		roots = append(roots, p)
+	}
+
+	return roots
+}
+
+// orphanScanner finds files in a save path that are not referenced by a torrent
+type orphanScanner struct {
+	// referenced holds the full paths of all torrent files
+	referenced map[string]struct{}
+	include    []string
+	exclude    []string
+	// before is the latest modification time of an orphan
+	before time.Time
+	// skipDir is never scanned, like the recycle dir
+	skipDir string
+}
+
+// scan returns the orphans in root. root itself is never an orphan.
+func (s *orphanScanner) scan(root string) ([]orphan, error) {
+	orphans, _, _, err := s.scanDir(root, root)
+	return orphans, err
+}
+
+// scanDir returns the orphans in dir with their total size, and whether everything in dir is orphaned
+func (s *orphanScanner) scanDir(root, dir string) ([]orphan, int64, bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	var (
+		orphans []orphan
+		size    int64
+	)
+
+	all := true
+
+	for _, entry := range entries {
+		p := filepath.Join(dir, entry.Name())
+
+		if p == s.skipDir {
+			all = false
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return nil, 0, false, err
+		}
+
+		if entry.IsDir() {
+			found, dirSize, dirAll, err := s.scanDir(root, p)
+			if err != nil {
+				return nil, 0, false, err
+			}
+
+			// empty directories are only orphaned without include globs
+			if dirAll && (len(found) > 0 || len(s.include) == 0) && !info.ModTime().After(s.before) {
+				orphans = append(orphans, orphan{Path: p, Dir: true, Size: dirSize, ModTime: info.ModTime()})
+				size += dirSize
+				continue
+			}
+
+			orphans = append(orphans, found...)
+			for _, o := range found {
+				size += o.Size
+			}
+
+			all = false
+			continue
+		}
+
+		if !s.isOrphan(root, p, info) {
+			all = false
+			continue
+		}
+
+		orphans = append(orphans, orphan{Path: p, Size: info.Size(), ModTime: info.ModTime()})
+		size += info.Size()
+	}
+
+	return orphans, size, all, nil
+}
+
+// isOrphan reports whether the file is not referenced, old enough and matches the include and exclude globs
+func (s *orphanScanner) isOrphan(root, p string, info os.FileInfo) bool {
+	if _, ok := s.referenced[p]; ok {
+		return false
+	}
+
+	if info.ModTime().After(s.before) {
+		return false
+	}
+
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		return false
+	}
+
+	for _, pattern := range s.exclude {
+		if matchFilePattern(pattern, rel) {
+			return false
+		}
+	}
+
+	if len(s.include) == 0 {
+		return true
+	}
+
+	for _, pattern := range s.include {
+		if matchFilePattern(pattern, rel) {
+			return true
+		}
+	}
+
+	return false
+}
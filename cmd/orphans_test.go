@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/autobrr/go-qbittorrent"
+)
+
+func Test_orphanRoots(t *testing.T) {
+	categories := map[string]qbittorrent.Category{
+		"movies":  {Name: "movies", SavePath: "/mnt/d2/movies"},
+		"tv":      {Name: "tv", SavePath: ""},
+		"music":   {Name: "music", SavePath: "audio"},
+		"archive": {Name: "archive", SavePath: "/mnt/d2/movies/archive"},
+		"other":   {Name: "other", SavePath: "/mnt/d20/"},
+	}
+
+	got := orphanRoots("/mnt/d1", categories)
+	want := []string{"/mnt/d1", "/mnt/d2/movies", "/mnt/d20"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("orphanRoots() = %v, want %v", got, want)
+	}
+}
+
+func Test_orphanScanner_scan(t *testing.T) {
+	old := time.Now().Add(-48 * time.Hour)
+
+	// files are relative to the root, dirs end with /
+	tree := []string{
+		"Movie.2020/Movie.2020.mkv",
+		"Movie.2020/Movie.2020.nfo",
+		"Movie.2020/extra.txt",
+		"Removed/Removed.mkv",
+		"Removed/Sample/sample.mkv",
+		"Downloading.mkv.!qB",
+		"leftover.nfo",
+		"Empty/",
+		"recycle/old.mkv",
+	}
+
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		recent  []string
+		want    []string
+	}{
+		{
+			name: "orphaned files and dirs",
+			want: []string{"Empty/", "Movie.2020/extra.txt", "Removed/", "leftover.nfo"},
+		},
+		{
+			name:    "exclude",
+			exclude: []string{"*.nfo", "Sample/"},
+			want:    []string{"Empty/", "Movie.2020/extra.txt", "Removed/Removed.mkv"},
+		},
+		{
+			name:    "include",
+			include: []string{"*.mkv"},
+			want:    []string{"Removed/"},
+		},
+		{
+			name:   "recent files",
+			recent: []string{"Removed/Sample/sample.mkv", "leftover.nfo"},
+			want:   []string{"Empty/", "Movie.2020/extra.txt", "Removed/Removed.mkv"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+
+			recent := map[string]bool{}
+			for _, p := range tt.recent {
+				recent[p] = true
+			}
+
+			// directories are aged after their files are created
+			var dirs []string
+
+			for _, p := range tree {
+				full := filepath.Join(root, filepath.FromSlash(p))
+
+				if p[len(p)-1] == '/' {
+					if err := os.MkdirAll(full, 0755); err != nil {
+						t.Fatal(err)
+					}
+					dirs = append(dirs, full)
+					continue
+				}
+
+				if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(full, []byte("data"), 0644); err != nil {
+					t.Fatal(err)
+				}
+
+				if !recent[p] {
+					if err := os.Chtimes(full, old, old); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				for dir := filepath.Dir(full); dir != root; dir = filepath.Dir(dir) {
+					dirs = append(dirs, dir)
+				}
+			}
+
+			for _, dir := range dirs {
+				if err := os.Chtimes(dir, old, old); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			referenced := map[string]struct{}{}
+			addReferencedFile(referenced, root, "Movie.2020/Movie.2020.mkv")
+			addReferencedFile(referenced, root, "Movie.2020/Movie.2020.nfo")
+			addReferencedFile(referenced, root, "Downloading.mkv")
+
+			s := &orphanScanner{
+				referenced: referenced,
+				include:    tt.include,
+				exclude:    tt.exclude,
+				before:     time.Now().Add(-24 * time.Hour),
+				skipDir:    filepath.Join(root, "recycle"),
+			}
+
+			orphans, err := s.scan(root)
+			if err != nil {
+				t.Fatalf("scan() error = %v", err)
+			}
+
+			var got []string
+			for _, o := range orphans {
+				rel, _ := filepath.Rel(root, o.Path)
+				rel = filepath.ToSlash(rel)
+				if o.Dir {
+					rel += "/"
+				}
+				got = append(got, rel)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("scan() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type fakeFilesClient struct {
+	active    atomic.Int32
+	maxActive atomic.Int32
+	fail      string
+}
+
+func (c *fakeFilesClient) GetFilesInformationCtx(ctx context.Context, hash string) (*qbittorrent.TorrentFiles, error) {
+	n := c.active.Add(1)
+	defer c.active.Add(-1)
+
+	for {
+		m := c.maxActive.Load()
+		if n <= m || c.maxActive.CompareAndSwap(m, n) {
+			break
+		}
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	if hash == c.fail {
+		return nil, errors.New("failed")
+	}
+
+	return &qbittorrent.TorrentFiles{{Name: hash + ".mkv"}}, nil
+}
+
+func Test_fetchTorrentFiles(t *testing.T) {
+	torrents := make([]qbittorrent.Torrent, 20)
+	for i := range torrents {
+		torrents[i] = qbittorrent.Torrent{Hash: strconv.Itoa(i)}
+	}
+
+	client := &fakeFilesClient{}
+
+	files, err := fetchTorrentFiles(context.Background(), client, torrents, 4)
+	if err != nil {
+		t.Fatalf("fetchTorrentFiles() error = %v", err)
+	}
+
+	if got := client.maxActive.Load(); got > 4 {
+		t.Errorf("fetchTorrentFiles() max concurrent requests = %d, want <= 4", got)
+	}
+
+	for i, f := range files {
+		if want := strconv.Itoa(i) + ".mkv"; f == nil || (*f)[0].Name != want {
+			t.Errorf("fetchTorrentFiles() files %d = %v, want %s", i, f, want)
+		}
+	}
+
+	if _, err := fetchTorrentFiles(context.Background(), &fakeFilesClient{fail: "3"}, torrents, 4); err == nil {
+		t.Errorf("fetchTorrentFiles() expected error for torrent 3")
+	}
+}
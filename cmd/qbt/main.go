@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"log"
 	"os"
 
@@ -19,6 +20,11 @@ func main() {
 	rootCmd := cmd.NewRootCmd(version, commit, date)
 
 	if err := rootCmd.Execute(); err != nil {
+		var exitErr *cmd.ExitCodeError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
+
 		os.Exit(1)
 	}
 }
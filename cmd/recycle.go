@@ -0,0 +1,455 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ludviglundgren/qbittorrent-cli/internal/config"
+	"github.com/ludviglundgren/qbittorrent-cli/internal/recycle"
+	"github.com/ludviglundgren/qbittorrent-cli/pkg/utils"
+
+	"github.com/autobrr/go-qbittorrent"
+	"github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// RunRecycle cmd for recycle bin actions
+func RunRecycle() *cobra.Command {
+	var command = &cobra.Command{
+		Use:   "recycle",
+		Short: "Recycle bin subcommand",
+		Long: `List, restore and empty the recycle bin of torrents removed with qbt torrent remove --recycle.
+
+The recycle dir is read from dir in [recycle] in the config, or set with --dir.`,
+	}
+
+	command.PersistentFlags().String("dir", "", "Recycle dir. Defaults to dir in [recycle] in the config")
+
+	command.AddCommand(RunRecycleList())
+	command.AddCommand(RunRecycleRestore())
+	command.AddCommand(RunRecycleEmpty())
+
+	return command
+}
+
+// RunRecycleList cmd to list the recycle bin
+func RunRecycleList() *cobra.Command {
+	var output string
+
+	var command = &cobra.Command{
+		Use:   "list",
+		Short: "List torrents in the recycle bin",
+		Long:  `List the torrents in the recycle bin, oldest first.`,
+		Example: `  qbt recycle list
+  qbt recycle list --output json`,
+	}
+
+	command.Flags().StringVar(&output, "output", "", "Print as [formatted text (default), json]")
+
+	command.RunE = func(cmd *cobra.Command, args []string) error {
+		config.InitConfig()
+
+		bin, err := recycleBin(cmd)
+		if err != nil {
+			return err
+		}
+
+		entries, err := bin.List()
+		if err != nil {
+			return err
+		}
+
+		switch output {
+		case "json":
+			if entries == nil {
+				entries = []recycle.Entry{}
+			}
+
+			res, err := json.Marshal(entries)
+			if err != nil {
+				return errors.Wrap(err, "could not marshal entries to json")
+			}
+			fmt.Println(string(res))
+
+		default:
+			if len(entries) == 0 {
+				log.Println("recycle bin is empty")
+				return nil
+			}
+
+			if err := printRecycleEntries(entries); err != nil {
+				return errors.Wrap(err, "could not print entries")
+			}
+		}
+
+		return nil
+	}
+
+	return command
+}
+
+var recycleEntryTemplate = `{{ range .}}
+[*] {{.Name}}
+    Hash: {{.Hash}} Category: {{.Category}} Tags: {{.Tags}}
+    Size: {{.Size}} Deleted: {{.DeletedAt}}
+    Save path: {{.SavePath}}
+{{end}}
+`
+
+type RecycleEntryData struct {
+	Hash      string
+	Name      string
+	Category  string
+	Tags      string
+	Size      string
+	DeletedAt string
+	SavePath  string
+}
+
+func printRecycleEntries(entries []recycle.Entry) error {
+	tmpl, err := template.New("recycle").Parse(recycleEntryTemplate)
+	if err != nil {
+		return err
+	}
+
+	var data []RecycleEntryData
+
+	for _, e := range entries {
+		data = append(data, RecycleEntryData{
+			Hash:      e.Hash,
+			Name:      e.Name,
+			Category:  e.Category,
+			Tags:      strings.Join(e.Tags, ", "),
+			Size:      humanize.Bytes(uint64(e.Size)),
+			DeletedAt: humanize.Time(e.DeletedAt),
+			SavePath:  e.SavePath,
+		})
+	}
+
+	return tmpl.Execute(os.Stdout, data)
+}
+
+// RunRecycleRestore cmd to restore torrents from the recycle bin
+func RunRecycleRestore() *cobra.Command {
+	var (
+		dry   bool
+		noAdd bool
+	)
+
+	var command = &cobra.Command{
+		Use:   "restore <hash> ...",
+		Short: "Restore torrents from the recycle bin",
+		Long: `Move the files of torrents in the recycle bin back to their save path and add the torrents to qBittorrent again
+with their category and tags. Existing files in the save path are never overwritten.
+
+Use --no-add to only restore the files. Use - to read hashes from stdin.`,
+		Example: `  qbt recycle restore HASH
+  qbt recycle restore HASH1 HASH2 --no-add`,
+		Args: cobra.MinimumNArgs(1),
+	}
+
+	command.Flags().BoolVar(&dry, "dry-run", false, "Run without doing anything")
+	command.Flags().BoolVar(&noAdd, "no-add", false, "Only restore the files, without adding the torrents")
+
+	command.RunE = func(cmd *cobra.Command, args []string) error {
+		hashes, err := expandStdin(args)
+		if err != nil {
+			return err
+		}
+
+		if err := utils.ValidateHash(hashes); err != nil {
+			return errors.Wrap(err, "invalid hashes supplied")
+		}
+
+		config.InitConfig()
+
+		bin, err := recycleBin(cmd)
+		if err != nil {
+			return err
+		}
+
+		var qb *qbittorrent.Client
+
+		ctx := cmd.Context()
+
+		if !noAdd && !dry {
+			qb = qbittorrent.NewClient(qbittorrent.Config{
+				Host:      config.Qbit.Addr,
+				APIKey:    config.Qbit.APIKey,
+				Username:  config.Qbit.Login,
+				Password:  config.Qbit.Password,
+				BasicUser: config.Qbit.BasicUser,
+				BasicPass: config.Qbit.BasicPass,
+			})
+
+			if err := qb.LoginCtx(ctx); err != nil {
+				return errors.Wrap(err, "could not login to qbit")
+			}
+		}
+
+		failed := 0
+
+		for _, hash := range hashes {
+			entry, err := bin.Get(hash)
+			if err != nil {
+				log.Printf("could not restore %s: %q\n", hash, err)
+				failed++
+				continue
+			}
+
+			if dry {
+				log.Printf("dry-run: restore %s %s to %s\n", entry.Hash, entry.Name, entry.SavePath)
+				continue
+			}
+
+			if err := restoreRecycled(ctx, qb, bin, entry); err != nil {
+				log.Printf("could not restore %s %s: %q\n", entry.Hash, entry.Name, err)
+				failed++
+				continue
+			}
+
+			log.Printf("restored %s %s to %s\n", entry.Hash, entry.Name, entry.SavePath)
+		}
+
+		if failed > 0 {
+			cmd.SilenceUsage = true
+			return errors.Errorf("could not restore %d torrent(s)", failed)
+		}
+
+		return nil
+	}
+
+	return command
+}
+
+// restoreRecycled restores the files of the entry and adds the torrent with qb when not nil. The entry is removed when done.
+func restoreRecycled(ctx context.Context, qb *qbittorrent.Client, bin *recycle.Bin, entry recycle.Entry) error {
+	if _, err := bin.Restore(entry.Hash); err != nil {
+		return err
+	}
+
+	if qb != nil {
+		if !entry.HasTorrent {
+			return errors.New("files restored, but the entry has no .torrent file to add")
+		}
+
+		options := map[string]string{
+			"savepath": entry.SavePath,
+			"autoTMM":  "false",
+		}
+
+		if entry.DownloadPath != "" {
+			options["useDownloadPath"] = "true"
+			options["downloadPath"] = entry.DownloadPath
+		}
+
+		if entry.Category != "" {
+			options["category"] = entry.Category
+		}
+
+		if len(entry.Tags) > 0 {
+			options["tags"] = strings.Join(entry.Tags, ",")
+		}
+
+		if _, err := qb.AddTorrentFromFileCtx(ctx, bin.TorrentFile(entry.Hash), options); err != nil {
+			return errors.Wrap(err, "files restored, but could not add torrent")
+		}
+	}
+
+	return bin.Remove(entry.Hash)
+}
+
+// RunRecycleEmpty cmd to delete torrents from the recycle bin
+func RunRecycleEmpty() *cobra.Command {
+	var (
+		dry       bool
+		olderThan string
+	)
+
+	var command = &cobra.Command{
+		Use:   "empty",
+		Short: "Delete torrents from the recycle bin",
+		Long: `Permanently delete the torrents in the recycle bin with their files.
+
+Use --older-than to only delete torrents removed longer ago, like 7d or 36h.`,
+		Example: `  qbt recycle empty --older-than 7d
+  qbt recycle empty --dry-run`,
+	}
+
+	command.Flags().BoolVar(&dry, "dry-run", false, "Run without doing anything")
+	command.Flags().StringVar(&olderThan, "older-than", "", "Only delete torrents removed longer ago than this, like 7d or 36h")
+
+	command.RunE = func(cmd *cobra.Command, args []string) error {
+		var age time.Duration
+
+		if olderThan != "" {
+			d, err := utils.ParseDuration(olderThan)
+			if err != nil {
+				return errors.Wrap(err, "invalid --older-than")
+			}
+
+			age = d
+		}
+
+		config.InitConfig()
+
+		bin, err := recycleBin(cmd)
+		if err != nil {
+			return err
+		}
+
+		entries, err := bin.List()
+		if err != nil {
+			return err
+		}
+
+		before := time.Now().Add(-age)
+
+		var (
+			deleted int
+			size    int64
+		)
+
+		for _, e := range entries {
+			if e.DeletedAt.After(before) {
+				continue
+			}
+
+			if dry {
+				log.Printf("dry-run: delete %s %s (%s)\n", e.Hash, e.Name, humanize.Bytes(uint64(e.Size)))
+				deleted++
+				size += e.Size
+				continue
+			}
+
+			if err := bin.Remove(e.Hash); err != nil {
+				return errors.Wrapf(err, "could not delete %s", e.Hash)
+			}
+
+			log.Printf("deleted %s %s (%s)\n", e.Hash, e.Name, humanize.Bytes(uint64(e.Size)))
+			deleted++
+			size += e.Size
+		}
+
+		if dry {
+			log.Printf("dry-run: (%d) torrent(s) with %s to delete\n", deleted, humanize.Bytes(uint64(size)))
+			return nil
+		}
+
+		log.Printf("deleted (%d) torrent(s) with %s from the recycle bin\n", deleted, humanize.Bytes(uint64(size)))
+
+		return nil
+	}
+
+	return command
+}
+
+// recycleBin returns the recycle bin from the --dir flag or the config
+func recycleBin(cmd *cobra.Command) (*recycle.Bin, error) {
+	dir := config.Recycle.Dir
+
+	if f := cmd.Flags().Lookup("dir"); f != nil && f.Changed {
+		dir = f.Value.String()
+	}
+
+	if dir == "" {
+		return nil, errors.New("no recycle dir: set dir in [recycle] in the config")
+	}
+
+	dir, err := utils.ExpandTilde(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not expand recycle dir: %s", dir)
+	}
+
+	return recycle.New(dir), nil
+}
+
+// recycleTorrent removes the torrent from qBittorrent without its files and moves the files to the recycle bin.
+// The files of incomplete torrents are moved from the download path when they are in it.
+func recycleTorrent(ctx context.Context, qb *qbittorrent.Client, bin *recycle.Bin, t qbittorrent.Torrent) error {
+	files, err := qb.GetFilesInformationCtx(ctx, t.Hash)
+	if err != nil {
+		return errors.Wrap(err, "could not get files")
+	}
+
+	entry := recycle.Entry{
+		Hash:     t.Hash,
+		Name:     t.Name,
+		Category: t.Category,
+		SavePath: t.SavePath,
+		Size:     t.Size,
+	}
+
+	if filesInDownloadPath(t) {
+		entry.DownloadPath = t.DownloadPath
+	}
+
+	for _, tag := range strings.Split(t.Tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			entry.Tags = append(entry.Tags, tag)
+		}
+	}
+
+	if files != nil {
+		for _, f := range *files {
+			entry.Files = append(entry.Files, f.Name)
+		}
+	}
+
+	trackers, err := qb.GetTorrentTrackersCtx(ctx, t.Hash)
+	if err != nil {
+		return errors.Wrap(err, "could not get trackers")
+	}
+
+	for _, tr := range trackers {
+		// DHT, PeX and LSD are listed as trackers like ** [DHT] **
+		if strings.HasPrefix(tr.Url, "** [") {
+			continue
+		}
+
+		entry.Trackers = append(entry.Trackers, tr.Url)
+	}
+
+	torrent, err := qb.ExportTorrentCtx(ctx, t.Hash)
+	if err != nil {
+		log.Printf("could not export torrent %s, it can not be added again on restore: %q\n", t.Hash, err)
+	}
+
+	if err := qb.DeleteTorrentsCtx(ctx, []string{t.Hash}, false); err != nil {
+		return errors.Wrap(err, "could not remove torrent")
+	}
+
+	errs, err := bin.Add(entry, torrent)
+	if err != nil {
+		return errors.Wrap(err, "torrent removed, but could not move files to the recycle bin")
+	}
+
+	for name, err := range errs {
+		log.Printf("could not move %s to the recycle bin: %q\n", name, err)
+	}
+
+	if len(errs) > 0 {
+		return errors.Errorf("torrent removed, but could not move %d file(s) to the recycle bin", len(errs))
+	}
+
+	return nil
+}
+
+// filesInDownloadPath reports whether the content of the torrent is in its download path, like for incomplete torrents
+func filesInDownloadPath(t qbittorrent.Torrent) bool {
+	if t.DownloadPath == "" {
+		return false
+	}
+
+	p := contentPath(t)
+	d := cleanSavePath(strings.ReplaceAll(t.DownloadPath, "\\", "/"))
+
+	return p == d || strings.HasPrefix(p, d+"/")
+}
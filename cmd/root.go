@@ -3,6 +3,7 @@ package cmd
 import (
 	"io"
 	"log"
+	"strconv"
 
 	"github.com/ludviglundgren/qbittorrent-cli/internal/config"
 
@@ -43,8 +44,30 @@ Documentation is available at https://github.com/ludviglundgren/qbittorrent-cli`
 	rootCmd.AddCommand(RunTransfer())
 	rootCmd.AddCommand(RunCategory())
 	rootCmd.AddCommand(RunTag())
+	rootCmd.AddCommand(RunRules())
+	rootCmd.AddCommand(RunOrphans())
+	rootCmd.AddCommand(RunRecycle())
 	rootCmd.AddCommand(RunVersion(version, commit, date))
 	rootCmd.AddCommand(RunUpdate(version))
 
 	return rootCmd
 }
+
+// ExitCodeError is returned by commands that need to exit with a specific code,
+// so scripts can tell outcomes apart without parsing output.
+type ExitCodeError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitCodeError) Error() string {
+	if e.Err == nil {
+		return "exit code " + strconv.Itoa(e.Code)
+	}
+
+	return e.Err.Error()
+}
+
+func (e *ExitCodeError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,393 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ludviglundgren/qbittorrent-cli/internal/config"
+	"github.com/ludviglundgren/qbittorrent-cli/internal/domain"
+	"github.com/ludviglundgren/qbittorrent-cli/internal/fs"
+
+	"github.com/autobrr/go-qbittorrent"
+	"github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	FreeSpaceSourceClient = "client"
+	FreeSpaceSourceLocal  = "local"
+
+	// tagLowSpace marks torrents paused by rules enforce so they can be resumed when space is available again
+	tagLowSpace = "qbt-low-space"
+)
+
+// RunRules cmd for rules actions
+func RunRules() *cobra.Command {
+	var command = &cobra.Command{
+		Use:   "rules",
+		Short: "Rules subcommand",
+		Long:  `Apply the rules from config`,
+	}
+
+	command.AddCommand(RunRulesEnforce())
+
+	return command
+}
+
+// RunRulesEnforce cmd to pause and resume downloads by free space
+func RunRulesEnforce() *cobra.Command {
+	var (
+		dry      bool
+		interval time.Duration
+	)
+
+	var command = &cobra.Command{
+		Use:   "enforce",
+		Short: "Enforce the free space rule on running downloads",
+		Long: `Check the free space against min_free_space from [rules] in the config.
+
+The free space is read from the client (free space of the default save path) or, with free_space_source = "local",
+from the filesystem of free_space_path. Bytes still to be downloaded by running torrents are subtracted unless
+the client preallocates disk space.
+
+When the free space is below the minimum and pause_on_low_space is enabled, downloading torrents are paused
+and tagged with qbt-low-space. Once there is enough space again they are resumed, oldest first, as long as
+their remaining size fits above the minimum.
+
+Use --interval to keep running and check again on every interval.`,
+		Example: `  qbt rules enforce
+  qbt rules enforce --dry-run
+  qbt rules enforce --interval 5m`,
+	}
+
+	command.Flags().BoolVar(&dry, "dry-run", false, "Run without doing anything")
+	command.Flags().DurationVar(&interval, "interval", 0, "Check again on every interval, 0 checks once")
+
+	command.RunE = func(cmd *cobra.Command, args []string) error {
+		config.InitConfig()
+
+		if !config.Rules.Enabled {
+			return errors.New("rules are not enabled in config")
+		}
+
+		rule, err := parseFreeSpaceRule(config.Rules)
+		if err != nil {
+			return err
+		}
+
+		if rule == nil {
+			return errors.New("no min_free_space set in [rules]")
+		}
+
+		qbtSettings := qbittorrent.Config{
+			Host:      config.Qbit.Addr,
+			APIKey:    config.Qbit.APIKey,
+			Username:  config.Qbit.Login,
+			Password:  config.Qbit.Password,
+			BasicUser: config.Qbit.BasicUser,
+			BasicPass: config.Qbit.BasicPass,
+		}
+
+		qb := qbittorrent.NewClient(qbtSettings)
+
+		ctx := cmd.Context()
+
+		if err := qb.LoginCtx(ctx); err != nil {
+			return errors.Wrap(err, "could not login to qbit")
+		}
+
+		if interval <= 0 {
+			return enforceFreeSpace(ctx, qb, rule, config.Rules.PauseOnLowSpace, dry)
+		}
+
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := enforceFreeSpace(ctx, qb, rule, config.Rules.PauseOnLowSpace, dry); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+
+				log.Printf("could not enforce rules: %q\n", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	}
+
+	return command
+}
+
+// freeSpaceRule is the min_free_space rule from config
+type freeSpaceRule struct {
+	min    int64
+	source string
+	path   string
+}
+
+// parseFreeSpaceRule returns the free space rule, or nil if min_free_space is not set
+func parseFreeSpaceRule(rules domain.Rules) (*freeSpaceRule, error) {
+	if rules.MinFreeSpace == "" {
+		return nil, nil
+	}
+
+	min, err := humanize.ParseBytes(rules.MinFreeSpace)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid min_free_space: %s", rules.MinFreeSpace)
+	}
+
+	rule := &freeSpaceRule{
+		min:    int64(min),
+		source: strings.ToLower(rules.FreeSpaceSource),
+		path:   rules.FreeSpacePath,
+	}
+
+	switch rule.source {
+	case "":
+		rule.source = FreeSpaceSourceClient
+	case FreeSpaceSourceClient, FreeSpaceSourceLocal:
+	default:
+		return nil, errors.Errorf("invalid free_space_source: %s. Possible values: client, local", rules.FreeSpaceSource)
+	}
+
+	return rule, nil
+}
+
+// freeSpace returns the free space of the save path. The client only reports the free space of its default save path.
+func (r *freeSpaceRule) freeSpace(ctx context.Context, qb *qbittorrent.Client, savePath string) (int64, error) {
+	if r.source == FreeSpaceSourceClient {
+		free, err := qb.GetFreeSpaceOnDiskCtx(ctx)
+		if err != nil {
+			return 0, errors.Wrap(err, "could not get free space from client")
+		}
+
+		return free, nil
+	}
+
+	path := r.path
+	if path == "" {
+		path = savePath
+	}
+
+	if path == "" {
+		defaultPath, err := qb.GetDefaultSavePathCtx(ctx)
+		if err != nil {
+			return 0, errors.Wrap(err, "could not get default save path")
+		}
+
+		path = defaultPath
+	}
+
+	free, err := fs.FreeSpace(path)
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not get free space of: %s", path)
+	}
+
+	return free, nil
+}
+
+// isRunningDownload reports whether the torrent is downloading or queued to download
+func isRunningDownload(t qbittorrent.Torrent) bool {
+	switch t.State {
+	case qbittorrent.TorrentStateDownloading, qbittorrent.TorrentStateMetaDl, "forcedMetaDL",
+		qbittorrent.TorrentStateStalledDl, qbittorrent.TorrentStateForcedDl, qbittorrent.TorrentStateQueuedDl,
+		qbittorrent.TorrentStateCheckingDl, qbittorrent.TorrentStateAllocating:
+		return true
+	}
+
+	return false
+}
+
+// pendingBytes returns the bytes still to be written by running downloads
+func pendingBytes(torrents []qbittorrent.Torrent) int64 {
+	var pending int64
+
+	for _, t := range torrents {
+		if isRunningDownload(t) && t.AmountLeft > 0 {
+			pending += t.AmountLeft
+		}
+	}
+
+	return pending
+}
+
+// availableSpace returns the free space left after running downloads complete.
+// With preallocation the space of running downloads is already taken.
+func availableSpace(ctx context.Context, qb *qbittorrent.Client, rule *freeSpaceRule, savePath string, torrents []qbittorrent.Torrent) (int64, bool, error) {
+	free, err := rule.freeSpace(ctx, qb, savePath)
+	if err != nil {
+		return 0, false, err
+	}
+
+	prefs, err := qb.GetAppPreferencesCtx(ctx)
+	if err != nil {
+		return 0, false, errors.Wrap(err, "could not get preferences")
+	}
+
+	if prefs.PreallocateAll {
+		return free, true, nil
+	}
+
+	return free - pendingBytes(torrents), false, nil
+}
+
+// spaceGuard checks torrents about to be added against the free space rule
+type spaceGuard struct {
+	min         int64
+	available   int64
+	preallocate bool
+}
+
+func newSpaceGuard(ctx context.Context, qb *qbittorrent.Client, rule *freeSpaceRule, savePath string) (*spaceGuard, error) {
+	torrents, err := qb.GetTorrentsCtx(ctx, qbittorrent.TorrentFilterOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not fetch torrents")
+	}
+
+	available, preallocate, err := availableSpace(ctx, qb, rule, savePath, torrents)
+	if err != nil {
+		return nil, err
+	}
+
+	return &spaceGuard{min: rule.min, available: available, preallocate: preallocate}, nil
+}
+
+// allow reports whether size bytes fit above the minimum free space and reserves them for the next check.
+// Magnets have no size until the metadata is received, so only the current free space is checked.
+func (g *spaceGuard) allow(size int64) bool {
+	if g.available-size < g.min {
+		log.Printf("not enough free space: %s available, %s needed, minimum %s\n", humanize.Bytes(uint64(max(g.available, 0))), humanize.Bytes(uint64(size)), humanize.Bytes(uint64(g.min)))
+		return false
+	}
+
+	g.available -= size
+
+	return true
+}
+
+// enforceFreeSpace pauses running downloads when free space is below the minimum,
+// and resumes the torrents it paused once they fit again
+func enforceFreeSpace(ctx context.Context, qb *qbittorrent.Client, rule *freeSpaceRule, pause bool, dry bool) error {
+	torrents, err := qb.GetTorrentsCtx(ctx, qbittorrent.TorrentFilterOptions{})
+	if err != nil {
+		return errors.Wrap(err, "could not fetch torrents")
+	}
+
+	available, preallocate, err := availableSpace(ctx, qb, rule, "", torrents)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("available space: %s, minimum: %s\n", humanize.Bytes(uint64(max(available, 0))), humanize.Bytes(uint64(rule.min)))
+
+	if available < rule.min {
+		var hashes []string
+		for _, t := range torrents {
+			if isRunningDownload(t) {
+				hashes = append(hashes, t.Hash)
+			}
+		}
+
+		if len(hashes) == 0 {
+			log.Println("free space below minimum, no running downloads to pause")
+			return nil
+		}
+
+		if !pause {
+			log.Printf("free space below minimum with (%d) running download(s), set pause_on_low_space to pause them\n", len(hashes))
+			return nil
+		}
+
+		if dry {
+			log.Printf("dry-run: pause (%d) download(s) for low free space\n", len(hashes))
+			return nil
+		}
+
+		if err := batchRequests(hashes, func(start, end int) error {
+			return qb.PauseCtx(ctx, hashes[start:end])
+		}); err != nil {
+			return errors.Wrap(err, "could not pause torrents")
+		}
+
+		if err := batchRequests(hashes, func(start, end int) error {
+			return qb.AddTagsCtx(ctx, hashes[start:end], tagLowSpace)
+		}); err != nil {
+			return errors.Wrap(err, "could not tag torrents")
+		}
+
+		log.Printf("paused (%d) download(s) for low free space\n", len(hashes))
+
+		return nil
+	}
+
+	var paused []qbittorrent.Torrent
+	for _, t := range torrents {
+		if _, ok := validateTag([]string{tagLowSpace}, t.Tags); ok {
+			paused = append(paused, t)
+		}
+	}
+
+	if len(paused) == 0 {
+		return nil
+	}
+
+	sort.Slice(paused, func(i, j int) bool {
+		return paused[i].AddedOn < paused[j].AddedOn
+	})
+
+	guard := &spaceGuard{min: rule.min, available: available, preallocate: preallocate}
+
+	var hashes []string
+	for _, t := range paused {
+		size := t.AmountLeft
+		if guard.preallocate {
+			size = 0
+		}
+
+		if !guard.allow(size) {
+			break
+		}
+
+		hashes = append(hashes, t.Hash)
+	}
+
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	if dry {
+		log.Printf("dry-run: resume (%d/%d) download(s) paused for low free space\n", len(hashes), len(paused))
+		return nil
+	}
+
+	if err := batchRequests(hashes, func(start, end int) error {
+		return qb.ResumeCtx(ctx, hashes[start:end])
+	}); err != nil {
+		return errors.Wrap(err, "could not resume torrents")
+	}
+
+	if err := batchRequests(hashes, func(start, end int) error {
+		return qb.RemoveTagsCtx(ctx, hashes[start:end], tagLowSpace)
+	}); err != nil {
+		return errors.Wrap(err, "could not untag torrents")
+	}
+
+	log.Printf("resumed (%d/%d) download(s) paused for low free space\n", len(hashes), len(paused))
+
+	return nil
+}
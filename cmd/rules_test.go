@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/ludviglundgren/qbittorrent-cli/internal/config"
+	"github.com/ludviglundgren/qbittorrent-cli/internal/domain"
+
+	"github.com/autobrr/go-qbittorrent"
+)
+
+func Test_parseFreeSpaceRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   domain.Rules
+		want    *freeSpaceRule
+		wantErr bool
+	}{
+		{
+			name:  "not set",
+			rules: domain.Rules{Enabled: true, MaxActiveDownloads: 2},
+			want:  nil,
+		},
+		{
+			name:  "default source",
+			rules: domain.Rules{MinFreeSpace: "50GB"},
+			want:  &freeSpaceRule{min: 50_000_000_000, source: FreeSpaceSourceClient},
+		},
+		{
+			name:  "local source with path",
+			rules: domain.Rules{MinFreeSpace: "10 GiB", FreeSpaceSource: "Local", FreeSpacePath: "/data"},
+			want:  &freeSpaceRule{min: 10 << 30, source: FreeSpaceSourceLocal, path: "/data"},
+		},
+		{
+			name:    "invalid size",
+			rules:   domain.Rules{MinFreeSpace: "lots"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid source",
+			rules:   domain.Rules{MinFreeSpace: "1GB", FreeSpaceSource: "statfs"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFreeSpaceRule(tt.rules)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFreeSpaceRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseFreeSpaceRule() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_pendingBytes(t *testing.T) {
+	torrents := []qbittorrent.Torrent{
+		{State: qbittorrent.TorrentStateDownloading, AmountLeft: 100},
+		{State: qbittorrent.TorrentStateStalledDl, AmountLeft: 50},
+		{State: qbittorrent.TorrentStateQueuedDl, AmountLeft: 25},
+		{State: qbittorrent.TorrentStateStoppedDl, AmountLeft: 1000},
+		{State: qbittorrent.TorrentStateUploading, AmountLeft: 0},
+	}
+
+	if got := pendingBytes(torrents); got != 175 {
+		t.Errorf("pendingBytes() = %d, want 175", got)
+	}
+}
+
+func Test_spaceGuard_allow(t *testing.T) {
+	g := &spaceGuard{min: 100, available: 300}
+
+	tests := []struct {
+		name string
+		size int64
+		want bool
+	}{
+		{name: "fits", size: 150, want: true},
+		{name: "does not fit after reserving", size: 100, want: false},
+		{name: "fits exactly", size: 50, want: true},
+		{name: "magnet at minimum", size: 0, want: true},
+		{name: "one byte over", size: 1, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := g.allow(tt.size); got != tt.want {
+				t.Errorf("allow(%d) = %v, want %v", tt.size, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_checkAddRules(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"hash":"aaaa","state":"downloading"},{"hash":"bbbb","state":"stalledDL"}]`))
+	}))
+	defer srv.Close()
+
+	qb := qbittorrent.NewClient(qbittorrent.Config{Host: srv.URL})
+
+	tests := []struct {
+		name               string
+		maxActiveDownloads int
+		want               bool
+	}{
+		{name: "no limit", maxActiveDownloads: 0, want: true},
+		{name: "below max", maxActiveDownloads: 3, want: true},
+		{name: "max reached", maxActiveDownloads: 2, want: false},
+	}
+
+	rules := config.Rules
+	defer func() { config.Rules = rules }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Rules = domain.Rules{Enabled: true, MaxActiveDownloads: tt.maxActiveDownloads}
+
+			got, err := checkAddRules(context.Background(), qb)
+			if err != nil {
+				t.Fatalf("checkAddRules() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("checkAddRules() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ludviglundgren/qbittorrent-cli/internal/downloader"
+
+	"github.com/pkg/errors"
+)
+
+// stdin is read when - is passed in place of hashes or torrents
+var stdin io.Reader = os.Stdin
+
+// readLines returns the newline separated values from r, skipping empty lines
+func readLines(r io.Reader) ([]string, error) {
+	var lines []string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "could not read stdin")
+	}
+
+	return lines, nil
+}
+
+// unquoteHash removes a trailing comma and one pair of matching quotes around the hash,
+// so json strings from jq work without -r
+func unquoteHash(hash string) string {
+	hash = strings.TrimSpace(strings.TrimSuffix(hash, ","))
+
+	if len(hash) >= 2 && (hash[0] == '"' || hash[0] == '\'') && hash[len(hash)-1] == hash[0] {
+		hash = strings.TrimSpace(hash[1 : len(hash)-1])
+	}
+
+	return hash
+}
+
+// expandStdin replaces - in values with the hashes read from stdin
+func expandStdin(values []string) ([]string, error) {
+	var expanded []string
+	read := false
+
+	for _, value := range values {
+		if value != "-" {
+			expanded = append(expanded, value)
+			continue
+		}
+
+		if read {
+			continue
+		}
+		read = true
+
+		lines, err := readLines(stdin)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, line := range lines {
+			if hash := unquoteHash(line); hash != "" {
+				expanded = append(expanded, hash)
+			}
+		}
+	}
+
+	return expanded, nil
+}
+
+// readStdinTorrents reads stdin for torrent add. It returns the raw torrent file if stdin is a
+// torrent file, otherwise the newline separated magnets, urls and paths.
+func readStdinTorrents(r io.Reader) ([]byte, []string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not read stdin")
+	}
+
+	if downloader.Validate(data) == nil {
+		return data, nil, nil
+	}
+
+	lines, err := readLines(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return nil, lines, nil
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_readLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "hashes",
+			input: "hash1\nhash2\n",
+			want:  []string{"hash1", "hash2"},
+		},
+		{
+			name:  "empty lines and whitespace",
+			input: "\n  hash1  \r\n\n\thash2\n\n",
+			want:  []string{"hash1", "hash2"},
+		},
+		{
+			name:  "magnets and paths",
+			input: "magnet:?xt=urn:btih:5dee65101db281ac9c46344cd6b175cdcad53426&dn=download\n./files/my file.torrent\n",
+			want:  []string{"magnet:?xt=urn:btih:5dee65101db281ac9c46344cd6b175cdcad53426&dn=download", "./files/my file.torrent"},
+		},
+		{
+			name:  "paths with quotes and commas are kept",
+			input: "it's.torrent\n'quoted.torrent\nfile,.torrent,\n",
+			want:  []string{"it's.torrent", "'quoted.torrent", "file,.torrent,"},
+		},
+		{
+			name:  "empty",
+			input: "",
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readLines(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readLines() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_expandStdin(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		stdin  string
+		want   []string
+	}{
+		{
+			name:   "no stdin",
+			values: []string{"hash1", "hash2"},
+			stdin:  "hash3\n",
+			want:   []string{"hash1", "hash2"},
+		},
+		{
+			name:   "stdin only",
+			values: []string{"-"},
+			stdin:  "hash1\nhash2\n",
+			want:   []string{"hash1", "hash2"},
+		},
+		{
+			name:   "stdin with args",
+			values: []string{"hash1", "-", "hash4"},
+			stdin:  "hash2\nhash3\n",
+			want:   []string{"hash1", "hash2", "hash3", "hash4"},
+		},
+		{
+			name:   "json strings",
+			values: []string{"-"},
+			stdin:  "\"hash1\",\n'hash2'\n\"hash3\"\n",
+			want:   []string{"hash1", "hash2", "hash3"},
+		},
+		{
+			name:   "stdin read once",
+			values: []string{"-", "-"},
+			stdin:  "hash1\n",
+			want:   []string{"hash1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orig := stdin
+			defer func() { stdin = orig }()
+
+			stdin = strings.NewReader(tt.stdin)
+
+			got, err := expandStdin(tt.values)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expandStdin() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_readStdinTorrents(t *testing.T) {
+	data, lines, err := readStdinTorrents(strings.NewReader("magnet:?xt=urn:btih:5dee65101db281ac9c46344cd6b175cdcad53426\nit's.torrent\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data != nil {
+		t.Errorf("readStdinTorrents() data = %v, want nil", data)
+	}
+	if want := []string{"magnet:?xt=urn:btih:5dee65101db281ac9c46344cd6b175cdcad53426", "it's.torrent"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("readStdinTorrents() lines = %v, want %v", lines, want)
+	}
+}
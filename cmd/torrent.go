@@ -13,12 +13,16 @@ func RunTorrent() *cobra.Command {
 	}
 
 	command.AddCommand(RunTorrentAdd())
+	command.AddCommand(RunTorrentBalance())
 	command.AddCommand(RunTorrentCategory())
 	command.AddCommand(RunTorrentCompare())
 	command.AddCommand(RunTorrentExport())
+	command.AddCommand(RunTorrentFileEdit())
+	command.AddCommand(RunTorrentFiles())
 	command.AddCommand(RunTorrentHash())
 	command.AddCommand(RunTorrentImport())
 	command.AddCommand(RunTorrentList())
+	command.AddCommand(RunTorrentMove())
 	command.AddCommand(RunTorrentPause())
 	command.AddCommand(RunTorrentReannounce())
 	command.AddCommand(RunTorrentRecheck())
@@ -27,6 +31,8 @@ func RunTorrent() *cobra.Command {
 	command.AddCommand(RunTorrentShareLimit())
 	command.AddCommand(RunTorrentTag())
 	command.AddCommand(RunTorrentTracker())
+	command.AddCommand(RunTorrentVerify())
+	command.AddCommand(RunTorrentWatch())
 
 	return command
 }
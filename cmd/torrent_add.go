@@ -2,20 +2,20 @@ package cmd
 
 import (
 	"context"
-	"io"
 	"log"
-	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/ludviglundgren/qbittorrent-cli/internal/config"
+	"github.com/ludviglundgren/qbittorrent-cli/internal/domain"
+	"github.com/ludviglundgren/qbittorrent-cli/internal/downloader"
+	"github.com/ludviglundgren/qbittorrent-cli/internal/reannounce"
 
-	"github.com/anacrolix/torrent/metainfo"
 	"github.com/autobrr/go-qbittorrent"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -47,18 +47,68 @@ func RunTorrentAdd() *cobra.Command {
 		stopCondition  string
 		sleep          time.Duration
 		recheck        bool
+		preset         string
+		contentLayout  string
+
+		ratioLimit               float64
+		seedingTimeLimit         int64
+		inactiveSeedingTimeLimit int64
+
+		onDuplicate  string
+		checkContent bool
+
+		includeFiles    []string
+		excludeFiles    []string
+		rename          string
+		metadataTimeout time.Duration
+
+		wait         bool
+		waitFor      string
+		waitTimeout  time.Duration
+		waitInterval time.Duration
+
+		output string
+
+		urlDirect bool
 	)
 
 	command := &cobra.Command{
 		Use:   "add",
 		Short: "Add torrent(s)",
-		Long:  `Add new torrent(s) to qBittorrent from file or magnet. Supports glob pattern for files like: ./files/*.torrent`,
+		Long: `Add new torrent(s) to qBittorrent from file or magnet. Supports glob pattern for files like: ./files/*.torrent
+
+Torrents are checked against the client by hash before adding. Use --on-duplicate to choose what happens
+with torrents already present: skip them, add their trackers or tags to the existing torrent, or fail.
+With --check-content torrents with the same name and size under a different hash are reported as cross-seed candidates.
+
+Use --include-files and --exclude-files to only download some files. Patterns are matched case-insensitively against
+the file list after adding: *.nfo matches file names, Sample/ matches directories and Sample/*.mkv matches the end of paths.
+For magnets the file list is available once the metadata is received.
+
+Use --wait to block until the added torrent(s) reach the --wait-for state: metadata, downloaded or seeding.
+Exits non-zero on timeout or when a torrent errors, has missing files or is stopped before reaching the state,
+like torrents added with --paused or stopped by a stop condition or share limit.
+
+Torrent urls are downloaded with the timeout, retries, size limit and per host headers and cookies from [download] in the config.
+Redirects to magnet links are followed. Use --url-direct to let qBittorrent download the url instead.
+
+Use --output json to print one result per torrent with the hash, name, size, status (added, skipped or failed),
+reason and reannounce outcome. With json output every torrent is tried and the exit code is non-zero if any failed.
+
+Use --preset to apply a named preset from [add.presets.<name>] in the config. Flags set explicitly override the preset values.
+
+Use - to read from stdin: either a single .torrent file, or newline separated magnets, urls and paths.`,
 		Example: `  qbt torrent add my-file.torrent --category test --tags tag1
   qbt torrent add ./files/*.torrent --paused --skip-hash-check
-  qbt torrent add magnet:?xt=urn:btih:5dee65101db281ac9c46344cd6b175cdcad53426&dn=download`,
+  qbt torrent add my-file.torrent --preset movies --tags override
+  qbt torrent add my-file.torrent --exclude-files "*.nfo" --exclude-files "Sample/"
+  qbt torrent add my-file.torrent --wait --wait-for seeding --wait-timeout 2h
+  qbt torrent add magnet:?xt=urn:btih:5dee65101db281ac9c46344cd6b175cdcad53426&dn=download
+  cat magnets.txt | qbt torrent add -
+  curl -s https://example.com/file.torrent | qbt torrent add -`,
 		Args: func(cmd *cobra.Command, args []string) error {
 			if len(args) < 1 {
-				return errors.New("requires a torrent file, glob or magnet as first argument")
+				return errors.New("requires a torrent file, glob, magnet or - for stdin as first argument")
 			}
 
 			return nil
@@ -80,6 +130,23 @@ func RunTorrentAdd() *cobra.Command {
 	command.Flags().DurationVar(&sleep, "sleep", 200*time.Millisecond, "Set the amount of time to wait between adding torrents in seconds")
 	command.Flags().StringArrayVar(&tags, "tags", []string{}, "Add tags to torrent")
 	command.Flags().BoolVar(&recheck, "recheck", false, "Force recheck after adding (useful when using --paused)")
+	command.Flags().StringVar(&preset, "preset", "", "Apply a named preset from the config")
+	command.Flags().StringVar(&contentLayout, "content-layout", "", "Add torrent with the specified content layout. Possible values: Original, Subfolder, NoSubfolder")
+	command.Flags().StringArrayVar(&includeFiles, "include-files", []string{}, "Only download files matching glob pattern. Can be repeated")
+	command.Flags().StringArrayVar(&excludeFiles, "exclude-files", []string{}, "Skip files matching glob pattern, like *.nfo or Sample/. Can be repeated")
+	command.Flags().DurationVar(&metadataTimeout, "metadata-timeout", 5*time.Minute, "Max time to wait for magnet metadata when using --include-files or --exclude-files")
+	command.Flags().StringVar(&rename, "rename", "", "Rename torrent")
+	command.Flags().BoolVar(&urlDirect, "url-direct", false, "Let qBittorrent download torrent urls. Skips the duplicate check and options that need the hash")
+	command.Flags().StringVar(&output, "output", "", "Print results as [formatted text (default), json]. Logs are printed to stderr")
+	command.Flags().BoolVar(&wait, "wait", false, "Wait until the added torrent(s) reach the --wait-for state")
+	command.Flags().StringVar(&waitFor, "wait-for", WaitForDownloaded, "State to wait for. Possible values: metadata, downloaded, seeding")
+	command.Flags().DurationVar(&waitTimeout, "wait-timeout", 0, "Max time to wait. 0 waits forever")
+	command.Flags().DurationVar(&waitInterval, "wait-interval", 5*time.Second, "Interval between progress checks")
+	command.Flags().Float64Var(&ratioLimit, "ratio-limit", -2, "Ratio limit. -2 = global, -1 = unlimited, >=0 = ratio")
+	command.Flags().Int64Var(&seedingTimeLimit, "seeding-time-limit", -2, "Seeding time limit in MINUTES. -2 = global, -1 = unlimited, >=0 = minutes")
+	command.Flags().StringVar(&onDuplicate, "on-duplicate", OnDuplicateSkip, "Action for torrents already in the client. Possible values: skip, add-trackers, merge-tags, fail")
+	command.Flags().BoolVar(&checkContent, "check-content", false, "Report torrents with the same name and size under a different hash as cross-seed candidates")
+	command.Flags().Int64Var(&inactiveSeedingTimeLimit, "inactive-seeding-time-limit", -2, "Inactive seeding time limit in MINUTES. -2 = global, -1 = unlimited, >=0 = minutes")
 
 	command.RunE = func(cmd *cobra.Command, args []string) error {
 		config.InitConfig()
@@ -87,6 +154,92 @@ func RunTorrentAdd() *cobra.Command {
 		// first arg is path to torrent file
 		filePath := args[0]
 
+		// flags set explicitly override the preset, the preset overrides the [add] defaults
+		unset := func(name string) bool {
+			return !command.Flags().Changed(name)
+		}
+
+		p := domain.AddPreset{}
+		if preset != "" {
+			var err error
+			p, err = findAddPreset(config.Add.Presets, preset)
+			if err != nil {
+				return err
+			}
+
+			if unset("category") && p.Category != "" {
+				category = p.Category
+			}
+			if unset("tags") && len(p.Tags) > 0 {
+				tags = p.Tags
+			}
+			if unset("save-path") && p.SavePath != "" {
+				savePath = p.SavePath
+			}
+			if unset("paused") && p.Paused {
+				paused = true
+			}
+			if unset("skip-hash-check") && p.SkipHashCheck {
+				skipHashCheck = true
+			}
+			if unset("limit-ul") && p.UploadLimit > 0 {
+				uploadLimit = p.UploadLimit
+			}
+			if unset("limit-dl") && p.DownloadLimit > 0 {
+				downloadLimit = p.DownloadLimit
+			}
+			if unset("stop-condition") && p.StopCondition != "" {
+				stopCondition = p.StopCondition
+			}
+			if unset("content-layout") && p.ContentLayout != "" {
+				contentLayout = p.ContentLayout
+			}
+			if unset("include-files") && len(p.IncludeFiles) > 0 {
+				includeFiles = p.IncludeFiles
+			}
+			if unset("exclude-files") && len(p.ExcludeFiles) > 0 {
+				excludeFiles = p.ExcludeFiles
+			}
+			if unset("ratio-limit") && p.RatioLimit != nil {
+				ratioLimit = *p.RatioLimit
+			}
+			if unset("seeding-time-limit") && p.SeedingTimeLimit != nil {
+				seedingTimeLimit = *p.SeedingTimeLimit
+			}
+			if unset("inactive-seeding-time-limit") && p.InactiveSeedingTimeLimit != nil {
+				inactiveSeedingTimeLimit = *p.InactiveSeedingTimeLimit
+			}
+		}
+
+		if unset("sequential") {
+			sequential = presetBool(p.Sequential, config.Add.Sequential)
+		}
+		if unset("first-last-piece") {
+			firstLastPiece = presetBool(p.FirstLastPiece, config.Add.FirstLastPiece)
+		}
+
+		if err := validateShareLimits(ratioLimit, seedingTimeLimit, inactiveSeedingTimeLimit); err != nil {
+			return err
+		}
+
+		if err := validateOnDuplicate(onDuplicate); err != nil {
+			return err
+		}
+
+		if err := validateWaitFor(waitFor); err != nil {
+			return err
+		}
+
+		if output != "" && output != "json" {
+			return errors.Errorf("invalid --output: %s. Possible values: json", output)
+		}
+
+		switch contentLayout {
+		case "", "Original", "Subfolder", "NoSubfolder":
+		default:
+			return errors.Errorf("invalid content layout: %s. Possible values: Original, Subfolder, NoSubfolder", contentLayout)
+		}
+
 		qbtSettings := qbittorrent.Config{
 			Host:      config.Qbit.Addr,
 			APIKey:    config.Qbit.APIKey,
@@ -104,18 +257,67 @@ func RunTorrentAdd() *cobra.Command {
 			return errors.Wrap(err, "could not login to qbit")
 		}
 
+		jsonOutput := output == "json"
+
 		if config.Rules.Enabled && !ignoreRules {
-			activeDownloads, err := qb.GetTorrentsCtx(ctx, qbittorrent.TorrentFilterOptions{Filter: qbittorrent.TorrentFilterDownloading})
+			ok, err := checkAddRules(ctx, qb)
 			if err != nil {
-				return errors.Wrap(err, "could not fetch torrents")
+				return err
 			}
 
-			if len(activeDownloads) >= config.Rules.MaxActiveDownloads {
-				log.Printf("max active downloads of (%d) reached, skip adding\n", config.Rules.MaxActiveDownloads)
+			if !ok {
+				if jsonOutput {
+					return printAddResults([]*addResult{{Input: filePath, Status: AddStatusSkipped, Reason: "max active downloads reached"}})
+				}
+
 				return nil
 			}
 		}
 
+		var guard *spaceGuard
+		if config.Rules.Enabled && !ignoreRules {
+			rule, err := parseFreeSpaceRule(config.Rules)
+			if err != nil {
+				return err
+			}
+
+			if rule != nil {
+				guard, err = newSpaceGuard(ctx, qb, rule, savePath)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		existingTorrents, err := qb.GetTorrentsCtx(ctx, qbittorrent.TorrentFilterOptions{})
+		if err != nil {
+			return errors.Wrap(err, "could not fetch torrents")
+		}
+
+		duplicates := newDuplicateIndex(existingTorrents)
+
+		// checkDuplicate runs the on-duplicate action and reports whether the candidate should be skipped
+		checkDuplicate := func(c addCandidate, r *addResult) (bool, error) {
+			if existing, ok := duplicates.find(c); ok {
+				r.Status = AddStatusSkipped
+				r.Reason = "already present: " + existing.Hash
+				r.Existing = existing.Hash
+
+				return true, handleDuplicate(ctx, qb, onDuplicate, c, existing, tags, dry)
+			}
+
+			if checkContent {
+				if existing, ok := duplicates.findContent(c); ok {
+					log.Printf("cross-seed candidate: %s has the same content as %s (%s)\n", c.Source, existing.Hash, existing.Name)
+					r.CrossSeedOf = existing.Hash
+				}
+			}
+
+			return false, nil
+		}
+
+		selectFiles := len(includeFiles) > 0 || len(excludeFiles) > 0
+
 		options := map[string]string{}
 		if paused {
 			options["paused"] = "true"
@@ -124,18 +326,10 @@ func RunTorrentAdd() *cobra.Command {
 		if skipHashCheck {
 			options["skip_checking"] = "true"
 		}
-		if command.Flags().Changed("sequential") {
-			if sequential {
-				options["sequentialDownload"] = "true"
-			}
-		} else if config.Add.Sequential {
+		if sequential {
 			options["sequentialDownload"] = "true"
 		}
-		if command.Flags().Changed("first-last-piece") {
-			if firstLastPiece {
-				options["firstLastPiecePrio"] = "true"
-			}
-		} else if config.Add.FirstLastPiece {
+		if firstLastPiece {
 			options["firstLastPiecePrio"] = "true"
 		}
 		if savePath != "" {
@@ -155,256 +349,454 @@ func RunTorrentAdd() *cobra.Command {
 			options["upLimit"] = strconv.FormatUint(uploadLimit, 10)
 		}
 		if downloadLimit > 0 {
-			options["dlLimit"] = strconv.FormatUint(uploadLimit, 10)
+			options["dlLimit"] = strconv.FormatUint(downloadLimit, 10)
+		}
+		if contentLayout != "" {
+			options["contentLayout"] = contentLayout
+		}
+		if rename != "" {
+			options["rename"] = rename
+		}
+		if ratioLimit != -2 {
+			options["ratioLimit"] = strconv.FormatFloat(ratioLimit, 'f', -1, 64)
+		}
+		if seedingTimeLimit != -2 {
+			options["seedingTimeLimit"] = strconv.FormatInt(seedingTimeLimit, 10)
+		}
+		if inactiveSeedingTimeLimit != -2 {
+			options["inactiveSeedingTimeLimit"] = strconv.FormatInt(inactiveSeedingTimeLimit, 10)
 		}
 
-		if strings.HasPrefix(filePath, "magnet:") {
-			if dry {
-				log.Printf("dry-run: successfully added torrent from magnet %s!\n", filePath)
+		var tempFiles []string
 
-				return nil
+		defer func() {
+			for _, name := range tempFiles {
+				os.Remove(name)
 			}
+		}()
 
-			if _, err := qb.AddTorrentFromUrlCtx(ctx, filePath, options); err != nil {
-				return errors.Wrapf(err, "adding torrent %s failed", filePath)
+		// writeTemp writes a downloaded or piped torrent file to disk for adding
+		writeTemp := func(data []byte) (string, error) {
+			tempFile, err := os.CreateTemp("", "qbt-torrent-dl")
+			if err != nil {
+				return "", errors.Wrap(err, "could not create tmp file")
 			}
 
-			hash := ""
+			defer tempFile.Close()
 
-			// some trackers are bugged or slow, so we need to re-announce the torrent until it works
-			if config.Reannounce.Enabled && !paused {
-				magnet, err := metainfo.ParseMagnetUri(filePath)
-				if err != nil {
-					return errors.Wrapf(err, "could not parse magnet URI: %s", filePath)
-				}
+			tempFiles = append(tempFiles, tempFile.Name())
+
+			if _, err := tempFile.Write(data); err != nil {
+				return "", errors.Wrap(err, "could not write download locally")
+			}
 
-				hash := magnet.InfoHash.String()
+			return tempFile.Name(), nil
+		}
 
-				wg := sync.WaitGroup{}
+		var results []*addResult
 
-				wg.Add(1)
+		// fail records the failed item. Text output stops at the first failure, json output continues with the next item.
+		fail := func(r *addResult, err error) error {
+			r.Status = AddStatusFailed
+			r.Reason = err.Error()
 
-				go func() {
-					defer wg.Done()
-					if err := checkTrackerStatus(ctx, qb, removeStalled, hash); err != nil {
-						log.Fatalf("could not get tracker status for torrent: %q\n", err)
-					}
-				}()
+			if jsonOutput {
+				log.Printf("%q\n", err)
+				return nil
+			}
+
+			return err
+		}
+
+		var items []addInput
 
-				wg.Wait()
+		inputs := []string{filePath}
+		if filePath == "-" {
+			data, lines, err := readStdinTorrents(stdin)
+			if err != nil {
+				return err
 			}
 
-			if paused && recheck {
-				magnet, err := metainfo.ParseMagnetUri(filePath)
-				if err == nil {
-					hash = magnet.InfoHash.String()
-					if err := qb.RecheckCtx(ctx, []string{hash}); err != nil {
-						log.Printf("could not recheck torrent: %s err: %q\n", hash, err)
-					} else {
-						log.Printf("rechecked torrent: %s\n", hash)
-					}
+			if data != nil {
+				name, err := writeTemp(data)
+				if err != nil {
+					return err
 				}
+
+				items = append(items, addInput{file: name, input: "-"})
 			}
 
-			log.Printf("successfully added torrent from magnet: %s %s\n", filePath, hash)
-			return nil
-		} else {
-			var files []string
-			var err error
+			inputs = lines
+		}
 
-			var tempFile *os.File
+		var dl *downloader.Downloader
 
-			defer func() {
-				if tempFile != nil {
-					os.Remove(tempFile.Name())
-					tempFile.Close()
-				}
-			}()
+		for _, input := range inputs {
+			isURL := strings.HasPrefix(input, "https://") || strings.HasPrefix(input, "http://")
 
-			if strings.HasPrefix(filePath, "https://") || strings.HasPrefix(filePath, "http://") {
-				tempFile, err = os.CreateTemp("", "qbt-torrent-dl")
-				if err != nil {
-					return errors.Wrap(err, "could not create tmp file")
+			switch {
+			case strings.HasPrefix(input, "magnet:"):
+				items = append(items, addInput{file: input, input: input})
+
+			case isURL && urlDirect:
+				items = append(items, addInput{file: input, input: input, direct: true})
+
+			case isURL:
+				if dl == nil {
+					dl = downloader.New(downloader.OptionsFromConfig(config.Download))
 				}
 
-				response, err := http.Get(filePath)
+				res, err := dl.Download(ctx, input)
 				if err != nil {
-					return errors.Wrapf(err, "could not download file: %s", filePath)
+					r := &addResult{Input: input}
+					results = append(results, r)
+
+					if err := fail(r, errors.Wrapf(err, "could not download file: %s", input)); err != nil {
+						return err
+					}
+					continue
 				}
 
-				defer response.Body.Close()
+				if res.Magnet != "" {
+					log.Printf("url redirected to magnet: %s\n", res.Magnet)
 
-				if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusCreated && response.StatusCode != http.StatusNoContent {
-					return errors.Errorf("unexpected status: %d", response.StatusCode)
+					items = append(items, addInput{file: res.Magnet, input: input})
+					continue
 				}
 
-				_, err = io.Copy(tempFile, response.Body)
+				name, err := writeTemp(res.Data)
 				if err != nil {
-					return errors.Wrap(err, "could not write download locally")
+					return err
 				}
 
-				files = []string{tempFile.Name()}
-			} else if IsGlobPattern(filePath) {
-				files, err = filepath.Glob(filePath)
+				items = append(items, addInput{file: name, input: input})
+
+			case IsGlobPattern(input):
+				matches, err := filepath.Glob(input)
 				if err != nil {
-					return errors.Wrapf(err, "could not find files matching: %s", filePath)
+					return errors.Wrapf(err, "could not find files matching: %s", input)
 				}
-			} else {
-				_, err := os.Lstat(filePath)
-				if err != nil {
-					return errors.Wrapf(err, "could not find file: %s", filePath)
+
+				for _, match := range matches {
+					items = append(items, addInput{file: match, input: match})
 				}
 
-				files = []string{filePath}
+			default:
+				if _, err := os.Lstat(input); err != nil {
+					r := &addResult{Input: input}
+					results = append(results, r)
+
+					if err := fail(r, errors.Wrapf(err, "could not find file: %s", input)); err != nil {
+						return err
+					}
+					continue
+				}
+
+				items = append(items, addInput{file: input, input: input})
 			}
+		}
 
-			if len(files) == 0 {
-				log.Printf("found 0 torrents matching %s\n", filePath)
-				return nil
+		if len(items) == 0 && len(results) == 0 {
+			log.Printf("found 0 torrents matching %s\n", filePath)
+
+			if jsonOutput {
+				return printAddResults(nil)
 			}
 
-			log.Printf("found (%d) torrent(s) to add\n", len(files))
+			return nil
+		}
+
+		if rename != "" && len(items) > 1 {
+			return errors.New("--rename can only be used when adding a single torrent")
+		}
+
+		if !strings.HasPrefix(filePath, "magnet:") {
+			log.Printf("found (%d) torrent(s) to add\n", len(items))
+		}
+
+		var supervisor *reannounce.Supervisor
+		reannounced := map[string]*addResult{}
+
+		if config.Reannounce.Enabled && !paused && !dry {
+			opts := reannounce.OptionsFromConfig(config.Reannounce)
+			opts.RemoveStalled = removeStalled
+
+			supervisor = reannounce.New(ctx, qb, opts)
+		}
 
-			wg := sync.WaitGroup{}
+		success := 0
+		skipped := 0
+		sleepNext := false
+		var added []string
+		for _, item := range items {
+			if sleepNext {
+				log.Printf("sleeping %v before adding next torrent...\n", sleep)
 
-			success := 0
-			for _, file := range files {
+				time.Sleep(sleep)
+
+				sleepNext = false
+			}
+
+			r := &addResult{Input: item.input}
+			results = append(results, r)
+
+			if item.direct {
+				// qBittorrent downloads the file, so the hash is unknown here
 				if dry {
-					log.Printf("dry-run: torrent %s successfully added!\n", file)
+					log.Printf("dry-run: successfully added torrent from url %s!\n", item.file)
+
+					r.Status = AddStatusSkipped
+					r.Reason = "dry-run"
 
 					continue
 				}
 
-				// set savePath again
-				options["savepath"] = savePath
-
-				if _, err := qb.AddTorrentFromFileCtx(ctx, file, options); err != nil {
-					return errors.Wrapf(err, "could not add torrent: %s", file)
+				if _, err := qb.AddTorrentFromUrlCtx(ctx, item.file, options); err != nil {
+					if err := fail(r, errors.Wrapf(err, "adding torrent %s failed", item.file)); err != nil {
+						return err
+					}
+					continue
 				}
 
-				// Get meta info from file to find out the hash for later use
-				t, err := metainfo.LoadFromFile(file)
+				r.Status = AddStatusAdded
+				success++
+				sleepNext = true
+
+				log.Printf("successfully added torrent from url: %s\n", item.file)
+
+				continue
+			}
+
+			file := item.file
+			isMagnet := strings.HasPrefix(file, "magnet:")
+
+			// Get meta info from file to find out the hash for later use
+			var c addCandidate
+			if isMagnet {
+				c, err = candidateFromMagnet(file)
 				if err != nil {
-					log.Printf("could not open file: %s", file)
+					if err := fail(r, errors.Wrapf(err, "could not parse magnet URI: %s", file)); err != nil {
+						return err
+					}
 					continue
 				}
-
-				hash := t.HashInfoBytes().String()
-
-				if paused && recheck {
-					if err := qb.RecheckCtx(ctx, []string{hash}); err != nil {
-						log.Printf("could not recheck torrent: %s err: %q\n", hash, err)
-					} else {
-						log.Printf("rechecked torrent: %s\n", hash)
+			} else {
+				c, err = candidateFromFile(file)
+				if err != nil {
+					if err := fail(r, errors.Wrapf(err, "could not parse torrent file: %s", item.input)); err != nil {
+						return err
 					}
+					continue
 				}
+			}
 
-				// some trackers are bugged or slow, so we need to re-announce the torrent until it works
-				if config.Reannounce.Enabled && !paused {
-					wg.Add(1)
+			c.Source = r.Input
+			r.setCandidate(c)
 
-					go func() {
-						defer wg.Done()
-						if err := checkTrackerStatus(ctx, qb, removeStalled, hash); err != nil {
-							log.Printf("could not get tracker status for torrent: %s err: %q\n", hash, err)
-						}
-					}()
+			duplicate, err := checkDuplicate(c, r)
+			if err != nil {
+				if err := fail(r, err); err != nil {
+					return err
 				}
+				continue
+			}
 
-				success++
+			if duplicate {
+				skipped++
+				continue
+			}
 
-				log.Printf("successfully added torrent: %s\n", hash)
+			if guard != nil && !guard.allow(c.Size) {
+				log.Printf("skip adding %s: not enough free space\n", r.Input)
 
-				if len(files) > 1 {
-					log.Printf("sleeping %v before adding next torrent...\n", sleep)
+				r.Status = AddStatusSkipped
+				r.Reason = "not enough free space"
+				skipped++
+
+				continue
+			}
+
+			if dry {
+				log.Printf("dry-run: torrent %s successfully added!\n", r.Input)
 
-					time.Sleep(sleep)
+				r.Status = AddStatusSkipped
+				r.Reason = "dry-run"
 
+				continue
+			}
+
+			holdForFiles := holdForFileSelection(selectFiles, paused, options)
+
+			addOptions := options
+			if holdForFiles {
+				addOptions = fileSelectionOptions(options, isMagnet)
+			}
+
+			if isMagnet {
+				if _, err := qb.AddTorrentFromUrlCtx(ctx, file, addOptions); err != nil {
+					if err := fail(r, errors.Wrapf(err, "adding torrent %s failed", file)); err != nil {
+						return err
+					}
 					continue
 				}
+			} else {
+				// set savePath again
+				addOptions["savepath"] = savePath
+
+				if _, err := qb.AddTorrentFromFileCtx(ctx, file, addOptions); err != nil {
+					if err := fail(r, errors.Wrapf(err, "could not add torrent: %s", file)); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+
+			r.Status = AddStatusAdded
+
+			// qBittorrent uses the truncated v2 hash as id for v2-only torrents
+			hash := c.Hashes.ID()
+
+			// some trackers are bugged or slow, so we need to re-announce the torrent until it works
+			if supervisor != nil {
+				supervisor.Add(hash, c.Name)
+				reannounced[hash] = r
+			}
+
+			if selectFiles {
+				// the torrent was added stopped so excluded files are not downloaded before the selection is applied
+				if err := applyFileSelection(ctx, qb, hash, c.Files, includeFiles, excludeFiles, metadataTimeout); err != nil {
+					log.Printf("could not apply file selection, torrent left stopped: %q\n", err)
+				} else if holdForFiles {
+					if err := qb.ResumeCtx(ctx, []string{hash}); err != nil {
+						log.Printf("could not resume torrent: %s err: %q\n", hash, err)
+					}
+				}
+			}
+
+			if paused && recheck {
+				if err := qb.RecheckCtx(ctx, []string{hash}); err != nil {
+					log.Printf("could not recheck torrent: %s err: %q\n", hash, err)
+				} else {
+					log.Printf("rechecked torrent: %s\n", hash)
+				}
+			}
+
+			success++
+			sleepNext = true
+			added = append(added, hash)
 
+			if isMagnet {
+				log.Printf("successfully added torrent from magnet: %s %s\n", file, hash)
+			} else {
+				log.Printf("successfully added torrent: %s\n", hash)
 			}
+		}
 
-			wg.Wait()
+		if supervisor != nil {
+			for _, res := range supervisor.Wait() {
+				if r, ok := reannounced[res.Hash]; ok {
+					r.Reannounce = res.Status
+				}
 
+				if res.Error != "" {
+					log.Printf("could not re-announce torrent: %s err: %q\n", res.Hash, res.Error)
+				}
+			}
+		}
+
+		if !strings.HasPrefix(filePath, "magnet:") {
 			log.Printf("successfully added %d torrent(s)\n", success)
 		}
 
+		if skipped > 0 {
+			log.Printf("skipped %d torrent(s)\n", skipped)
+		}
+
+		if jsonOutput {
+			if err := printAddResults(results); err != nil {
+				return err
+			}
+
+			if failed := countFailed(results); failed > 0 {
+				cmd.SilenceUsage = true
+				return errors.Errorf("could not add %d torrent(s)", failed)
+			}
+		}
+
+		if wait {
+			cmd.SilenceUsage = true
+			return waitForTorrents(ctx, qb, added, waitFor, waitTimeout, waitInterval)
+		}
+
 		return nil
 	}
 
 	return command
 }
 
-// IsGlobPattern reports whether path contains any of the magic characters
-// recognized by Match.
-func IsGlobPattern(path string) bool {
-	magicChars := `*?[`
-	if runtime.GOOS != "windows" {
-		magicChars = `*?[\`
-	}
-	return strings.ContainsAny(path, magicChars)
+// addInput is a torrent file or magnet to add and the input it came from
+type addInput struct {
+	file   string
+	input  string
+	direct bool
 }
 
-func checkTrackerStatus(ctx context.Context, qb *qbittorrent.Client, removeStalled bool, hash string) error {
-	announceOK := false
-	attempts := 0
-
-	time.Sleep(time.Duration(config.Reannounce.Interval) * time.Millisecond)
+// presetBool returns the preset value when it is set, or else the [add] default
+func presetBool(preset *bool, def bool) bool {
+	if preset != nil {
+		return *preset
+	}
 
-	log.Printf("starting reannounce for %s\n", hash)
+	return def
+}
 
-	for attempts < config.Reannounce.Attempts {
-		log.Printf("[%d/%d] reannounce attempt for %s\n", attempts, config.Reannounce.Attempts, hash)
+// findAddPreset returns the preset by name. Names are matched case-insensitively as the config lowercases them.
+func findAddPreset(presets map[string]domain.AddPreset, name string) (domain.AddPreset, error) {
+	var names []string
 
-		trackers, err := qb.GetTorrentTrackersCtx(ctx, hash)
-		if err != nil {
-			log.Fatalf("could not get trackers of torrent: %v %v", hash, err)
+	for key, p := range presets {
+		if strings.EqualFold(key, name) {
+			return p, nil
 		}
 
-		// check if status not working or something else
-		_, working := findTrackerStatus(trackers, 2)
-		if working {
-			log.Printf("[%d/%d] reannounce found working tracker for %s\n", attempts, config.Reannounce.Attempts, hash)
-			announceOK = true
-			break
-		}
+		names = append(names, key)
+	}
 
-		if err := qb.ReAnnounceTorrentsCtx(ctx, []string{hash}); err != nil {
-			return err
-		}
+	if len(names) == 0 {
+		return domain.AddPreset{}, errors.Errorf("preset not found: %s. No presets in config", name)
+	}
 
-		time.Sleep(time.Duration(config.Reannounce.Interval) * time.Millisecond)
-		attempts++
-		continue
+	sort.Strings(names)
+
+	return domain.AddPreset{}, errors.Errorf("preset not found: %s. Available presets: %s", name, strings.Join(names, ", "))
+}
+
+// checkAddRules reports whether the max active downloads rule allows adding another torrent.
+// A max of 0 does not limit active downloads.
+func checkAddRules(ctx context.Context, qb *qbittorrent.Client) (bool, error) {
+	if config.Rules.MaxActiveDownloads <= 0 {
+		return true, nil
 	}
 
-	if !announceOK {
-		if removeStalled {
-			log.Println("Announce not ok, deleting torrent")
+	activeDownloads, err := qb.GetTorrentsCtx(ctx, qbittorrent.TorrentFilterOptions{Filter: qbittorrent.TorrentFilterDownloading})
+	if err != nil {
+		return false, errors.Wrap(err, "could not fetch torrents")
+	}
 
-			if err := qb.DeleteTorrentsCtx(ctx, []string{hash}, false); err != nil {
-				return err
-			}
-		}
+	if config.Rules.MaxActiveDownloads > 0 && len(activeDownloads) >= config.Rules.MaxActiveDownloads {
+		log.Printf("max active downloads of (%d) reached, skip adding\n", config.Rules.MaxActiveDownloads)
+		return false, nil
 	}
 
-	return nil
+	return true, nil
 }
 
-// Check if status not working or something else
-// https://github.com/qbittorrent/qBittorrent/wiki/WebUI-API-(qBittorrent-4.1)#get-torrent-trackers
-//
-//	0 Tracker is disabled (used for DHT, PeX, and LSD)
-//	1 Tracker has not been contacted yet
-//	2 Tracker has been contacted and is working
-//	3 Tracker is updating
-//	4 Tracker has been contacted, but it is not working (or doesn't send proper replies)
-func findTrackerStatus(slice []qbittorrent.TorrentTracker, val int) (int, bool) {
-	for i, item := range slice {
-		if int(item.Status) == val {
-			return i, true
-		}
+// IsGlobPattern reports whether path contains any of the magic characters
+// recognized by Match.
+func IsGlobPattern(path string) bool {
+	magicChars := `*?[`
+	if runtime.GOOS != "windows" {
+		magicChars = `*?[\`
 	}
-	return -1, false
+	return strings.ContainsAny(path, magicChars)
 }
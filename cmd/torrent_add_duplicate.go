@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/ludviglundgren/qbittorrent-cli/pkg/torrent"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/autobrr/go-qbittorrent"
+	"github.com/pkg/errors"
+)
+
+const (
+	OnDuplicateSkip        = "skip"
+	OnDuplicateAddTrackers = "add-trackers"
+	OnDuplicateMergeTags   = "merge-tags"
+	OnDuplicateFail        = "fail"
+)
+
+// addCandidate is a torrent file or magnet about to be added
+type addCandidate struct {
+	Source   string
+	Hashes   torrent.InfoHashes
+	Name     string
+	Size     int64
+	Trackers []string
+	// Files are the files of a torrent file in the order of qBittorrent, empty for magnets
+	Files qbittorrent.TorrentFiles
+}
+
+func candidateFromMagnet(uri string) (addCandidate, error) {
+	m, err := metainfo.ParseMagnetV2Uri(uri)
+	if err != nil {
+		return addCandidate{}, err
+	}
+
+	hashes, err := torrent.HashesFromMagnet(uri)
+	if err != nil {
+		return addCandidate{}, err
+	}
+
+	return addCandidate{
+		Source:   uri,
+		Hashes:   hashes,
+		Name:     m.DisplayName,
+		Trackers: m.Trackers,
+	}, nil
+}
+
+func candidateFromFile(path string) (addCandidate, error) {
+	mi, err := metainfo.LoadFromFile(path)
+	if err != nil {
+		return addCandidate{}, err
+	}
+
+	hashes, err := torrent.HashesFromMetaInfo(mi)
+	if err != nil {
+		return addCandidate{}, err
+	}
+
+	info, err := mi.UnmarshalInfo()
+	if err != nil {
+		return addCandidate{}, err
+	}
+
+	c := addCandidate{
+		Source: path,
+		Hashes: hashes,
+		Name:   info.BestName(),
+		Size:   info.TotalLength(),
+	}
+
+	for _, tier := range mi.UpvertedAnnounceList() {
+		c.Trackers = append(c.Trackers, tier...)
+	}
+
+	for _, f := range info.UpvertedFiles() {
+		// qBittorrent does not list padding files
+		if strings.Contains(f.Attr, "p") {
+			continue
+		}
+
+		name := c.Name
+		if p := f.BestPath(); len(p) > 0 {
+			name = c.Name + "/" + strings.Join(p, "/")
+		}
+
+		c.Files = append(c.Files, qbittorrent.TorrentFile{Index: len(c.Files), Name: name, Size: f.Length})
+	}
+
+	return c, nil
+}
+
+// duplicateIndex indexes the torrents in the client by hash and by name and size
+type duplicateIndex struct {
+	byHash    map[string]qbittorrent.Torrent
+	byContent map[string]qbittorrent.Torrent
+}
+
+func newDuplicateIndex(torrents []qbittorrent.Torrent) *duplicateIndex {
+	d := &duplicateIndex{
+		byHash:    make(map[string]qbittorrent.Torrent, len(torrents)),
+		byContent: make(map[string]qbittorrent.Torrent, len(torrents)),
+	}
+
+	for _, t := range torrents {
+		for _, key := range torrentHashKeys(t) {
+			d.byHash[key] = t
+		}
+
+		d.byContent[contentKey(t.Name, t.TotalSize)] = t
+	}
+
+	return d
+}
+
+// find returns the torrent in the client with any of the hashes of c
+func (d *duplicateIndex) find(c addCandidate) (qbittorrent.Torrent, bool) {
+	for _, h := range []string{c.Hashes.V1, c.Hashes.V2, torrent.TruncateV2(c.Hashes.V2)} {
+		if h == "" {
+			continue
+		}
+
+		if t, ok := d.byHash[h]; ok {
+			return t, true
+		}
+	}
+
+	return qbittorrent.Torrent{}, false
+}
+
+// findContent returns a torrent in the client with the same name and size as c.
+// Magnets have no size so they never match.
+func (d *duplicateIndex) findContent(c addCandidate) (qbittorrent.Torrent, bool) {
+	if c.Name == "" || c.Size == 0 {
+		return qbittorrent.Torrent{}, false
+	}
+
+	t, ok := d.byContent[contentKey(c.Name, c.Size)]
+
+	return t, ok
+}
+
+func contentKey(name string, size int64) string {
+	return name + "/" + strconv.FormatInt(size, 10)
+}
+
+func validateOnDuplicate(action string) error {
+	switch action {
+	case OnDuplicateSkip, OnDuplicateAddTrackers, OnDuplicateMergeTags, OnDuplicateFail:
+		return nil
+	}
+
+	return errors.Errorf("invalid --on-duplicate: %s. Possible values: skip, add-trackers, merge-tags, fail", action)
+}
+
+// handleDuplicate runs the on-duplicate action for a candidate already present in the client
+func handleDuplicate(ctx context.Context, qb *qbittorrent.Client, action string, c addCandidate, existing qbittorrent.Torrent, tags []string, dry bool) error {
+	log.Printf("torrent already present: %s %s (%s)\n", c.Source, existing.Hash, existing.Name)
+
+	switch action {
+	case OnDuplicateFail:
+		return errors.Errorf("torrent already present: %s %s", c.Source, existing.Hash)
+
+	case OnDuplicateAddTrackers:
+		if len(c.Trackers) == 0 {
+			log.Printf("no trackers to add to: %s\n", existing.Hash)
+			return nil
+		}
+
+		if dry {
+			log.Printf("dry-run: add (%d) tracker(s) to: %s\n", len(c.Trackers), existing.Hash)
+			return nil
+		}
+
+		if err := qb.AddTrackersCtx(ctx, existing.Hash, strings.Join(c.Trackers, "\n")); err != nil {
+			return errors.Wrapf(err, "could not add trackers to: %s", existing.Hash)
+		}
+
+		log.Printf("added (%d) tracker(s) to: %s\n", len(c.Trackers), existing.Hash)
+
+	case OnDuplicateMergeTags:
+		if len(tags) == 0 {
+			log.Printf("no tags to merge into: %s\n", existing.Hash)
+			return nil
+		}
+
+		if dry {
+			log.Printf("dry-run: add tags %s to: %s\n", strings.Join(tags, ","), existing.Hash)
+			return nil
+		}
+
+		if err := qb.AddTagsCtx(ctx, []string{existing.Hash}, strings.Join(tags, ",")); err != nil {
+			return errors.Wrapf(err, "could not add tags to: %s", existing.Hash)
+		}
+
+		log.Printf("added tags %s to: %s\n", strings.Join(tags, ","), existing.Hash)
+
+	default:
+		log.Printf("skip adding: %s\n", c.Source)
+	}
+
+	return nil
+}
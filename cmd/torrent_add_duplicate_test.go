@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/ludviglundgren/qbittorrent-cli/pkg/torrent"
+
+	"github.com/autobrr/go-qbittorrent"
+)
+
+func Test_duplicateIndex(t *testing.T) {
+	v2 := "8d2a3b1f0ee2bd1b6d6f7c8e9a0b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b"
+
+	index := newDuplicateIndex([]qbittorrent.Torrent{
+		{Hash: "5ba4939a00a9b21629a0ad7d376898b768d997a3", InfohashV1: "5ba4939a00a9b21629a0ad7d376898b768d997a3", Name: "Movie.2020", TotalSize: 1000},
+		{Hash: v2[:40], InfohashV2: v2, Name: "Show.S01", TotalSize: 2000},
+	})
+
+	tests := []struct {
+		name        string
+		candidate   addCandidate
+		wantHash    string
+		wantContent string
+	}{
+		{
+			name:      "v1 hash",
+			candidate: addCandidate{Hashes: torrent.InfoHashes{V1: "5ba4939a00a9b21629a0ad7d376898b768d997a3"}},
+			wantHash:  "5ba4939a00a9b21629a0ad7d376898b768d997a3",
+		},
+		{
+			name:      "v2 hash",
+			candidate: addCandidate{Hashes: torrent.InfoHashes{V2: v2}},
+			wantHash:  v2[:40],
+		},
+		{
+			name:        "same content different hash",
+			candidate:   addCandidate{Hashes: torrent.InfoHashes{V1: "3eced34cd948e7ea92f31ded3e0fd734274fee4a"}, Name: "Movie.2020", Size: 1000},
+			wantContent: "5ba4939a00a9b21629a0ad7d376898b768d997a3",
+		},
+		{
+			name:      "different size",
+			candidate: addCandidate{Hashes: torrent.InfoHashes{V1: "3eced34cd948e7ea92f31ded3e0fd734274fee4a"}, Name: "Movie.2020", Size: 1001},
+		},
+		{
+			name:      "magnet without size",
+			candidate: addCandidate{Hashes: torrent.InfoHashes{V1: "3eced34cd948e7ea92f31ded3e0fd734274fee4a"}, Name: "Movie.2020"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := index.find(tt.candidate)
+			if ok != (tt.wantHash != "") || got.Hash != tt.wantHash {
+				t.Errorf("find() = %v %v, want %v", got.Hash, ok, tt.wantHash)
+			}
+
+			got, ok = index.findContent(tt.candidate)
+			if ok != (tt.wantContent != "") || got.Hash != tt.wantContent {
+				t.Errorf("findContent() = %v %v, want %v", got.Hash, ok, tt.wantContent)
+			}
+		})
+	}
+}
+
+func Test_candidateFromMagnet(t *testing.T) {
+	c, err := candidateFromMagnet("magnet:?xt=urn:btih:5dee65101db281ac9c46344cd6b175cdcad53426&dn=download&tr=https%3A%2F%2Ftracker.example.org%2Fannounce")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Hashes.ID() != "5dee65101db281ac9c46344cd6b175cdcad53426" || c.Name != "download" || len(c.Trackers) != 1 || c.Trackers[0] != "https://tracker.example.org/announce" {
+		t.Errorf("candidateFromMagnet() = %+v", c)
+	}
+}
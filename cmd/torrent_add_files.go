@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/autobrr/go-qbittorrent"
+	"github.com/pkg/errors"
+)
+
+// matchFilePattern reports whether the torrent file name matches the glob pattern, case-insensitive.
+// Patterns ending with / match a directory anywhere in the path, like Sample/.
+// Patterns with a / match the end of the path, like Sample/*.mkv. Other patterns match the base name, like *.nfo.
+func matchFilePattern(pattern, name string) bool {
+	pattern = strings.ToLower(strings.TrimPrefix(pattern, "/"))
+	name = strings.ToLower(strings.ReplaceAll(name, "\\", "/"))
+
+	parts := strings.Split(name, "/")
+
+	if strings.HasSuffix(pattern, "/") {
+		dir := strings.TrimSuffix(pattern, "/")
+
+		for _, part := range parts[:len(parts)-1] {
+			if ok, _ := path.Match(dir, part); ok {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, parts[len(parts)-1])
+		return ok
+	}
+
+	for i := range parts {
+		if ok, _ := path.Match(pattern, strings.Join(parts[i:], "/")); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// filesToSkip returns the indexes of files not matching any include pattern or matching an exclude pattern
+func filesToSkip(files qbittorrent.TorrentFiles, include, exclude []string) []int {
+	var skip []int
+
+	for _, f := range files {
+		included := len(include) == 0
+		for _, pattern := range include {
+			if matchFilePattern(pattern, f.Name) {
+				included = true
+				break
+			}
+		}
+
+		excluded := false
+		for _, pattern := range exclude {
+			if matchFilePattern(pattern, f.Name) {
+				excluded = true
+				break
+			}
+		}
+
+		if !included || excluded {
+			skip = append(skip, f.Index)
+		}
+	}
+
+	return skip
+}
+
+// waitForFiles polls the file list of a torrent until the metadata is received or timeout
+func waitForFiles(ctx context.Context, qb *qbittorrent.Client, hash string, timeout time.Duration) (qbittorrent.TorrentFiles, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		// the torrent might not be in the client yet right after adding, so errors are retried
+		files, err := qb.GetFilesInformationCtx(ctx, hash)
+		if err == nil && files != nil && len(*files) > 0 {
+			return *files, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return nil, errors.Wrapf(err, "timed out waiting for metadata: %s", hash)
+			}
+
+			return nil, errors.Errorf("timed out waiting for metadata: %s", hash)
+		case <-ticker.C:
+		}
+	}
+}
+
+// applyFileSelection sets priority 0 (do not download) on files excluded by the patterns. The files of torrent files
+// are known before adding, for magnets they are polled until the metadata is received.
+func applyFileSelection(ctx context.Context, qb *qbittorrent.Client, hash string, files qbittorrent.TorrentFiles, include, exclude []string, timeout time.Duration) error {
+	if len(files) == 0 {
+		var err error
+		files, err = waitForFiles(ctx, qb, hash, timeout)
+		if err != nil {
+			return err
+		}
+	}
+
+	skip := filesToSkip(files, include, exclude)
+	if len(skip) == 0 {
+		log.Printf("all (%d) files selected: %s\n", len(files), hash)
+		return nil
+	}
+
+	if len(skip) == len(files) {
+		return errors.Errorf("file patterns exclude all (%d) files, not changing priorities: %s", len(files), hash)
+	}
+
+	ids := make([]string, 0, len(skip))
+	for _, index := range skip {
+		ids = append(ids, strconv.Itoa(index))
+	}
+
+	if err := setFilePriority(ctx, qb, hash, strings.Join(ids, "|"), 0, timeout); err != nil {
+		return errors.Wrapf(err, "could not set file priorities: %s", hash)
+	}
+
+	log.Printf("skipped (%d/%d) files: %s\n", len(skip), len(files), hash)
+
+	return nil
+}
+
+// setFilePriority sets the priority of the files and retries until timeout while the torrent is not in the client yet
+func setFilePriority(ctx context.Context, qb *qbittorrent.Client, hash, ids string, priority int, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		err := qb.SetFilePriorityCtx(ctx, hash, ids, priority)
+		if err == nil {
+			return nil
+		}
+
+		if !errors.Is(err, qbittorrent.ErrTorrentNotFound) && !errors.Is(err, qbittorrent.ErrTorrentMetadataNotDownloadedYet) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-ticker.C:
+		}
+	}
+}
+
+// holdForFileSelection reports whether the torrent is added stopped for the file selection and resumed after it.
+// Torrents that stay stopped anyway, added paused or with a stop condition, are not held and never resumed.
+func holdForFileSelection(selectFiles, paused bool, options map[string]string) bool {
+	if !selectFiles || paused {
+		return false
+	}
+
+	switch options["stop_condition"] {
+	case string(QbitStopConditionMetadataReceived), string(QbitStopConditionFilesChecked):
+		return false
+	}
+
+	return true
+}
+
+// fileSelectionOptions returns the add options that hold the torrent until the file selection is applied.
+// Torrent files are added stopped, magnets stop once the metadata is received as stopped magnets get no metadata.
+func fileSelectionOptions(options map[string]string, isMagnet bool) map[string]string {
+	opts := make(map[string]string, len(options)+2)
+	for k, v := range options {
+		opts[k] = v
+	}
+
+	if !isMagnet {
+		opts["paused"] = "true"
+		opts["stopped"] = "true"
+		return opts
+	}
+
+	if opts["stop_condition"] == "" || opts["stop_condition"] == string(QBitStopConditionNone) {
+		opts["stop_condition"] = string(QbitStopConditionMetadataReceived)
+	}
+
+	return opts
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ludviglundgren/qbittorrent-cli/internal/reannounce"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	AddStatusAdded   = "added"
+	AddStatusSkipped = "skipped"
+	AddStatusFailed  = "failed"
+)
+
+// addResult is the json result of torrent add for one torrent
+type addResult struct {
+	Input       string            `json:"input"`
+	Hash        string            `json:"hash,omitempty"`
+	InfohashV1  string            `json:"infohash_v1,omitempty"`
+	InfohashV2  string            `json:"infohash_v2,omitempty"`
+	Name        string            `json:"name,omitempty"`
+	Size        int64             `json:"size,omitempty"`
+	Status      string            `json:"status"`
+	Reason      string            `json:"reason,omitempty"`
+	Existing    string            `json:"existing,omitempty"`
+	CrossSeedOf string            `json:"cross_seed_of,omitempty"`
+	Reannounce  reannounce.Status `json:"reannounce,omitempty"`
+}
+
+func (r *addResult) setCandidate(c addCandidate) {
+	r.Hash = c.Hashes.ID()
+	r.InfohashV1 = c.Hashes.V1
+	r.InfohashV2 = c.Hashes.V2
+	r.Name = c.Name
+	r.Size = c.Size
+}
+
+func printAddResults(results []*addResult) error {
+	if results == nil {
+		results = []*addResult{}
+	}
+
+	res, err := json.Marshal(results)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal results to json")
+	}
+
+	fmt.Println(string(res))
+
+	return nil
+}
+
+func countFailed(results []*addResult) int {
+	failed := 0
+	for _, r := range results {
+		if r.Status == AddStatusFailed {
+			failed++
+		}
+	}
+
+	return failed
+}
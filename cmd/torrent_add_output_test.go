@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ludviglundgren/qbittorrent-cli/internal/reannounce"
+	"github.com/ludviglundgren/qbittorrent-cli/pkg/torrent"
+)
+
+func Test_addResult_json(t *testing.T) {
+	tests := []struct {
+		name   string
+		result addResult
+		want   string
+	}{
+		{
+			name:   "failed without candidate",
+			result: addResult{Input: "file.torrent", Status: AddStatusFailed, Reason: "could not parse torrent file"},
+			want:   `{"input":"file.torrent","status":"failed","reason":"could not parse torrent file"}`,
+		},
+		{
+			name: "added",
+			result: func() addResult {
+				r := addResult{Input: "file.torrent", Status: AddStatusAdded, Reannounce: reannounce.StatusOK}
+				r.setCandidate(addCandidate{Hashes: torrent.InfoHashes{V1: "5ba4939a00a9b21629a0ad7d376898b768d997a3"}, Name: "name", Size: 10})
+				return r
+			}(),
+			want: `{"input":"file.torrent","hash":"5ba4939a00a9b21629a0ad7d376898b768d997a3","infohash_v1":"5ba4939a00a9b21629a0ad7d376898b768d997a3","name":"name","size":10,"status":"added","reannounce":"ok"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.result)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("json = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_countFailed(t *testing.T) {
+	results := []*addResult{{Status: AddStatusAdded}, {Status: AddStatusFailed}, {Status: AddStatusSkipped}, {Status: AddStatusFailed}}
+
+	if got := countFailed(results); got != 2 {
+		t.Errorf("countFailed() = %v, want 2", got)
+	}
+}
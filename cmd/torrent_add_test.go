@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ludviglundgren/qbittorrent-cli/internal/domain"
+
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/autobrr/go-qbittorrent"
+)
+
+func Test_findAddPreset(t *testing.T) {
+	presets := map[string]domain.AddPreset{
+		"movies": {Category: "movies"},
+		"tv":     {Category: "tv"},
+	}
+
+	tests := []struct {
+		name    string
+		presets map[string]domain.AddPreset
+		preset  string
+		want    string
+		wantErr string
+	}{
+		{name: "found", presets: presets, preset: "tv", want: "tv"},
+		{name: "case-insensitive", presets: presets, preset: "Movies", want: "movies"},
+		{name: "not found", presets: presets, preset: "music", wantErr: "preset not found: music. Available presets: movies, tv"},
+		{name: "no presets", presets: nil, preset: "music", wantErr: "preset not found: music. No presets in config"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findAddPreset(tt.presets, tt.preset)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("findAddPreset() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("findAddPreset() unexpected error = %v", err)
+			}
+			if got.Category != tt.want {
+				t.Errorf("findAddPreset() = %v, want %v", got.Category, tt.want)
+			}
+		})
+	}
+}
+
+func Test_matchFilePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{pattern: "*.nfo", name: "Movie.2020/Movie.2020.nfo", want: true},
+		{pattern: "*.NFO", name: "movie.nfo", want: true},
+		{pattern: "*.nfo", name: "Movie.2020/Movie.2020.mkv", want: false},
+		{pattern: "Sample/", name: "Movie.2020/Sample/sample.mkv", want: true},
+		{pattern: "sample/", name: "Movie.2020/Sample/sample.mkv", want: true},
+		{pattern: "Sample/", name: "Movie.2020/Sample.mkv", want: false},
+		{pattern: "Sample/*.mkv", name: "Movie.2020/Sample/sample.mkv", want: true},
+		{pattern: "Movie.2020/*.mkv", name: "Movie.2020/Movie.2020.mkv", want: true},
+		{pattern: "Movie.2020/*.mkv", name: "Movie.2020/Sample/sample.mkv", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.name, func(t *testing.T) {
+			if got := matchFilePattern(tt.pattern, tt.name); got != tt.want {
+				t.Errorf("matchFilePattern() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_filesToSkip(t *testing.T) {
+	files := qbittorrent.TorrentFiles{
+		{Index: 0, Name: "Movie/Movie.mkv"},
+		{Index: 1, Name: "Movie/Movie.nfo"},
+		{Index: 2, Name: "Movie/Sample/sample.mkv"},
+		{Index: 3, Name: "Movie/Subs/English.srt"},
+	}
+
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    []int
+	}{
+		{name: "none", want: nil},
+		{name: "exclude", exclude: []string{"*.nfo", "Sample/"}, want: []int{1, 2}},
+		{name: "include", include: []string{"*.mkv"}, want: []int{1, 3}},
+		{name: "include and exclude", include: []string{"*.mkv"}, exclude: []string{"Sample/"}, want: []int{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filesToSkip(files, tt.include, tt.exclude); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filesToSkip() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_presetBool(t *testing.T) {
+	on, off := true, false
+
+	tests := []struct {
+		name   string
+		preset *bool
+		def    bool
+		want   bool
+	}{
+		{name: "unset uses default on", preset: nil, def: true, want: true},
+		{name: "unset uses default off", preset: nil, def: false, want: false},
+		{name: "preset turns on", preset: &on, def: false, want: true},
+		{name: "preset turns off", preset: &off, def: true, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := presetBool(tt.preset, tt.def); got != tt.want {
+				t.Errorf("presetBool() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_candidateFromFile_files(t *testing.T) {
+	info := metainfo.Info{
+		Name:        "Movie",
+		PieceLength: 16384,
+		Pieces:      make([]byte, 20),
+		Files: []metainfo.FileInfo{
+			{Path: []string{"Movie.mkv"}, Length: 1000},
+			{Path: []string{".pad", "15384"}, Length: 15384, ExtendedFileAttrs: metainfo.ExtendedFileAttrs{Attr: "p"}},
+			{Path: []string{"Sample", "sample.mkv"}, Length: 100},
+		},
+	}
+
+	infoBytes, err := bencode.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := filepath.Join(t.TempDir(), "movie.torrent")
+
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (&metainfo.MetaInfo{InfoBytes: infoBytes}).Write(f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	c, err := candidateFromFile(name)
+	if err != nil {
+		t.Fatalf("candidateFromFile() error = %v", err)
+	}
+
+	want := qbittorrent.TorrentFiles{
+		{Index: 0, Name: "Movie/Movie.mkv", Size: 1000},
+		{Index: 1, Name: "Movie/Sample/sample.mkv", Size: 100},
+	}
+	if !reflect.DeepEqual(c.Files, want) {
+		t.Errorf("candidateFromFile() files = %+v, want %+v", c.Files, want)
+	}
+}
+
+func Test_fileSelectionOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  map[string]string
+		isMagnet bool
+		want     map[string]string
+	}{
+		{name: "torrent file added stopped", options: map[string]string{"category": "movies"}, isMagnet: false, want: map[string]string{"category": "movies", "paused": "true", "stopped": "true"}},
+		{name: "magnet stops on metadata", options: map[string]string{}, isMagnet: true, want: map[string]string{"stop_condition": "MetadataReceived"}},
+		{name: "magnet stop condition none", options: map[string]string{"stop_condition": "None"}, isMagnet: true, want: map[string]string{"stop_condition": "MetadataReceived"}},
+		{name: "magnet keeps files checked", options: map[string]string{"stop_condition": "FilesChecked"}, isMagnet: true, want: map[string]string{"stop_condition": "FilesChecked"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fileSelectionOptions(tt.options, tt.isMagnet)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fileSelectionOptions() = %v, want %v", got, tt.want)
+			}
+			if _, ok := tt.options["stopped"]; ok {
+				t.Errorf("fileSelectionOptions() changed the options")
+			}
+		})
+	}
+}
+
+func Test_holdForFileSelection(t *testing.T) {
+	tests := []struct {
+		name        string
+		selectFiles bool
+		paused      bool
+		options     map[string]string
+		want        bool
+	}{
+		{name: "no file selection", selectFiles: false, options: map[string]string{}, want: false},
+		{name: "file selection", selectFiles: true, options: map[string]string{}, want: true},
+		{name: "stop condition none", selectFiles: true, options: map[string]string{"stop_condition": "None"}, want: true},
+		{name: "paused", selectFiles: true, paused: true, options: map[string]string{}, want: false},
+		{name: "stop condition metadata received", selectFiles: true, options: map[string]string{"stop_condition": "MetadataReceived"}, want: false},
+		{name: "stop condition files checked", selectFiles: true, options: map[string]string{"stop_condition": "FilesChecked"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := holdForFileSelection(tt.selectFiles, tt.paused, tt.options); got != tt.want {
+				t.Errorf("holdForFileSelection() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
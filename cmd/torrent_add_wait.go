@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/autobrr/go-qbittorrent"
+	"github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+)
+
+const (
+	WaitForMetadata   = "metadata"
+	WaitForDownloaded = "downloaded"
+	WaitForSeeding    = "seeding"
+)
+
+// waitMissingPolls is the number of polls in a row a torrent can be missing, e.g. right after it was added, before it is not found.
+// Stopped torrents get as many polls, as a torrent resumed right before waiting can still be listed as stopped.
+const waitMissingPolls = 3
+
+var errTorrentStopped = errors.New("torrent is stopped")
+
+func validateWaitFor(target string) error {
+	switch target {
+	case WaitForMetadata, WaitForDownloaded, WaitForSeeding:
+		return nil
+	}
+
+	return errors.Errorf("invalid --wait-for: %s. Possible values: metadata, downloaded, seeding", target)
+}
+
+// torrentReached reports whether the torrent reached the target state.
+// It returns an error if the torrent is in an error or missing files state,
+// or is stopped before reaching the target as it would never reach it.
+func torrentReached(t qbittorrent.Torrent, target string) (bool, error) {
+	switch t.State {
+	case qbittorrent.TorrentStateError, qbittorrent.TorrentStateMissingFiles:
+		return false, errors.Errorf("torrent %s (%s) is in state: %s", t.Hash, t.Name, t.State)
+	}
+
+	reached := false
+
+	switch target {
+	case WaitForMetadata:
+		reached = t.State != qbittorrent.TorrentStateMetaDl && t.State != "forcedMetaDL" && t.TotalSize > 0
+
+	case WaitForDownloaded:
+		reached = t.Progress >= 1 && t.State != qbittorrent.TorrentStateCheckingUp && t.State != qbittorrent.TorrentStateCheckingResumeData
+
+	case WaitForSeeding:
+		switch t.State {
+		case qbittorrent.TorrentStateUploading, qbittorrent.TorrentStateStalledUp, qbittorrent.TorrentStateForcedUp, qbittorrent.TorrentStateQueuedUp:
+			reached = true
+		}
+	}
+
+	if reached {
+		return true, nil
+	}
+
+	switch t.State {
+	case qbittorrent.TorrentStateStoppedDl, qbittorrent.TorrentStateStoppedUp, qbittorrent.TorrentStatePausedDl, qbittorrent.TorrentStatePausedUp:
+		return false, errors.Wrapf(errTorrentStopped, "torrent %s (%s) will not reach: %s", t.Hash, t.Name, target)
+	}
+
+	return false, nil
+}
+
+// waitForTorrents polls the torrents until all reached the target state, printing progress on change.
+// A timeout of 0 waits forever.
+func waitForTorrents(ctx context.Context, qb *qbittorrent.Client, hashes []string, target string, timeout, interval time.Duration) error {
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	log.Printf("waiting for (%d) torrent(s) to reach: %s\n", len(hashes), target)
+
+	pending := make(map[string]struct{}, len(hashes))
+	for _, hash := range hashes {
+		pending[hash] = struct{}{}
+	}
+
+	lastStatus := map[string]string{}
+	missing := map[string]int{}
+	stopped := map[string]int{}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		torrents, err := qb.GetTorrentsCtx(ctx, qbittorrent.TorrentFilterOptions{Hashes: hashes})
+		if err != nil && ctx.Err() == nil {
+			return errors.Wrap(err, "could not get torrents")
+		}
+
+		found := make(map[string]struct{}, len(torrents))
+
+		for _, t := range torrents {
+			if _, ok := pending[t.Hash]; !ok {
+				continue
+			}
+
+			found[t.Hash] = struct{}{}
+			delete(missing, t.Hash)
+
+			status := humanize.FtoaWithDigits(t.Progress*100, 1) + "% " + string(t.State)
+			if lastStatus[t.Hash] != status {
+				lastStatus[t.Hash] = status
+				log.Printf("%s %s: %s %s/s\n", t.Hash, t.Name, status, humanize.Bytes(uint64(t.DlSpeed)))
+			}
+
+			reached, err := torrentReached(t, target)
+			if errors.Is(err, errTorrentStopped) {
+				stopped[t.Hash]++
+
+				if stopped[t.Hash] >= waitMissingPolls {
+					return err
+				}
+
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			delete(stopped, t.Hash)
+
+			if reached {
+				log.Printf("%s %s reached: %s\n", t.Hash, t.Name, target)
+				delete(pending, t.Hash)
+			}
+		}
+
+		if err == nil {
+			for hash := range pending {
+				if _, ok := found[hash]; ok {
+					continue
+				}
+
+				missing[hash]++
+
+				if missing[hash] >= waitMissingPolls {
+					return errors.Errorf("torrent %s not found", hash)
+				}
+			}
+		}
+
+		if len(pending) == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return errors.Errorf("timed out after %s waiting for (%d) torrent(s) to reach: %s", timeout, len(pending), target)
+			}
+
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
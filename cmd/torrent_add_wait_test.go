@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/autobrr/go-qbittorrent"
+)
+
+func Test_torrentReached(t *testing.T) {
+	tests := []struct {
+		name    string
+		torrent qbittorrent.Torrent
+		target  string
+		want    bool
+		wantErr bool
+	}{
+		{name: "metadata pending", torrent: qbittorrent.Torrent{State: qbittorrent.TorrentStateMetaDl}, target: WaitForMetadata, want: false},
+		{name: "metadata received", torrent: qbittorrent.Torrent{State: qbittorrent.TorrentStateDownloading, TotalSize: 100}, target: WaitForMetadata, want: true},
+		{name: "downloading", torrent: qbittorrent.Torrent{State: qbittorrent.TorrentStateDownloading, Progress: 0.5}, target: WaitForDownloaded, want: false},
+		{name: "checking after download", torrent: qbittorrent.Torrent{State: qbittorrent.TorrentStateCheckingUp, Progress: 1}, target: WaitForDownloaded, want: false},
+		{name: "downloaded and stopped", torrent: qbittorrent.Torrent{State: qbittorrent.TorrentStateStoppedUp, Progress: 1}, target: WaitForDownloaded, want: true},
+		{name: "stopped is not seeding", torrent: qbittorrent.Torrent{State: qbittorrent.TorrentStateStoppedUp, Progress: 1}, target: WaitForSeeding, wantErr: true},
+		{name: "stopped download", torrent: qbittorrent.Torrent{State: qbittorrent.TorrentStateStoppedDl, Progress: 0.5}, target: WaitForDownloaded, wantErr: true},
+		{name: "paused magnet", torrent: qbittorrent.Torrent{State: qbittorrent.TorrentStatePausedDl}, target: WaitForMetadata, wantErr: true},
+		{name: "stopped with metadata", torrent: qbittorrent.Torrent{State: qbittorrent.TorrentStateStoppedDl, TotalSize: 100}, target: WaitForMetadata, want: true},
+		{name: "seeding", torrent: qbittorrent.Torrent{State: qbittorrent.TorrentStateStalledUp, Progress: 1}, target: WaitForSeeding, want: true},
+		{name: "missing files", torrent: qbittorrent.Torrent{State: qbittorrent.TorrentStateMissingFiles}, target: WaitForDownloaded, wantErr: true},
+		{name: "error", torrent: qbittorrent.Torrent{State: qbittorrent.TorrentStateError}, target: WaitForMetadata, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := torrentReached(tt.torrent, tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("torrentReached() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("torrentReached() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_waitForTorrents_notFound(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"hash":"aaaa","name":"A","state":"stalledUP","progress":1}]`))
+	}))
+	defer srv.Close()
+
+	qb := qbittorrent.NewClient(qbittorrent.Config{Host: srv.URL})
+
+	err := waitForTorrents(context.Background(), qb, []string{"aaaa", "bbbb"}, WaitForSeeding, time.Minute, time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "torrent bbbb not found") {
+		t.Errorf("waitForTorrents() error = %v, want torrent bbbb not found", err)
+	}
+}
+
+func Test_waitForTorrents_stopped(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"hash":"aaaa","name":"A","state":"stoppedDL","progress":0.5}]`))
+	}))
+	defer srv.Close()
+
+	qb := qbittorrent.NewClient(qbittorrent.Config{Host: srv.URL})
+
+	err := waitForTorrents(context.Background(), qb, []string{"aaaa"}, WaitForDownloaded, 0, time.Millisecond)
+	if !errors.Is(err, errTorrentStopped) {
+		t.Errorf("waitForTorrents() error = %v, want %v", err, errTorrentStopped)
+	}
+}
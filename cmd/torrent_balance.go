@@ -0,0 +1,440 @@
+package cmd
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ludviglundgren/qbittorrent-cli/internal/config"
+	"github.com/ludviglundgren/qbittorrent-cli/internal/fs"
+
+	"github.com/autobrr/go-qbittorrent"
+	"github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// RunTorrentBalance cmd to balance torrent data across save locations
+func RunTorrentBalance() *cobra.Command {
+	var (
+		dry         bool
+		paths       []string
+		capacities  []string
+		pins        map[string]string
+		targetRatio float64
+		concurrency int
+		timeout     time.Duration
+		interval    time.Duration
+	)
+
+	var command = &cobra.Command{
+		Use:   "balance",
+		Short: "Balance torrent data across save locations",
+		Long: `Balance torrent data across the save locations in --paths, like one path per disk.
+
+The usage of every path is the size of the torrents saved in it. Torrents are planned to move from paths above the
+target fill ratio to paths below it, without filling any path above the target. The default target is the average
+fill ratio, so all paths end up equally full. Subfolders below the path are kept when moving.
+Torrents with the same content, like cross-seeds, are counted once and moved together to the same path.
+
+Capacities are read from the local filesystem of every path, or set with --capacities in the same order as --paths.
+Use --pin to keep a category on one path: its torrents are moved there and never moved elsewhere.
+Only completed torrents are moved. The plan is printed before moving, use --dry-run to only print it.`,
+		Example: `  qbt torrent balance --paths /mnt/d1,/mnt/d2,/mnt/d3 --dry-run
+  qbt torrent balance --paths /mnt/d1,/mnt/d2 --capacities 8TB,12TB --target-ratio 0.8
+  qbt torrent balance --paths /mnt/d1,/mnt/d2 --pin movies=/mnt/d1 --concurrency 2`,
+	}
+
+	command.Flags().BoolVar(&dry, "dry-run", false, "Print the plan without moving anything")
+	command.Flags().StringSliceVar(&paths, "paths", []string{}, "Save locations to balance, comma separated (required)")
+	command.Flags().StringSliceVar(&capacities, "capacities", []string{}, "Capacity of every path like 8TB, in the same order as --paths. Read from the filesystem by default")
+	command.Flags().StringToStringVar(&pins, "pin", map[string]string{}, "Keep the torrents of a category on a path, like movies=/mnt/d1. Can be repeated")
+	command.Flags().Float64Var(&targetRatio, "target-ratio", 0, "Target fill ratio of every path from 0 to 1. 0 uses the average fill ratio")
+	command.Flags().IntVar(&concurrency, "concurrency", 1, "Number of torrents to move at the same time")
+	command.Flags().DurationVar(&timeout, "timeout", 0, "Max time to wait for a batch to move, 0 waits forever")
+	command.Flags().DurationVar(&interval, "interval", 5*time.Second, "Interval to check the move progress")
+
+	command.MarkFlagRequired("paths")
+
+	command.RunE = func(cmd *cobra.Command, args []string) error {
+		if len(paths) < 2 {
+			return errors.New("--paths needs at least 2 paths")
+		}
+
+		if len(capacities) > 0 && len(capacities) != len(paths) {
+			return errors.Errorf("--capacities has %d values for %d paths", len(capacities), len(paths))
+		}
+
+		if targetRatio < 0 || targetRatio > 1 {
+			return errors.Errorf("invalid --target-ratio: %v. Must be between 0 and 1", targetRatio)
+		}
+
+		if concurrency < 1 {
+			return errors.Errorf("invalid --concurrency: %d", concurrency)
+		}
+
+		disks, err := balanceDisks(paths, capacities)
+		if err != nil {
+			return err
+		}
+
+		pinned, err := balancePins(pins, disks)
+		if err != nil {
+			return err
+		}
+
+		config.InitConfig()
+
+		qbtSettings := qbittorrent.Config{
+			Host:      config.Qbit.Addr,
+			APIKey:    config.Qbit.APIKey,
+			Username:  config.Qbit.Login,
+			Password:  config.Qbit.Password,
+			BasicUser: config.Qbit.BasicUser,
+			BasicPass: config.Qbit.BasicPass,
+		}
+
+		qb := qbittorrent.NewClient(qbtSettings)
+
+		ctx := cmd.Context()
+
+		if err := qb.LoginCtx(ctx); err != nil {
+			return errors.Wrap(err, "could not login to qbit")
+		}
+
+		torrents, err := qb.GetTorrentsCtx(ctx, qbittorrent.TorrentFilterOptions{})
+		if err != nil {
+			return errors.Wrap(err, "could not get torrents")
+		}
+
+		addDiskUsage(disks, torrents)
+
+		before := append([]balanceDisk(nil), disks...)
+		moves, total := planBalance(disks, torrents, pinned, targetRatio)
+
+		log.Println("disk usage before -> after:")
+		for i, d := range disks {
+			log.Printf("  %s: %s -> %s of %s (%.1f%% -> %.1f%%)\n", d.Path, humanize.Bytes(uint64(before[i].Used)), humanize.Bytes(uint64(d.Used)),
+				humanize.Bytes(uint64(d.Capacity)), before[i].ratio()*100, d.ratio()*100)
+		}
+
+		if len(moves) == 0 {
+			log.Println("paths are balanced, nothing to move")
+			return nil
+		}
+
+		for i, m := range moves {
+			log.Printf("  [%d/%d] %s %s (%s): %s -> %s\n", i+1, len(moves), m.Hash, m.Name, humanize.Bytes(uint64(m.Size)), m.From, m.To)
+		}
+
+		log.Printf("plan: move (%d) torrent(s) with %s\n", len(moves), humanize.Bytes(uint64(total)))
+
+		if dry {
+			log.Println("dry-run: no torrents moved")
+			return nil
+		}
+
+		moved, failed, err := runMoves(ctx, qb, moves, concurrency, timeout, interval)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("successfully moved (%d) torrent(s)\n", moved)
+
+		if failed > 0 {
+			cmd.SilenceUsage = true
+			return errors.Errorf("could not move %d torrent(s)", failed)
+		}
+
+		return nil
+	}
+
+	return command
+}
+
+// balanceDisk is a save location with its capacity and the size of the torrents saved in it
+type balanceDisk struct {
+	Path     string
+	Capacity int64
+	// Free is the free space on the filesystem, -1 if unknown
+	Free int64
+	Used int64
+}
+
+func (d balanceDisk) ratio() float64 {
+	if d.Capacity <= 0 {
+		return 0
+	}
+
+	return float64(d.Used) / float64(d.Capacity)
+}
+
+// balanceDisks returns the disks of the paths, with capacities from the flag or the local filesystem
+func balanceDisks(paths, capacities []string) ([]balanceDisk, error) {
+	disks := make([]balanceDisk, 0, len(paths))
+	seen := map[string]struct{}{}
+
+	for i, p := range paths {
+		d := balanceDisk{Path: cleanSavePath(p), Free: -1}
+
+		if _, ok := seen[d.Path]; ok {
+			return nil, errors.Errorf("duplicate path: %s", p)
+		}
+		seen[d.Path] = struct{}{}
+
+		if len(capacities) > 0 {
+			capacity, err := humanize.ParseBytes(capacities[i])
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid capacity: %s", capacities[i])
+			}
+
+			d.Capacity = int64(capacity)
+		} else {
+			total, free, err := fs.DiskUsage(d.Path)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not get capacity of %s, set it with --capacities", d.Path)
+			}
+
+			d.Capacity = total
+			d.Free = free
+		}
+
+		if d.Capacity <= 0 {
+			return nil, errors.Errorf("capacity of %s must be more than 0", d.Path)
+		}
+
+		disks = append(disks, d)
+	}
+
+	return disks, nil
+}
+
+// balancePins returns the disk index for every pinned category
+func balancePins(pins map[string]string, disks []balanceDisk) (map[string]int, error) {
+	pinned := make(map[string]int, len(pins))
+
+	for category, p := range pins {
+		idx := -1
+		for i, d := range disks {
+			if d.Path == cleanSavePath(p) {
+				idx = i
+				break
+			}
+		}
+
+		if idx < 0 {
+			return nil, errors.Errorf("pinned path for category %s is not in --paths: %s", category, p)
+		}
+
+		pinned[category] = idx
+	}
+
+	return pinned, nil
+}
+
+// diskForPath returns the index of the disk the save path is in by the longest matching path, or -1
+func diskForPath(disks []balanceDisk, savePath string) int {
+	savePath = cleanSavePath(savePath)
+
+	best := -1
+	for i, d := range disks {
+		if savePath != d.Path && !strings.HasPrefix(savePath, d.Path+"/") && !strings.HasPrefix(savePath, d.Path+"\\") {
+			continue
+		}
+
+		if best < 0 || len(d.Path) > len(disks[best].Path) {
+			best = i
+		}
+	}
+
+	return best
+}
+
+// isMovable reports whether the torrent is complete and not busy
+func isMovable(t qbittorrent.Torrent) bool {
+	if t.Progress < 1 {
+		return false
+	}
+
+	switch t.State {
+	case qbittorrent.TorrentStateMoving, qbittorrent.TorrentStateCheckingUp, qbittorrent.TorrentStateCheckingResumeData,
+		qbittorrent.TorrentStateMissingFiles, qbittorrent.TorrentStateError:
+		return false
+	}
+
+	return true
+}
+
+// balanceGroup is the torrents with the same content, like cross-seeds, that are moved together
+type balanceGroup struct {
+	torrents []qbittorrent.Torrent
+	disk     int
+	size     int64
+}
+
+// movable reports whether all torrents of the group are complete and not busy
+func (g balanceGroup) movable() bool {
+	for _, t := range g.torrents {
+		if !isMovable(t) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// balanceGroups groups the torrents saved in the disks by content path, in the order of the torrents.
+// The size of a group is the size of its largest torrent.
+func balanceGroups(disks []balanceDisk, torrents []qbittorrent.Torrent) []*balanceGroup {
+	var groups []*balanceGroup
+	byContent := map[string]*balanceGroup{}
+
+	for _, t := range torrents {
+		idx := diskForPath(disks, t.SavePath)
+		if idx < 0 {
+			continue
+		}
+
+		p := contentPath(t)
+
+		g, ok := byContent[p]
+		if !ok {
+			g = &balanceGroup{disk: idx}
+			byContent[p] = g
+			groups = append(groups, g)
+		}
+
+		g.torrents = append(g.torrents, t)
+		if t.Size > g.size {
+			g.size = t.Size
+		}
+	}
+
+	return groups
+}
+
+// addDiskUsage adds the size of the torrents to the usage of the disk they are saved in, counting shared content once
+func addDiskUsage(disks []balanceDisk, torrents []qbittorrent.Torrent) {
+	for _, g := range balanceGroups(disks, torrents) {
+		disks[g.disk].Used += g.size
+	}
+}
+
+// planBalance plans moves to reach the target ratio, with the usage of the disks from addDiskUsage.
+// Pinned categories are moved to their disk first. A target ratio of 0 uses the average fill ratio.
+// Torrents with the same content are moved together. The usage of the disks is updated with the planned moves.
+// It returns the moves and the size of the moved content.
+func planBalance(disks []balanceDisk, torrents []qbittorrent.Torrent, pinned map[string]int, targetRatio float64) ([]torrentMove, int64) {
+	groups := balanceGroups(disks, torrents)
+
+	// incoming is the size planned to move to every disk, checked against the free space
+	incoming := make([]int64, len(disks))
+
+	var (
+		moves []torrentMove
+		total int64
+	)
+
+	move := func(g *balanceGroup, to int) {
+		for _, t := range g.torrents {
+			rel := strings.TrimPrefix(cleanSavePath(t.SavePath), disks[g.disk].Path)
+
+			moves = append(moves, torrentMove{
+				Hash: t.Hash,
+				Name: t.Name,
+				From: t.SavePath,
+				To:   disks[to].Path + rel,
+				Size: t.Size,
+			})
+		}
+
+		disks[g.disk].Used -= g.size
+		disks[to].Used += g.size
+		incoming[to] += g.size
+		total += g.size
+	}
+
+	fits := func(idx int, size int64) bool {
+		return disks[idx].Free < 0 || disks[idx].Free-incoming[idx] >= size
+	}
+
+	var unpinned []*balanceGroup
+
+	for _, g := range groups {
+		target, ok := groupPin(g, pinned)
+		if !ok {
+			unpinned = append(unpinned, g)
+			continue
+		}
+
+		if target >= 0 && g.disk != target && g.movable() && fits(target, g.size) {
+			move(g, target)
+		}
+	}
+
+	if targetRatio <= 0 {
+		var used, capacity int64
+		for _, d := range disks {
+			used += d.Used
+			capacity += d.Capacity
+		}
+
+		targetRatio = float64(used) / float64(capacity)
+	}
+
+	// excess is the bytes above the target, negative below it
+	excess := func(idx int) int64 {
+		return disks[idx].Used - int64(targetRatio*float64(disks[idx].Capacity))
+	}
+
+	// largest content first to move as few torrents as possible
+	sort.SliceStable(unpinned, func(i, j int) bool {
+		return unpinned[i].size > unpinned[j].size
+	})
+
+	for _, g := range unpinned {
+		size := g.size
+		if size <= 0 || !g.movable() || excess(g.disk) < size {
+			continue
+		}
+
+		dest := -1
+		for i := range disks {
+			if i == g.disk || -excess(i) < size || !fits(i, size) {
+				continue
+			}
+
+			if dest < 0 || excess(i) < excess(dest) {
+				dest = i
+			}
+		}
+
+		if dest >= 0 {
+			move(g, dest)
+		}
+	}
+
+	return moves, total
+}
+
+// groupPin returns the pinned disk of the group and whether any of its torrents is pinned.
+// The disk is -1 when its torrents are pinned to different disks.
+func groupPin(g *balanceGroup, pinned map[string]int) (int, bool) {
+	target, found := -1, false
+
+	for _, t := range g.torrents {
+		idx, ok := pinned[t.Category]
+		if !ok {
+			continue
+		}
+
+		if found && idx != target {
+			return -1, true
+		}
+
+		target, found = idx, true
+	}
+
+	return target, found
+}
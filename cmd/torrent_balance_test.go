@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/autobrr/go-qbittorrent"
+)
+
+func Test_diskForPath(t *testing.T) {
+	disks := []balanceDisk{{Path: "/mnt/d1"}, {Path: "/mnt/d1/movies"}, {Path: "/mnt/d2"}}
+
+	tests := []struct {
+		name     string
+		savePath string
+		want     int
+	}{
+		{name: "exact", savePath: "/mnt/d2", want: 2},
+		{name: "trailing separator", savePath: "/mnt/d2/", want: 2},
+		{name: "subfolder", savePath: "/mnt/d1/tv", want: 0},
+		{name: "longest prefix", savePath: "/mnt/d1/movies/4k", want: 1},
+		{name: "same prefix other folder", savePath: "/mnt/d10", want: -1},
+		{name: "not in paths", savePath: "/data", want: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diskForPath(disks, tt.savePath); got != tt.want {
+				t.Errorf("diskForPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_balancePins(t *testing.T) {
+	disks := []balanceDisk{{Path: "/mnt/d1"}, {Path: "/mnt/d2"}}
+
+	got, err := balancePins(map[string]string{"movies": "/mnt/d2/"}, disks)
+	if err != nil {
+		t.Fatalf("balancePins() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, map[string]int{"movies": 1}) {
+		t.Errorf("balancePins() = %v", got)
+	}
+
+	if _, err := balancePins(map[string]string{"movies": "/mnt/d3"}, disks); err == nil {
+		t.Errorf("balancePins() expected error for path not in --paths")
+	}
+}
+
+func Test_planBalance(t *testing.T) {
+	const gb = int64(1000 * 1000 * 1000)
+
+	complete := func(hash, savePath, category string, size int64) qbittorrent.Torrent {
+		return qbittorrent.Torrent{Hash: hash, Name: hash, SavePath: savePath, Category: category, Size: size, Progress: 1, State: qbittorrent.TorrentStateUploading}
+	}
+
+	tests := []struct {
+		name        string
+		disks       []balanceDisk
+		torrents    []qbittorrent.Torrent
+		pinned      map[string]int
+		targetRatio float64
+		want        []torrentMove
+		wantTotal   int64
+		wantUsed    []int64
+	}{
+		{
+			name:  "balanced",
+			disks: []balanceDisk{{Path: "/mnt/d1", Capacity: 10 * gb, Free: -1}, {Path: "/mnt/d2", Capacity: 10 * gb, Free: -1}},
+			torrents: []qbittorrent.Torrent{
+				complete("a", "/mnt/d1", "", 2*gb),
+				complete("b", "/mnt/d2", "", 2*gb),
+			},
+			want:     nil,
+			wantUsed: []int64{2 * gb, 2 * gb},
+		},
+		{
+			name:  "largest torrent moved and subfolder kept",
+			disks: []balanceDisk{{Path: "/mnt/d1", Capacity: 10 * gb, Free: -1}, {Path: "/mnt/d2", Capacity: 10 * gb, Free: -1}},
+			torrents: []qbittorrent.Torrent{
+				complete("a", "/mnt/d1/movies", "", 2*gb),
+				complete("b", "/mnt/d1", "", 1*gb),
+				complete("c", "/mnt/d1", "", 1*gb),
+			},
+			want: []torrentMove{
+				{Hash: "a", Name: "a", From: "/mnt/d1/movies", To: "/mnt/d2/movies", Size: 2 * gb},
+			},
+			wantUsed: []int64{2 * gb, 2 * gb},
+		},
+		{
+			name:  "incomplete torrents are not moved",
+			disks: []balanceDisk{{Path: "/mnt/d1", Capacity: 10 * gb, Free: -1}, {Path: "/mnt/d2", Capacity: 10 * gb, Free: -1}},
+			torrents: []qbittorrent.Torrent{
+				{Hash: "a", Name: "a", SavePath: "/mnt/d1", Size: 4 * gb, Progress: 0.5, State: qbittorrent.TorrentStateDownloading},
+			},
+			want:     nil,
+			wantUsed: []int64{4 * gb, 0},
+		},
+		{
+			name:  "destination not filled above target",
+			disks: []balanceDisk{{Path: "/mnt/d1", Capacity: 10 * gb, Free: -1}, {Path: "/mnt/d2", Capacity: 10 * gb, Free: -1}},
+			torrents: []qbittorrent.Torrent{
+				complete("a", "/mnt/d1", "", 6*gb),
+			},
+			want:     nil,
+			wantUsed: []int64{6 * gb, 0},
+		},
+		{
+			name:  "not enough free space",
+			disks: []balanceDisk{{Path: "/mnt/d1", Capacity: 10 * gb, Free: -1}, {Path: "/mnt/d2", Capacity: 10 * gb, Free: 1 * gb}},
+			torrents: []qbittorrent.Torrent{
+				complete("a", "/mnt/d1", "", 2*gb),
+				complete("b", "/mnt/d1", "", 2*gb),
+			},
+			want:     nil,
+			wantUsed: []int64{4 * gb, 0},
+		},
+		{
+			name:  "target ratio",
+			disks: []balanceDisk{{Path: "/mnt/d1", Capacity: 10 * gb, Free: -1}, {Path: "/mnt/d2", Capacity: 10 * gb, Free: -1}},
+			torrents: []qbittorrent.Torrent{
+				complete("a", "/mnt/d1", "", 2*gb),
+				complete("b", "/mnt/d1", "", 2*gb),
+				complete("c", "/mnt/d1", "", 2*gb),
+			},
+			targetRatio: 0.2,
+			want: []torrentMove{
+				{Hash: "a", Name: "a", From: "/mnt/d1", To: "/mnt/d2", Size: 2 * gb},
+			},
+			wantUsed: []int64{4 * gb, 2 * gb},
+		},
+		{
+			name:  "pinned category",
+			disks: []balanceDisk{{Path: "/mnt/d1", Capacity: 10 * gb, Free: -1}, {Path: "/mnt/d2", Capacity: 10 * gb, Free: -1}},
+			torrents: []qbittorrent.Torrent{
+				complete("a", "/mnt/d2/movies", "movies", 3*gb),
+				complete("b", "/mnt/d1", "tv", 1*gb),
+				complete("c", "/mnt/d1", "tv", 1*gb),
+			},
+			pinned: map[string]int{"movies": 0},
+			want: []torrentMove{
+				{Hash: "a", Name: "a", From: "/mnt/d2/movies", To: "/mnt/d1/movies", Size: 3 * gb},
+				{Hash: "b", Name: "b", From: "/mnt/d1", To: "/mnt/d2", Size: 1 * gb},
+				{Hash: "c", Name: "c", From: "/mnt/d1", To: "/mnt/d2", Size: 1 * gb},
+			},
+			wantUsed: []int64{3 * gb, 2 * gb},
+		},
+		{
+			name:  "cross-seeds counted once and moved together",
+			disks: []balanceDisk{{Path: "/mnt/d1", Capacity: 10 * gb, Free: -1}, {Path: "/mnt/d2", Capacity: 10 * gb, Free: -1}},
+			torrents: []qbittorrent.Torrent{
+				{Hash: "a", Name: "Movie", SavePath: "/mnt/d1/movies", ContentPath: "/mnt/d1/movies/Movie", Size: 2 * gb, Progress: 1, State: qbittorrent.TorrentStateUploading},
+				complete("b", "/mnt/d1", "", 2*gb),
+				{Hash: "c", Name: "Movie", SavePath: "/mnt/d1/movies", Size: 2 * gb, Progress: 1, State: qbittorrent.TorrentStateStalledUp},
+			},
+			want: []torrentMove{
+				{Hash: "a", Name: "Movie", From: "/mnt/d1/movies", To: "/mnt/d2/movies", Size: 2 * gb},
+				{Hash: "c", Name: "Movie", From: "/mnt/d1/movies", To: "/mnt/d2/movies", Size: 2 * gb},
+			},
+			wantTotal: 2 * gb,
+			wantUsed:  []int64{2 * gb, 2 * gb},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addDiskUsage(tt.disks, tt.torrents)
+
+			got, total := planBalance(tt.disks, tt.torrents, tt.pinned, tt.targetRatio)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("planBalance() = %+v, want %+v", got, tt.want)
+			}
+			if tt.wantTotal > 0 && total != tt.wantTotal {
+				t.Errorf("planBalance() total = %d, want %d", total, tt.wantTotal)
+			}
+
+			var used []int64
+			for _, d := range tt.disks {
+				used = append(used, d.Used)
+			}
+			if !reflect.DeepEqual(used, tt.wantUsed) {
+				t.Errorf("planBalance() used = %v, want %v", used, tt.wantUsed)
+			}
+		})
+	}
+}
@@ -31,10 +31,11 @@ func RunTorrentCategory() *cobra.Command {
 // RunTorrentCategorySet cmd for torrent category operations
 func RunTorrentCategorySet() *cobra.Command {
 	var command = &cobra.Command{
-		Use:     "set",
-		Short:   "Set torrent category",
-		Long:    `Set category for torrents via hashes`,
-		Example: `  qbt torrent category set test-category --hashes hash1,hash2`,
+		Use:   "set",
+		Short: "Set torrent category",
+		Long:  "Set category for torrents via hashes. Use --hashes - to read newline separated hashes from stdin.",
+		Example: `  qbt torrent category set test-category --hashes hash1,hash2
+  qbt torrent list --output json | jq -r '.[].hash' | qbt torrent category set test-category --hashes -`,
 		Args: func(cmd *cobra.Command, args []string) error {
 			if len(args) < 1 {
 				return errors.New("requires a category as first argument")
@@ -53,11 +54,17 @@ func RunTorrentCategorySet() *cobra.Command {
 	command.Flags().StringSliceVar(&hashes, "hashes", []string{}, "Torrent hashes, as comma separated list")
 
 	command.RunE = func(cmd *cobra.Command, args []string) error {
+		expanded, err := expandStdin(hashes)
+		if err != nil {
+			return err
+		}
+		hashes = expanded
+
 		if len(hashes) == 0 {
 			return errors.New("no hashes supplied!")
 		}
 
-		err := utils.ValidateHash(hashes)
+		err = utils.ValidateHash(hashes)
 		if err != nil {
 			return errors.Wrap(err, "invalid hashes supplied")
 		}
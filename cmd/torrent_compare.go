@@ -181,21 +181,25 @@ func RunTorrentCompare() *cobra.Command {
 }
 
 func compare(source, compare []qbittorrent.Torrent) ([]string, error) {
+	// index source torrents by all their hashes so hybrid and v2 torrents match
+	// regardless of which hash the clients use as id
 	sourceTorrents := make(map[string]qbittorrent.Torrent, 0)
 
 	for _, s := range source {
-		sourceTorrents[s.Hash] = qbittorrent.Torrent{
-			Category:   s.Category,
-			Downloaded: s.Downloaded,
-			Hash:       s.Hash,
-			Name:       s.Name,
-			Progress:   s.Progress,
-			Ratio:      s.Ratio,
-			Size:       s.Size,
-			State:      s.State,
-			Tags:       s.Tags,
-			Tracker:    s.Tracker,
-			Uploaded:   s.Uploaded,
+		for _, key := range torrentHashKeys(s) {
+			sourceTorrents[key] = qbittorrent.Torrent{
+				Category:   s.Category,
+				Downloaded: s.Downloaded,
+				Hash:       s.Hash,
+				Name:       s.Name,
+				Progress:   s.Progress,
+				Ratio:      s.Ratio,
+				Size:       s.Size,
+				State:      s.State,
+				Tags:       s.Tags,
+				Tracker:    s.Tracker,
+				Uploaded:   s.Uploaded,
+			}
 		}
 	}
 
@@ -205,25 +209,27 @@ func compare(source, compare []qbittorrent.Torrent) ([]string, error) {
 	var totalSize uint64
 
 	for _, c := range compare {
-		if _, ok := sourceTorrents[c.Hash]; ok {
-			duplicateTorrentIDs = append(duplicateTorrentIDs, c.Hash)
-
-			totalSize += uint64(c.Size)
-
-			duplicateTorrentsSlice = append(duplicateTorrentsSlice, qbittorrent.Torrent{
-				Category:   c.Category,
-				Downloaded: c.Downloaded,
-				Hash:       c.Hash,
-				Name:       c.Name,
-				Progress:   c.Progress,
-				Ratio:      c.Ratio,
-				Size:       c.Size,
-				State:      c.State,
-				Tags:       c.Tags,
-				Tracker:    c.Tracker,
-				Uploaded:   c.Uploaded,
-			})
+		if !hasAnyKey(sourceTorrents, torrentHashKeys(c)) {
+			continue
 		}
+
+		duplicateTorrentIDs = append(duplicateTorrentIDs, c.Hash)
+
+		totalSize += uint64(c.Size)
+
+		duplicateTorrentsSlice = append(duplicateTorrentsSlice, qbittorrent.Torrent{
+			Category:   c.Category,
+			Downloaded: c.Downloaded,
+			Hash:       c.Hash,
+			Name:       c.Name,
+			Progress:   c.Progress,
+			Ratio:      c.Ratio,
+			Size:       c.Size,
+			State:      c.State,
+			Tags:       c.Tags,
+			Tracker:    c.Tracker,
+			Uploaded:   c.Uploaded,
+		})
 	}
 
 	// print duplicates
@@ -233,3 +239,13 @@ func compare(source, compare []qbittorrent.Torrent) ([]string, error) {
 
 	return duplicateTorrentIDs, nil
 }
+
+func hasAnyKey(m map[string]qbittorrent.Torrent, keys []string) bool {
+	for _, key := range keys {
+		if _, ok := m[key]; ok {
+			return true
+		}
+	}
+
+	return false
+}
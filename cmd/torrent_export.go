@@ -311,6 +311,9 @@ func processExport(sourceDir, exportDir string, hashes map[string]qbittorrent.To
 	// keep track of processed fastresume files
 	processedFastResumeHashes := map[string]bool{}
 
+	// BT_backup files are named by the torrent id, which is the truncated v2 hash for v2-only torrents
+	hashIndex := exportHashIndex(hashes)
+
 	// exportTorrent processes a single matched .torrent file (and its .fastresume).
 	// Any error it returns only concerns this torrent: the caller logs it and moves
 	// on to the remaining files so a single problematic file can't abort the whole
@@ -454,7 +457,7 @@ func processExport(sourceDir, exportDir string, hashes map[string]qbittorrent.To
 		torrentHash := fileNameTrimExt(fileName)
 
 		// if filename not in hashes return and check next
-		torrent, ok := hashes[torrentHash]
+		torrent, ok := hashIndex[torrentHash]
 		if !ok {
 			return nil
 		}
@@ -507,6 +510,21 @@ func processExport(sourceDir, exportDir string, hashes map[string]qbittorrent.To
 	return nil
 }
 
+// exportHashIndex maps every hash a torrent is known by (id, v1, v2 and truncated v2) to the torrent
+func exportHashIndex(hashes map[string]qbittorrent.Torrent) map[string]qbittorrent.Torrent {
+	index := make(map[string]qbittorrent.Torrent, len(hashes))
+
+	for hash, t := range hashes {
+		index[strings.ToLower(hash)] = t
+
+		for _, h := range torrentHashKeys(t) {
+			index[h] = t
+		}
+	}
+
+	return index
+}
+
 func fileNameTrimExt(fileName string) string {
 	return strings.ToLower(strings.TrimSuffix(fileName, filepath.Ext(fileName)))
 }
@@ -0,0 +1,404 @@
+package cmd
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ludviglundgren/qbittorrent-cli/pkg/torrent"
+	"github.com/ludviglundgren/qbittorrent-cli/pkg/utils"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// RunTorrentFileEdit cmd to edit .torrent files offline
+func RunTorrentFileEdit() *cobra.Command {
+	var command = &cobra.Command{
+		Use:   "file-edit",
+		Short: "Edit .torrent files",
+		Long: `Edit .torrent files offline. Accepts files, directories and glob patterns.
+
+Trackers, comment and created by are outside the info dict and can be changed freely.
+Changing source or the private flag modifies the info dict and thereby the info hash,
+which makes it a different torrent for clients and trackers. A warning is printed when that happens.`,
+		Example: `  qbt torrent file-edit ./torrents --replace-tracker 'https://old.example.org/(.*)' --replace-with 'https://new.example.org/$1'
+  qbt torrent file-edit ./torrents --remove-tracker 'opentrackr' --add-tracker udp://tracker.example.org:1337/announce
+  qbt torrent file-edit file.torrent --passkey 0123456789abcdef0123456789abcdef
+  qbt torrent file-edit './files/*.torrent' --strip-passkey --output-dir ./stripped
+  qbt torrent file-edit file.torrent --source EX --private=true`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return errors.New("requires a torrent file, directory or glob as first argument")
+			}
+
+			return nil
+		},
+	}
+
+	var (
+		dry            bool
+		verbose        bool
+		outputDir      string
+		addTrackers    []string
+		removeTracker  string
+		replaceTracker string
+		replaceWith    string
+		passkey        string
+		stripPasskey   bool
+		source         string
+		comment        string
+		createdBy      string
+		private        bool
+	)
+
+	command.Flags().BoolVar(&dry, "dry-run", false, "Dry run, don't write changes")
+	command.Flags().BoolVarP(&verbose, "verbose", "v", false, "Verbose output")
+	command.Flags().StringVar(&outputDir, "output-dir", "", "Write edited files to this dir instead of editing in place. Files with the same name are an error")
+	command.Flags().StringSliceVar(&addTrackers, "add-tracker", []string{}, "Add tracker urls, each in a new tier. Comma separated")
+	command.Flags().StringVar(&removeTracker, "remove-tracker", "", "Remove trackers matching regex")
+	command.Flags().StringVar(&replaceTracker, "replace-tracker", "", "Replace trackers matching regex, used with --replace-with")
+	command.Flags().StringVar(&replaceWith, "replace-with", "", "Replacement for --replace-tracker. Supports capture groups like $1")
+	command.Flags().StringVar(&passkey, "passkey", "", "Rewrite passkey in tracker urls")
+	command.Flags().BoolVar(&stripPasskey, "strip-passkey", false, "Strip passkey from tracker urls")
+	command.Flags().StringVar(&source, "source", "", "Set source field. Empty value removes it. Changes the info hash")
+	command.Flags().StringVar(&comment, "comment", "", "Set comment. Empty value removes it")
+	command.Flags().StringVar(&createdBy, "created-by", "", "Set created by. Empty value removes it")
+	command.Flags().BoolVar(&private, "private", false, "Set private flag. Changes the info hash")
+
+	command.MarkFlagsMutuallyExclusive("passkey", "strip-passkey")
+	command.MarkFlagsRequiredTogether("replace-tracker", "replace-with")
+
+	command.RunE = func(cmd *cobra.Command, args []string) error {
+		edit := torrentFileEdit{
+			addTrackers: addTrackers,
+			passkey:     passkey,
+		}
+
+		if removeTracker != "" {
+			re, err := regexp.Compile(removeTracker)
+			if err != nil {
+				return errors.Wrapf(err, "invalid --remove-tracker regex: %s", removeTracker)
+			}
+			edit.removeTracker = re
+		}
+
+		if replaceTracker != "" {
+			re, err := regexp.Compile(replaceTracker)
+			if err != nil {
+				return errors.Wrapf(err, "invalid --replace-tracker regex: %s", replaceTracker)
+			}
+			edit.replaceTracker = re
+			edit.replaceWith = replaceWith
+		}
+
+		edit.changePasskey = cmd.Flags().Changed("passkey") || stripPasskey
+		if cmd.Flags().Changed("passkey") && passkey == "" {
+			return errors.New("--passkey can not be empty, use --strip-passkey to remove it")
+		}
+
+		if cmd.Flags().Changed("source") {
+			edit.source = &source
+		}
+		if cmd.Flags().Changed("comment") {
+			edit.comment = &comment
+		}
+		if cmd.Flags().Changed("created-by") {
+			edit.createdBy = &createdBy
+		}
+		if cmd.Flags().Changed("private") {
+			edit.private = &private
+		}
+
+		if !edit.hasChanges() {
+			return errors.New("nothing to edit, see --help for available edits")
+		}
+
+		files, err := collectTorrentFiles(args)
+		if err != nil {
+			return err
+		}
+
+		if len(files) == 0 {
+			log.Printf("found 0 torrent files matching %s\n", strings.Join(args, " "))
+			return nil
+		}
+
+		if outputDir != "" {
+			outputDir, err = utils.ExpandTilde(outputDir)
+			if err != nil {
+				return errors.Wrap(err, "could not read output-dir")
+			}
+		}
+
+		outFiles, err := editOutputFiles(files, outputDir)
+		if err != nil {
+			return err
+		}
+
+		editedCount := 0
+		hashChangedCount := 0
+
+		for i, file := range files {
+			t, err := torrent.OpenDecodeRaw(file)
+			if err != nil {
+				return errors.Wrapf(err, "could not decode torrent file: %s", file)
+			}
+
+			oldHash := torrent.CalculateInfoHash(t)
+
+			changes := edit.apply(t)
+			if len(changes) == 0 {
+				if verbose {
+					log.Printf("[%d/%d] no changes: %s\n", i+1, len(files), file)
+				}
+				continue
+			}
+
+			newHash := torrent.CalculateInfoHash(t)
+
+			outFile := outFiles[i]
+
+			if dry {
+				log.Printf("dry-run: [%d/%d] edited: %s\n", i+1, len(files), outFile)
+			} else {
+				if err := torrent.EncodeRaw(outFile, t); err != nil {
+					return errors.Wrapf(err, "could not write torrent file: %s", outFile)
+				}
+
+				log.Printf("[%d/%d] edited: %s\n", i+1, len(files), outFile)
+			}
+
+			if verbose {
+				for _, change := range changes {
+					log.Printf("  %s\n", change)
+				}
+			}
+
+			if oldHash != newHash {
+				hashChangedCount++
+				log.Printf("warning: info hash changed from %s to %s for %s\n", oldHash, newHash, file)
+			}
+
+			editedCount++
+		}
+
+		log.Printf("edited (%d) of (%d) torrent files\n", editedCount, len(files))
+
+		if hashChangedCount > 0 {
+			log.Printf("warning: info hash changed for (%d) torrent files, clients and trackers will see them as new torrents\n", hashChangedCount)
+		}
+
+		return nil
+	}
+
+	return command
+}
+
+// editOutputFiles returns the file to write every edited file to, the file itself without output dir.
+// Files with the same name would overwrite each other in the output dir, so that is an error.
+func editOutputFiles(files []string, outputDir string) ([]string, error) {
+	if outputDir == "" {
+		return files, nil
+	}
+
+	outFiles := make([]string, 0, len(files))
+	seen := make(map[string]string, len(files))
+
+	for _, file := range files {
+		outFile := filepath.Join(outputDir, filepath.Base(file))
+
+		if other, ok := seen[outFile]; ok {
+			return nil, errors.Errorf("%s and %s would both be written to %s, edit them separately", other, file, outFile)
+		}
+		seen[outFile] = file
+
+		outFiles = append(outFiles, outFile)
+	}
+
+	return outFiles, nil
+}
+
+type torrentFileEdit struct {
+	addTrackers    []string
+	removeTracker  *regexp.Regexp
+	replaceTracker *regexp.Regexp
+	replaceWith    string
+	changePasskey  bool
+	passkey        string
+	source         *string
+	comment        *string
+	createdBy      *string
+	private        *bool
+}
+
+func (e torrentFileEdit) hasChanges() bool {
+	return len(e.addTrackers) > 0 || e.removeTracker != nil || e.replaceTracker != nil || e.changePasskey ||
+		e.source != nil || e.comment != nil || e.createdBy != nil || e.private != nil
+}
+
+// apply edits the raw decoded torrent and returns a description of every change made
+func (e torrentFileEdit) apply(t map[string]interface{}) []string {
+	var changes []string
+
+	tiers := torrent.Trackers(t)
+	trackersChanged := false
+
+	var newTiers [][]string
+	for _, tier := range tiers {
+		var newTier []string
+
+		for _, announce := range tier {
+			if e.removeTracker != nil && e.removeTracker.MatchString(announce) {
+				changes = append(changes, "removed tracker "+announce)
+				trackersChanged = true
+				continue
+			}
+
+			newAnnounce := announce
+
+			if e.replaceTracker != nil {
+				newAnnounce = e.replaceTracker.ReplaceAllString(newAnnounce, e.replaceWith)
+			}
+
+			if e.changePasskey {
+				newAnnounce, _ = torrent.ReplacePasskey(newAnnounce, e.passkey)
+			}
+
+			if newAnnounce != announce {
+				changes = append(changes, "replaced tracker "+announce+" with "+newAnnounce)
+				trackersChanged = true
+			}
+
+			newTier = append(newTier, newAnnounce)
+		}
+
+		newTiers = append(newTiers, newTier)
+	}
+
+	for _, announce := range e.addTrackers {
+		if containsTracker(newTiers, announce) {
+			continue
+		}
+
+		newTiers = append(newTiers, []string{announce})
+		changes = append(changes, "added tracker "+announce)
+		trackersChanged = true
+	}
+
+	if trackersChanged {
+		torrent.SetTrackers(t, newTiers)
+	}
+
+	if e.comment != nil && setRawString(t, "comment", *e.comment) {
+		changes = append(changes, "set comment to "+*e.comment)
+	}
+
+	if e.createdBy != nil && setRawString(t, "created by", *e.createdBy) {
+		changes = append(changes, "set created by to "+*e.createdBy)
+	}
+
+	if info, ok := t["info"].(map[string]interface{}); ok {
+		if e.source != nil && setRawString(info, "source", *e.source) {
+			changes = append(changes, "set source to "+*e.source)
+		}
+
+		if e.private != nil {
+			current, _ := info["private"].(int64)
+			if *e.private && current != 1 {
+				torrent.SetInfoField(t, "private", int64(1))
+				changes = append(changes, "set private flag")
+			} else if !*e.private && current != 0 {
+				torrent.SetInfoField(t, "private", nil)
+				changes = append(changes, "removed private flag")
+			}
+		}
+	}
+
+	return changes
+}
+
+// setRawString sets key to value, or removes it when value is empty. It reports whether anything changed.
+func setRawString(m map[string]interface{}, key, value string) bool {
+	current, exists := m[key].(string)
+
+	if value == "" {
+		if !exists {
+			return false
+		}
+
+		delete(m, key)
+		return true
+	}
+
+	if exists && current == value {
+		return false
+	}
+
+	m[key] = value
+	return true
+}
+
+func containsTracker(tiers [][]string, announce string) bool {
+	for _, tier := range tiers {
+		for _, u := range tier {
+			if u == announce {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// collectTorrentFiles expands args made up of files, directories and glob patterns into .torrent file paths
+func collectTorrentFiles(args []string) ([]string, error) {
+	var files []string
+
+	for _, arg := range args {
+		arg, err := utils.ExpandTilde(arg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not expand path: %s", arg)
+		}
+
+		if IsGlobPattern(arg) {
+			matches, err := filepath.Glob(arg)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not find files matching: %s", arg)
+			}
+
+			files = append(files, matches...)
+			continue
+		}
+
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not find file: %s", arg)
+		}
+
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if !d.IsDir() && filepath.Ext(d.Name()) == ".torrent" {
+				files = append(files, path)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not read dir: %s", arg)
+		}
+	}
+
+	return files, nil
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_editOutputFiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     []string
+		outputDir string
+		want      []string
+		wantErr   bool
+	}{
+		{name: "in place", files: []string{"a/x.torrent", "b/x.torrent"}, want: []string{"a/x.torrent", "b/x.torrent"}},
+		{name: "output dir", files: []string{"a/x.torrent", "b/y.torrent"}, outputDir: "out", want: []string{filepath.Join("out", "x.torrent"), filepath.Join("out", "y.torrent")}},
+		{name: "same name in output dir", files: []string{"a/x.torrent", "b/x.torrent"}, outputDir: "out", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := editOutputFiles(tt.files, tt.outputDir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("editOutputFiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("editOutputFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,422 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/ludviglundgren/qbittorrent-cli/internal/config"
+	"github.com/ludviglundgren/qbittorrent-cli/pkg/utils"
+
+	"github.com/autobrr/go-qbittorrent"
+	"github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	FilePrioritySkip   = 0
+	FilePriorityNormal = 1
+	FilePriorityHigh   = 6
+	FilePriorityMax    = 7
+)
+
+// RunTorrentFiles cmd for torrent file operations
+func RunTorrentFiles() *cobra.Command {
+	var command = &cobra.Command{
+		Use:   "files",
+		Short: "Torrent files subcommand",
+		Long:  `List torrent files, change their priority and rename them`,
+	}
+
+	command.AddCommand(RunTorrentFilesList())
+	command.AddCommand(RunTorrentFilesPriority())
+	command.AddCommand(RunTorrentFilesRename())
+
+	return command
+}
+
+// RunTorrentFilesList cmd to list the files of a torrent
+func RunTorrentFilesList() *cobra.Command {
+	var output string
+
+	var command = &cobra.Command{
+		Use:   "list <hash>",
+		Short: "List torrent files",
+		Long:  `List the files of a torrent with name, size, progress, priority and availability.`,
+		Example: `  qbt torrent files list HASH
+  qbt torrent files list HASH --output json`,
+		Args: cobra.ExactArgs(1),
+	}
+
+	command.Flags().StringVar(&output, "output", "", "Print as [formatted text (default), json]")
+
+	command.RunE = func(cmd *cobra.Command, args []string) error {
+		hash := args[0]
+		if err := utils.ValidateHash([]string{hash}); err != nil {
+			return errors.Wrap(err, "invalid hash supplied")
+		}
+
+		config.InitConfig()
+
+		qbtSettings := qbittorrent.Config{
+			Host:      config.Qbit.Addr,
+			APIKey:    config.Qbit.APIKey,
+			Username:  config.Qbit.Login,
+			Password:  config.Qbit.Password,
+			BasicUser: config.Qbit.BasicUser,
+			BasicPass: config.Qbit.BasicPass,
+		}
+
+		qb := qbittorrent.NewClient(qbtSettings)
+
+		ctx := cmd.Context()
+
+		if err := qb.LoginCtx(ctx); err != nil {
+			return errors.Wrap(err, "could not login to qbit")
+		}
+
+		files, err := qb.GetFilesInformationCtx(ctx, hash)
+		if err != nil {
+			return errors.Wrapf(err, "could not get files of torrent: %s", hash)
+		}
+
+		if files == nil || len(*files) == 0 {
+			log.Printf("no files found for torrent: %s\n", hash)
+			return nil
+		}
+
+		switch output {
+		case "json":
+			res, err := json.Marshal(files)
+			if err != nil {
+				return errors.Wrap(err, "could not marshal files to json")
+			}
+			fmt.Println(string(res))
+
+		default:
+			if err := printFiles(*files); err != nil {
+				return errors.Wrap(err, "could not print files")
+			}
+		}
+
+		return nil
+	}
+
+	return command
+}
+
+var torrentFileItemTemplate = `{{ range .}}
+[{{.Index}}] {{.Name}}
+    Size: {{.Size}} Progress: {{.Progress}} Priority: {{.Priority}} Availability: {{.Availability}}
+{{end}}
+`
+
+type FileItemData struct {
+	Index        int
+	Name         string
+	Size         string
+	Progress     string
+	Priority     string
+	Availability string
+}
+
+func printFiles(files qbittorrent.TorrentFiles) error {
+	tmpl, err := template.New("file").Parse(torrentFileItemTemplate)
+	if err != nil {
+		return err
+	}
+
+	var data []FileItemData
+
+	for _, f := range files {
+		data = append(data, FileItemData{
+			Index:        f.Index,
+			Name:         f.Name,
+			Size:         humanize.Bytes(uint64(f.Size)),
+			Progress:     humanize.FtoaWithDigits(float64(f.Progress)*100, 1) + "%",
+			Priority:     filePriorityName(f.Priority),
+			Availability: strconv.FormatFloat(float64(f.Availability), 'f', 2, 32),
+		})
+	}
+
+	return tmpl.Execute(os.Stdout, data)
+}
+
+// filePriorityName returns the name of the file priority as shown in qBittorrent
+func filePriorityName(priority int) string {
+	switch priority {
+	case FilePrioritySkip:
+		return "do not download"
+	case FilePriorityNormal:
+		return "normal"
+	case FilePriorityHigh:
+		return "high"
+	case FilePriorityMax:
+		return "maximum"
+	}
+
+	return strconv.Itoa(priority)
+}
+
+// RunTorrentFilesPriority cmd to set the priority of torrent files
+func RunTorrentFilesPriority() *cobra.Command {
+	var (
+		dry      bool
+		hash     string
+		globs    []string
+		priority int
+	)
+
+	var command = &cobra.Command{
+		Use:   "priority",
+		Short: "Set torrent file priority",
+		Long: `Set the priority of the files of a torrent matching --glob, or all files without --glob.
+
+Priorities: 0 do not download, 1 normal, 6 high, 7 maximum.
+Globs are matched case-insensitively: *.mkv matches file names, Sample/ matches directories and Sample/*.mkv matches the end of paths.`,
+		Example: `  qbt torrent files priority --hash HASH --glob "*.mkv" --priority 7
+  qbt torrent files priority --hash HASH --glob "*.nfo" --glob "Sample/" --priority 0`,
+	}
+
+	command.Flags().BoolVar(&dry, "dry-run", false, "Run without doing anything")
+	command.Flags().StringVar(&hash, "hash", "", "Torrent hash")
+	command.Flags().StringArrayVar(&globs, "glob", nil, "Only change files matching glob. Can be repeated")
+	command.Flags().IntVar(&priority, "priority", FilePriorityNormal, "Priority: 0 do not download, 1 normal, 6 high, 7 maximum")
+
+	command.MarkFlagRequired("hash")
+	command.MarkFlagRequired("priority")
+
+	command.RunE = func(cmd *cobra.Command, args []string) error {
+		if err := utils.ValidateHash([]string{hash}); err != nil {
+			return errors.Wrap(err, "invalid hash supplied")
+		}
+
+		switch priority {
+		case FilePrioritySkip, FilePriorityNormal, FilePriorityHigh, FilePriorityMax:
+		default:
+			return errors.Errorf("invalid priority: %d. Possible values: 0, 1, 6, 7", priority)
+		}
+
+		config.InitConfig()
+
+		qbtSettings := qbittorrent.Config{
+			Host:      config.Qbit.Addr,
+			APIKey:    config.Qbit.APIKey,
+			Username:  config.Qbit.Login,
+			Password:  config.Qbit.Password,
+			BasicUser: config.Qbit.BasicUser,
+			BasicPass: config.Qbit.BasicPass,
+		}
+
+		qb := qbittorrent.NewClient(qbtSettings)
+
+		ctx := cmd.Context()
+
+		if err := qb.LoginCtx(ctx); err != nil {
+			return errors.Wrap(err, "could not login to qbit")
+		}
+
+		files, err := qb.GetFilesInformationCtx(ctx, hash)
+		if err != nil {
+			return errors.Wrapf(err, "could not get files of torrent: %s", hash)
+		}
+
+		if files == nil {
+			return errors.Errorf("no files found for torrent: %s", hash)
+		}
+
+		matched := matchFiles(*files, globs)
+		if len(matched) == 0 {
+			log.Printf("found no files matching %q in torrent: %s\n", globs, hash)
+			return nil
+		}
+
+		ids := make([]string, 0, len(matched))
+		for _, f := range matched {
+			ids = append(ids, strconv.Itoa(f.Index))
+
+			if dry {
+				log.Printf("dry-run: set priority %s on: %s\n", filePriorityName(priority), f.Name)
+			}
+		}
+
+		if dry {
+			return nil
+		}
+
+		if err := qb.SetFilePriorityCtx(ctx, hash, strings.Join(ids, "|"), priority); err != nil {
+			return errors.Wrapf(err, "could not set file priority on torrent: %s", hash)
+		}
+
+		log.Printf("set priority %s on (%d/%d) files of torrent: %s\n", filePriorityName(priority), len(matched), len(*files), hash)
+
+		return nil
+	}
+
+	return command
+}
+
+// matchFiles returns the files matching any of the globs, or all files without globs
+func matchFiles(files qbittorrent.TorrentFiles, globs []string) []qbittorrent.TorrentFile {
+	var matched []qbittorrent.TorrentFile
+
+	for _, f := range files {
+		if len(globs) == 0 {
+			matched = append(matched, f)
+			continue
+		}
+
+		for _, glob := range globs {
+			if matchFilePattern(glob, f.Name) {
+				matched = append(matched, f)
+				break
+			}
+		}
+	}
+
+	return matched
+}
+
+// RunTorrentFilesRename cmd to rename torrent files with a regex
+func RunTorrentFilesRename() *cobra.Command {
+	var (
+		dry     bool
+		hash    string
+		pattern string
+		replace string
+	)
+
+	var command = &cobra.Command{
+		Use:   "rename",
+		Short: "Rename torrent files",
+		Long: `Rename every file of a torrent matching the regex --pattern with --replace.
+
+The pattern is matched against the path of the file in the torrent, like Folder/file.mkv.
+Use $1 or ${name} in --replace for capture groups. Files are only renamed if all new paths are unique.`,
+		Example: `  qbt torrent files rename --hash HASH --pattern '\.' --replace ' ' --dry-run
+  qbt torrent files rename --hash HASH --pattern '^(.*)\.mkv$' --replace '${1}.en.mkv'`,
+	}
+
+	command.Flags().BoolVar(&dry, "dry-run", false, "Run without doing anything")
+	command.Flags().StringVar(&hash, "hash", "", "Torrent hash")
+	command.Flags().StringVar(&pattern, "pattern", "", "Regex to match file paths")
+	command.Flags().StringVar(&replace, "replace", "", "Replacement for matches of the pattern")
+
+	command.MarkFlagRequired("hash")
+	command.MarkFlagRequired("pattern")
+
+	command.RunE = func(cmd *cobra.Command, args []string) error {
+		if err := utils.ValidateHash([]string{hash}); err != nil {
+			return errors.Wrap(err, "invalid hash supplied")
+		}
+
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return errors.Wrapf(err, "invalid pattern: %s", pattern)
+		}
+
+		config.InitConfig()
+
+		qbtSettings := qbittorrent.Config{
+			Host:      config.Qbit.Addr,
+			APIKey:    config.Qbit.APIKey,
+			Username:  config.Qbit.Login,
+			Password:  config.Qbit.Password,
+			BasicUser: config.Qbit.BasicUser,
+			BasicPass: config.Qbit.BasicPass,
+		}
+
+		qb := qbittorrent.NewClient(qbtSettings)
+
+		ctx := cmd.Context()
+
+		if err := qb.LoginCtx(ctx); err != nil {
+			return errors.Wrap(err, "could not login to qbit")
+		}
+
+		files, err := qb.GetFilesInformationCtx(ctx, hash)
+		if err != nil {
+			return errors.Wrapf(err, "could not get files of torrent: %s", hash)
+		}
+
+		if files == nil {
+			return errors.Errorf("no files found for torrent: %s", hash)
+		}
+
+		renames, err := renameFiles(*files, re, replace)
+		if err != nil {
+			return err
+		}
+
+		if len(renames) == 0 {
+			log.Printf("no files to rename in torrent: %s\n", hash)
+			return nil
+		}
+
+		for i, r := range renames {
+			if dry {
+				log.Printf("dry-run: [%d/%d] rename %s to %s\n", i+1, len(renames), r.OldPath, r.NewPath)
+				continue
+			}
+
+			if err := qb.RenameFileCtx(ctx, hash, r.OldPath, r.NewPath); err != nil {
+				return errors.Wrapf(err, "could not rename %s to %s", r.OldPath, r.NewPath)
+			}
+
+			log.Printf("[%d/%d] renamed %s to %s\n", i+1, len(renames), r.OldPath, r.NewPath)
+		}
+
+		if !dry {
+			log.Printf("successfully renamed (%d) files of torrent: %s\n", len(renames), hash)
+		}
+
+		return nil
+	}
+
+	return command
+}
+
+type fileRename struct {
+	OldPath string
+	NewPath string
+}
+
+// renameFiles returns the renames of the files changed by the regex.
+// It errors if a new path is empty, already exists or is used by more than one file.
+func renameFiles(files qbittorrent.TorrentFiles, re *regexp.Regexp, replace string) ([]fileRename, error) {
+	var renames []fileRename
+
+	paths := make(map[string]string, len(files))
+	for _, f := range files {
+		paths[f.Name] = f.Name
+	}
+
+	for _, f := range files {
+		newPath := re.ReplaceAllString(f.Name, replace)
+		if newPath == f.Name {
+			continue
+		}
+
+		if strings.TrimSpace(newPath) == "" || strings.HasSuffix(newPath, "/") {
+			return nil, errors.Errorf("invalid new path for %s: %q", f.Name, newPath)
+		}
+
+		renames = append(renames, fileRename{OldPath: f.Name, NewPath: newPath})
+	}
+
+	for _, r := range renames {
+		if existing, ok := paths[r.NewPath]; ok {
+			return nil, errors.Errorf("cannot rename %s to %s: path is used by %s", r.OldPath, r.NewPath, existing)
+		}
+
+		paths[r.NewPath] = r.OldPath
+	}
+
+	return renames, nil
+}
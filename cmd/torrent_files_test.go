@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/autobrr/go-qbittorrent"
+)
+
+var testTorrentFiles = qbittorrent.TorrentFiles{
+	{Index: 0, Name: "Movie.2020/Movie.2020.mkv"},
+	{Index: 1, Name: "Movie.2020/Movie.2020.nfo"},
+	{Index: 2, Name: "Movie.2020/Sample/sample.mkv"},
+}
+
+func Test_matchFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		globs []string
+		want  []int
+	}{
+		{name: "all files without globs", globs: nil, want: []int{0, 1, 2}},
+		{name: "extension", globs: []string{"*.MKV"}, want: []int{0, 2}},
+		{name: "directory", globs: []string{"Sample/"}, want: []int{2}},
+		{name: "multiple globs", globs: []string{"*.nfo", "Sample/"}, want: []int{1, 2}},
+		{name: "no match", globs: []string{"*.srt"}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			for _, f := range matchFiles(testTorrentFiles, tt.globs) {
+				got = append(got, f.Index)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("matchFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_renameFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		replace string
+		want    []fileRename
+		wantErr bool
+	}{
+		{
+			name:    "capture group",
+			pattern: `^(.*)\.mkv$`,
+			replace: "${1}.en.mkv",
+			want: []fileRename{
+				{OldPath: "Movie.2020/Movie.2020.mkv", NewPath: "Movie.2020/Movie.2020.en.mkv"},
+				{OldPath: "Movie.2020/Sample/sample.mkv", NewPath: "Movie.2020/Sample/sample.en.mkv"},
+			},
+		},
+		{
+			name:    "no changes",
+			pattern: `\.srt$`,
+			replace: ".en.srt",
+			want:    nil,
+		},
+		{
+			name:    "same new path",
+			pattern: `\.(mkv|nfo)$`,
+			replace: ".txt",
+			wantErr: true,
+		},
+		{
+			name:    "new path exists",
+			pattern: `\.nfo$`,
+			replace: ".mkv",
+			wantErr: true,
+		},
+		{
+			name:    "empty new path",
+			pattern: `.*`,
+			replace: "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renameFiles(testTorrentFiles, regexp.MustCompile(tt.pattern), tt.replace)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("renameFiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("renameFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
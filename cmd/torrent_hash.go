@@ -4,17 +4,29 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/anacrolix/torrent/metainfo"
+	"github.com/ludviglundgren/qbittorrent-cli/pkg/torrent"
+
+	"github.com/autobrr/go-qbittorrent"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
 // RunTorrentHash cmd to add torrents
 func RunTorrentHash() *cobra.Command {
+	var onlyID bool
+
 	var command = &cobra.Command{
-		Use:     "hash",
-		Short:   "Print the hash of a torrent file or magnet",
-		Example: `  qbt torrent hash file.torrent`,
+		Use:   "hash",
+		Short: "Print the hash of a torrent file or magnet",
+		Long: `Print the info hash of a torrent file or magnet.
+
+For v1 torrents the SHA-1 hash is printed. For v2 and hybrid torrents the v1 (if any) and the
+SHA-256 v2 hash are printed on separate lines, prefixed with v1: and v2:.
+
+Use --id to only print the id qBittorrent uses for the torrent: the v1 hash, or for v2-only
+torrents the v2 hash truncated to 40 characters.`,
+		Example: `  qbt torrent hash file.torrent
+  qbt torrent hash hybrid.torrent --id`,
 		Args: func(cmd *cobra.Command, args []string) error {
 			if len(args) < 1 {
 				return errors.New("requires a torrent file or magnet URI as first argument")
@@ -24,31 +36,53 @@ func RunTorrentHash() *cobra.Command {
 		},
 	}
 
+	command.Flags().BoolVar(&onlyID, "id", false, "Only print the id qBittorrent uses for the torrent")
+
 	command.RunE = func(cmd *cobra.Command, args []string) error {
 		filePath := args[0]
-		hash := ""
+
+		var hashes torrent.InfoHashes
+		var err error
 
 		if strings.HasPrefix(filePath, "magnet:") {
-			metadata, err := metainfo.ParseMagnetUri(filePath)
+			hashes, err = torrent.HashesFromMagnet(filePath)
 			if err != nil {
 				return errors.Wrapf(err, "could not parse magnet URI: %s", filePath)
 			}
-
-			hash = metadata.InfoHash.HexString()
-
 		} else {
-			metadata, err := metainfo.LoadFromFile(filePath)
+			hashes, err = torrent.HashesFromFile(filePath)
 			if err != nil {
 				return errors.Wrapf(err, "could not parse torrent file: %s", filePath)
 			}
+		}
 
-			hash = metadata.HashInfoBytes().HexString()
+		if onlyID || hashes.V2 == "" {
+			fmt.Println(hashes.ID())
+			return nil
+		}
+
+		if hashes.V1 != "" {
+			fmt.Printf("v1: %s\n", hashes.V1)
 		}
 
-		fmt.Println(hash)
+		fmt.Printf("v2: %s\n", hashes.V2)
 
 		return nil
 	}
 
 	return command
 }
+
+// torrentHashKeys returns every lowercase hash a client torrent is known by:
+// its id, the v1 hash, the v2 hash and the truncated v2 hash
+func torrentHashKeys(t qbittorrent.Torrent) []string {
+	var keys []string
+
+	for _, h := range []string{t.Hash, t.InfohashV1, t.InfohashV2, torrent.TruncateV2(t.InfohashV2)} {
+		if h != "" {
+			keys = append(keys, strings.ToLower(h))
+		}
+	}
+
+	return keys
+}
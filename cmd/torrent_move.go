@@ -0,0 +1,333 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ludviglundgren/qbittorrent-cli/internal/config"
+	"github.com/ludviglundgren/qbittorrent-cli/pkg/utils"
+
+	"github.com/autobrr/go-qbittorrent"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// moveStartPolls is the number of polls a torrent can stay at its old location without moving before the move is failed
+const moveStartPolls = 3
+
+// RunTorrentMove cmd to move torrent data to another location
+func RunTorrentMove() *cobra.Command {
+	var (
+		dry       bool
+		to        string
+		hashes    []string
+		category  string
+		tag       string
+		filter    string
+		batchSize int
+		timeout   time.Duration
+		interval  time.Duration
+	)
+
+	var command = &cobra.Command{
+		Use:   "move",
+		Short: "Move torrent data to another location",
+		Long: `Move the data of torrents to another location with qBittorrent, selected by hashes, category, tag and filter.
+
+Torrents are moved in batches of --batch-size, waiting for every batch to finish moving before starting the next
+so the disks are not saturated. After moving, torrents in missingFiles or error state are reported as failed.
+Moved torrents have Automatic Torrent Management disabled, as the save path no longer follows the category.`,
+		Example: `  qbt torrent move --to /mnt/disk2/movies --category movies
+  qbt torrent move --to /mnt/disk2/tv --hashes HASH1,HASH2 --batch-size 2
+  qbt torrent list --filter completed --output json | jq -r '.[].hash' | qbt torrent move --to /mnt/archive --hashes -`,
+	}
+
+	command.Flags().BoolVar(&dry, "dry-run", false, "Run without doing anything")
+	command.Flags().StringVar(&to, "to", "", "Location to move the torrent data to (required)")
+	command.Flags().StringSliceVar(&hashes, "hashes", []string{}, "Move torrents with hashes. Comma separated, or - to read from stdin")
+	command.Flags().StringVarP(&category, "category", "c", "", "Move torrents with category")
+	command.Flags().StringVarP(&tag, "tag", "t", "", "Move torrents with tag")
+	command.Flags().StringVarP(&filter, "filter", "f", "", "Move torrents with state. Available filters: all, downloading, seeding, completed, paused, active, inactive, resumed, \nstalled, stalled_uploading, stalled_downloading, errored")
+	command.Flags().IntVar(&batchSize, "batch-size", 1, "Number of torrents to move at the same time")
+	command.Flags().DurationVar(&timeout, "timeout", 0, "Max time to wait for a batch to move, 0 waits forever")
+	command.Flags().DurationVar(&interval, "interval", 5*time.Second, "Interval to check the move progress")
+
+	command.MarkFlagRequired("to")
+
+	command.RunE = func(cmd *cobra.Command, args []string) error {
+		expanded, err := expandStdin(hashes)
+		if err != nil {
+			return err
+		}
+		hashes = expanded
+
+		if len(hashes) > 0 {
+			if err := utils.ValidateHash(hashes); err != nil {
+				return errors.Wrap(err, "invalid hashes supplied")
+			}
+		}
+
+		if len(hashes) == 0 && category == "" && tag == "" && filter == "" {
+			return errors.New("no torrents specified: use --hashes, --category, --tag or --filter")
+		}
+
+		if batchSize < 1 {
+			return errors.Errorf("invalid --batch-size: %d", batchSize)
+		}
+
+		location := cleanSavePath(to)
+		if location == "" {
+			return errors.New("--to can not be empty")
+		}
+
+		config.InitConfig()
+
+		qbtSettings := qbittorrent.Config{
+			Host:      config.Qbit.Addr,
+			APIKey:    config.Qbit.APIKey,
+			Username:  config.Qbit.Login,
+			Password:  config.Qbit.Password,
+			BasicUser: config.Qbit.BasicUser,
+			BasicPass: config.Qbit.BasicPass,
+		}
+
+		qb := qbittorrent.NewClient(qbtSettings)
+
+		ctx := cmd.Context()
+
+		if err := qb.LoginCtx(ctx); err != nil {
+			return errors.Wrap(err, "could not login to qbit")
+		}
+
+		torrents, err := qb.GetTorrentsCtx(ctx, qbittorrent.TorrentFilterOptions{
+			Filter:   qbittorrent.TorrentFilter(strings.ToLower(filter)),
+			Category: category,
+			Tag:      tag,
+			Hashes:   hashes,
+		})
+		if err != nil {
+			return errors.Wrap(err, "could not get torrents")
+		}
+
+		var toMove []qbittorrent.Torrent
+		for _, t := range torrents {
+			if cleanSavePath(t.SavePath) == location {
+				log.Printf("torrent %s %s is already in %s\n", t.Hash, t.Name, location)
+				continue
+			}
+
+			toMove = append(toMove, t)
+		}
+
+		if len(toMove) == 0 {
+			log.Printf("found no torrents to move to %s\n", location)
+			return nil
+		}
+
+		if dry {
+			for _, t := range toMove {
+				log.Printf("dry-run: move %s %s from %s to %s\n", t.Hash, t.Name, t.SavePath, location)
+			}
+
+			log.Printf("dry-run: (%d) torrent(s) to move to %s\n", len(toMove), location)
+
+			return nil
+		}
+
+		log.Printf("moving (%d) torrent(s) to %s in batches of %d\n", len(toMove), location, batchSize)
+
+		moves := make([]torrentMove, 0, len(toMove))
+		for _, t := range toMove {
+			moves = append(moves, torrentMove{Hash: t.Hash, Name: t.Name, From: t.SavePath, To: location, Size: t.Size})
+		}
+
+		moved, failed, err := runMoves(ctx, qb, moves, batchSize, timeout, interval)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("successfully moved (%d) torrent(s) to %s\n", moved, location)
+
+		if failed > 0 {
+			cmd.SilenceUsage = true
+			return errors.Errorf("could not move %d torrent(s)", failed)
+		}
+
+		return nil
+	}
+
+	return command
+}
+
+// torrentMove is a torrent to move to a new location
+type torrentMove struct {
+	Hash string
+	Name string
+	From string
+	To   string
+	Size int64
+}
+
+// runMoves moves the torrents in batches of batchSize, waiting for every batch to finish moving before starting the next.
+// It returns the number of moved and failed torrents.
+func runMoves(ctx context.Context, qb *qbittorrent.Client, moves []torrentMove, batchSize int, timeout, interval time.Duration) (int, int, error) {
+	moved := 0
+	failed := 0
+
+	for start := 0; start < len(moves); start += batchSize {
+		batch := moves[start:min(start+batchSize, len(moves))]
+
+		locations := make(map[string]string, len(batch))
+		byLocation := map[string][]string{}
+
+		for i, m := range batch {
+			log.Printf("[%d/%d] moving %s %s from %s to %s\n", start+i+1, len(moves), m.Hash, m.Name, m.From, m.To)
+
+			locations[m.Hash] = m.To
+			byLocation[m.To] = append(byLocation[m.To], m.Hash)
+		}
+
+		for location, hashes := range byLocation {
+			if err := qb.SetLocationCtx(ctx, hashes, location); err != nil {
+				return moved, failed, errors.Wrapf(err, "could not set location of torrents: %v", hashes)
+			}
+		}
+
+		errs, err := waitForMove(ctx, qb, locations, timeout, interval)
+		if err != nil {
+			return moved, failed, err
+		}
+
+		for _, m := range batch {
+			if err, ok := errs[m.Hash]; ok {
+				log.Printf("could not move torrent %s: %q\n", m.Hash, err)
+				failed++
+				continue
+			}
+
+			moved++
+		}
+	}
+
+	return moved, failed, nil
+}
+
+// cleanSavePath removes trailing separators so save paths from qBittorrent compare equal to user input
+func cleanSavePath(path string) string {
+	trimmed := strings.TrimRight(path, "/\\")
+	if trimmed == "" && path != "" {
+		return path[:1]
+	}
+
+	return trimmed
+}
+
+// moveStatus reports whether the torrent finished moving to location, and an error if it has missing files or errored after the move
+func moveStatus(t qbittorrent.Torrent, location string) (bool, error) {
+	if t.State == qbittorrent.TorrentStateMoving {
+		return false, nil
+	}
+
+	if cleanSavePath(t.SavePath) != cleanSavePath(location) {
+		return false, nil
+	}
+
+	switch t.State {
+	case qbittorrent.TorrentStateMissingFiles, qbittorrent.TorrentStateError:
+		return true, errors.Errorf("torrent is in state %s after moving to %s", t.State, location)
+	}
+
+	return true, nil
+}
+
+// waitForMove polls the torrents until they finished moving to their location and returns the torrents that failed.
+// Torrents that don't start moving within a few polls are failed. A timeout of 0 waits forever.
+func waitForMove(ctx context.Context, qb *qbittorrent.Client, locations map[string]string, timeout, interval time.Duration) (map[string]error, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	hashes := make([]string, 0, len(locations))
+	pending := make(map[string]struct{}, len(locations))
+	for hash := range locations {
+		hashes = append(hashes, hash)
+		pending[hash] = struct{}{}
+	}
+
+	errs := map[string]error{}
+	notStarted := map[string]int{}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, errors.Errorf("timed out after %s waiting for (%d) torrent(s) to move", timeout, len(pending))
+			}
+
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		torrents, err := qb.GetTorrentsCtx(ctx, qbittorrent.TorrentFilterOptions{Hashes: hashes})
+		if err != nil {
+			if ctx.Err() != nil {
+				continue
+			}
+
+			return nil, errors.Wrap(err, "could not get torrents")
+		}
+
+		found := map[string]struct{}{}
+
+		for _, t := range torrents {
+			found[t.Hash] = struct{}{}
+
+			if _, ok := pending[t.Hash]; !ok {
+				continue
+			}
+
+			location := locations[t.Hash]
+
+			done, err := moveStatus(t, location)
+			if err != nil {
+				errs[t.Hash] = err
+			}
+
+			if done {
+				if err == nil {
+					log.Printf("moved %s %s to %s\n", t.Hash, t.Name, location)
+				}
+
+				delete(pending, t.Hash)
+				continue
+			}
+
+			if t.State != qbittorrent.TorrentStateMoving {
+				notStarted[t.Hash]++
+
+				if notStarted[t.Hash] >= moveStartPolls {
+					errs[t.Hash] = errors.Errorf("torrent did not move, still in %s with state %s", t.SavePath, t.State)
+					delete(pending, t.Hash)
+				}
+			}
+		}
+
+		for hash := range pending {
+			if _, ok := found[hash]; !ok {
+				errs[hash] = errors.New("torrent not found")
+				delete(pending, hash)
+			}
+		}
+
+		if len(pending) == 0 {
+			return errs, nil
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/autobrr/go-qbittorrent"
+)
+
+func Test_cleanSavePath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/mnt/disk2/movies/", want: "/mnt/disk2/movies"},
+		{path: "/mnt/disk2/movies", want: "/mnt/disk2/movies"},
+		{path: `D:\Torrents\`, want: `D:\Torrents`},
+		{path: "/", want: "/"},
+		{path: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := cleanSavePath(tt.path); got != tt.want {
+				t.Errorf("cleanSavePath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_moveStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		torrent  qbittorrent.Torrent
+		wantDone bool
+		wantErr  bool
+	}{
+		{
+			name:    "moving",
+			torrent: qbittorrent.Torrent{State: qbittorrent.TorrentStateMoving, SavePath: "/old"},
+		},
+		{
+			name:    "not moved yet",
+			torrent: qbittorrent.Torrent{State: qbittorrent.TorrentStateUploading, SavePath: "/old"},
+		},
+		{
+			name:     "moved",
+			torrent:  qbittorrent.Torrent{State: qbittorrent.TorrentStateStalledUp, SavePath: "/new/"},
+			wantDone: true,
+		},
+		{
+			name:     "missing files after move",
+			torrent:  qbittorrent.Torrent{State: qbittorrent.TorrentStateMissingFiles, SavePath: "/new"},
+			wantDone: true,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done, err := moveStatus(tt.torrent, "/new")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("moveStatus() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if done != tt.wantDone {
+				t.Errorf("moveStatus() done = %v, want %v", done, tt.wantDone)
+			}
+		})
+	}
+}
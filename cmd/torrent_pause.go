@@ -21,10 +21,12 @@ func RunTorrentPause() *cobra.Command {
 	var command = &cobra.Command{
 		Use:   "pause",
 		Short: "Pause specified torrent(s)",
-		Long:  `Pause the torrent(s) indicated by the supplied hash(es), or pause every torrent with --all.`,
+		Long: `Pause the torrent(s) indicated by the supplied hash(es), or pause every torrent with --all.
+Use - to read newline separated hashes from stdin.`,
 		Example: `  qbt torrent pause --all
   qbt torrent pause HASH1 HASH2
-  qbt torrent pause --hashes HASH1,HASH2`,
+  qbt torrent pause --hashes HASH1,HASH2
+  qbt torrent list --output json | jq -r '.[].hash' | qbt torrent pause -`,
 	}
 
 	command.Flags().BoolVar(&pauseAll, "all", false, "Pauses all torrents")
@@ -35,6 +37,12 @@ func RunTorrentPause() *cobra.Command {
 		// alongside the --hashes flag.
 		hashes = append(hashes, args...)
 
+		expanded, err := expandStdin(hashes)
+		if err != nil {
+			return err
+		}
+		hashes = expanded
+
 		if pauseAll {
 			hashes = []string{"all"}
 		} else {
@@ -2,10 +2,16 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/ludviglundgren/qbittorrent-cli/internal/config"
+	"github.com/ludviglundgren/qbittorrent-cli/internal/reannounce"
 
 	"github.com/autobrr/go-qbittorrent"
 	"github.com/pkg/errors"
@@ -15,30 +21,82 @@ import (
 // RunTorrentReannounce cmd to reannounce torrents
 func RunTorrentReannounce() *cobra.Command {
 	var (
-		dry      bool
-		hash     string
-		category string
-		tag      string
-		attempts int
-		interval int
+		dry            bool
+		hash           string
+		category       string
+		tag            string
+		attempts       int
+		interval       int
+		maxInterval    int
+		domainInterval int
+		workers        int
+		removeStalled  bool
+		watch          bool
+		poll           time.Duration
+		output         string
 	)
 
 	var command = &cobra.Command{
 		Use:   "reannounce",
 		Short: "Reannounce torrent(s)",
-		Long:  `Reannounce torrents with non-OK tracker status.`,
+		Long: `Reannounce torrents without a working tracker until a tracker works.
+The trackers of every torrent are checked first, torrents without trackers like magnets using only DHT are skipped.
+
+Torrents are reannounced concurrently by --workers, with the wait between attempts doubling from --interval up to --max-interval.
+Reannounces to the same tracker domain are spaced out by --domain-interval. Defaults are read from [reannounce] in the config.
+
+Use --watch to keep running and pick up newly added torrents every --poll interval. Stop with ctrl+c to print the report.
+Use --output json to print one result per torrent with the tracker, status (ok, failed, removed, error or canceled) and attempts.`,
+		Example: `  qbt torrent reannounce --category movies
+  qbt torrent reannounce --hash HASH --attempts 10
+  qbt torrent reannounce --watch --workers 20 --output json`,
 	}
 
 	command.Flags().BoolVar(&dry, "dry-run", false, "Run without doing anything")
 	command.Flags().StringVar(&hash, "hash", "", "Reannounce torrent with hash")
 	command.Flags().StringVar(&category, "category", "", "Reannounce torrents with category")
 	command.Flags().StringVar(&tag, "tag", "", "Reannounce torrents with tag")
-	command.Flags().IntVar(&attempts, "attempts", 50, "Reannounce torrents X times")
-	command.Flags().IntVar(&interval, "interval", 7000, "Reannounce torrents X times with interval Y. In MS")
+	command.Flags().IntVar(&attempts, "attempts", reannounce.DefaultAttempts, "Reannounce torrents X times")
+	command.Flags().IntVar(&interval, "interval", int(reannounce.DefaultInterval.Milliseconds()), "Wait before the first attempt, doubled after every attempt. In MS")
+	command.Flags().IntVar(&maxInterval, "max-interval", int(reannounce.DefaultMaxInterval.Milliseconds()), "Max wait between attempts. In MS")
+	command.Flags().IntVar(&domainInterval, "domain-interval", int(reannounce.DefaultDomainInterval.Milliseconds()), "Min time between reannounces to the same tracker domain. In MS")
+	command.Flags().IntVar(&workers, "workers", reannounce.DefaultWorkers, "Number of torrents to reannounce at the same time")
+	command.Flags().BoolVar(&removeStalled, "remove-stalled", false, "Remove torrents without a working tracker after the last attempt")
+	command.Flags().BoolVar(&watch, "watch", false, "Keep running and reannounce newly added torrents")
+	command.Flags().DurationVar(&poll, "poll", 30*time.Second, "Interval to look for new torrents with --watch")
+	command.Flags().StringVar(&output, "output", "", "Print results as [formatted text (default), json]")
 
 	command.RunE = func(cmd *cobra.Command, args []string) error {
+		if output != "" && output != "json" {
+			return errors.Errorf("invalid --output: %s. Possible values: json", output)
+		}
+
 		config.InitConfig()
 
+		opts := reannounce.OptionsFromConfig(config.Reannounce)
+		opts.RemoveStalled = removeStalled
+
+		flags := cmd.Flags()
+		if flags.Changed("attempts") {
+			opts.Attempts = attempts
+		}
+		if flags.Changed("interval") {
+			opts.Interval = time.Duration(interval) * time.Millisecond
+		}
+		if flags.Changed("max-interval") {
+			opts.MaxInterval = time.Duration(maxInterval) * time.Millisecond
+		}
+		if flags.Changed("domain-interval") {
+			opts.DomainInterval = time.Duration(domainInterval) * time.Millisecond
+		}
+		if flags.Changed("workers") {
+			opts.Workers = workers
+		}
+
+		opts.OnResult = func(r reannounce.Result) {
+			log.Printf("%s %s: %s after %d attempt(s) in %s\n", r.Hash, r.Name, r.Status, r.Attempts, r.Duration)
+		}
+
 		qbtSettings := qbittorrent.Config{
 			Host:      config.Qbit.Addr,
 			APIKey:    config.Qbit.APIKey,
@@ -50,49 +108,126 @@ func RunTorrentReannounce() *cobra.Command {
 
 		qb := qbittorrent.NewClient(qbtSettings)
 
-		ctx := cmd.Context()
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
 
 		if err := qb.LoginCtx(ctx); err != nil {
 			return errors.Wrap(err, "could not login to qbit")
 		}
 
 		req := qbittorrent.TorrentFilterOptions{
-			//Filter:   qbittorrent.TorrentFilterDownloading,
 			Category: category,
 			Tag:      tag,
-			Hashes:   []string{hash},
 		}
 
-		activeDownloads, err := qb.GetTorrentsCtx(ctx, req)
-		if err != nil {
-			return errors.Wrap(err, "could not fetch torrents")
+		if hash != "" {
+			req.Hashes = []string{hash}
 		}
 
-		if hash != "" && len(activeDownloads) != 1 {
-			return errors.Errorf("torrent not found: %s", hash)
-		}
+		supervisor := reannounce.New(ctx, qb, opts)
 
-		if dry {
-			log.Println("dry-run: torrents successfully re-announced!")
+		// torrents on dry-run and torrents without trackers are only reported once
+		skipped := map[string]struct{}{}
+
+		// queue adds the torrents without a working tracker to the supervisor
+		queue := func() error {
+			torrents, err := qb.GetTorrentsCtx(ctx, req)
+			if err != nil {
+				return errors.Wrap(err, "could not fetch torrents")
+			}
+
+			if hash != "" && len(torrents) != 1 && !watch {
+				return errors.Errorf("torrent not found: %s", hash)
+			}
+
+			var candidates []qbittorrent.Torrent
+
+			for _, t := range torrents {
+				if hash == "" && !needsReannounce(t) {
+					continue
+				}
+
+				if _, ok := skipped[t.Hash]; ok || supervisor.Has(t.Hash) {
+					continue
+				}
+
+				candidates = append(candidates, t)
+			}
+
+			// torrents with a working tracker or without trackers are skipped, unless reannounced by hash
+			if hash == "" && len(candidates) > 0 {
+				results, err := fetchTrackers(ctx, qb, candidates, opts.Workers, 0)
+				if err != nil {
+					return errors.Wrap(err, "could not get trackers")
+				}
 
-		} else {
-			for _, torrent := range activeDownloads {
-				if torrent.Progress == 0 && torrent.TimeActive < 120 {
-					go func(torrent qbittorrent.Torrent) {
-						log.Printf("torrent %s %s not working, active for %ds, re-announcing...\n", torrent.Hash, torrent.Name, torrent.TimeActive)
+				candidates = candidates[:0]
 
-						// some trackers are bugged or slow, so we need to re-announce the torrent until it works
-						if err = reannounceTorrent(ctx, qb, interval, attempts, torrent.Hash); err != nil {
-							log.Printf("could not re-announce torrent: %s %s err: %q\n", torrent.Hash, torrent.Name, err)
-						}
+				for _, res := range results {
+					switch {
+					case res.Err != nil:
+						log.Printf("could not get trackers for torrent %s: %q\n", res.Torrent.Hash, res.Err)
+					case reannounce.IsWorking(res.Trackers):
+					case reannounce.TrackerDomain(res.Trackers) == "":
+						skipped[res.Torrent.Hash] = struct{}{}
+						log.Printf("torrent %s %s has no trackers, skipping\n", res.Torrent.Hash, res.Torrent.Name)
+					default:
+						candidates = append(candidates, res.Torrent)
+					}
+				}
+			}
 
-						log.Printf("successfully re-announced torrent: %s %s err: %q\n", torrent.Hash, torrent.Name, err)
+			for _, t := range candidates {
+				if dry {
+					skipped[t.Hash] = struct{}{}
+					log.Printf("dry-run: reannounce %s %s\n", t.Hash, t.Name)
+					continue
+				}
 
-					}(torrent)
+				if supervisor.Add(t.Hash, t.Name) {
+					log.Printf("torrent %s %s has no working tracker, re-announcing...\n", t.Hash, t.Name)
 				}
 			}
 
-			log.Println("torrents successfully re-announced")
+			return nil
+		}
+
+		if err := queue(); err != nil {
+			return err
+		}
+
+		if watch {
+			log.Printf("watching for torrents to reannounce every %s\n", poll)
+
+			pollReannounce(ctx, poll, queue)
+		}
+
+		results := supervisor.Wait()
+
+		if output == "json" {
+			if results == nil {
+				results = []reannounce.Result{}
+			}
+
+			res, err := json.Marshal(results)
+			if err != nil {
+				return errors.Wrap(err, "could not marshal results to json")
+			}
+
+			fmt.Println(string(res))
+		}
+
+		counts := map[reannounce.Status]int{}
+		for _, r := range results {
+			counts[r.Status]++
+		}
+
+		log.Printf("reannounced (%d) torrent(s): %d ok, %d failed, %d removed, %d error, %d canceled\n", len(results),
+			counts[reannounce.StatusOK], counts[reannounce.StatusFailed], counts[reannounce.StatusRemoved], counts[reannounce.StatusError], counts[reannounce.StatusCanceled])
+
+		if failed := counts[reannounce.StatusFailed] + counts[reannounce.StatusError]; failed > 0 && !watch {
+			cmd.SilenceUsage = true
+			return errors.Errorf("could not reannounce %d torrent(s)", failed)
 		}
 
 		return nil
@@ -101,38 +236,35 @@ func RunTorrentReannounce() *cobra.Command {
 	return command
 }
 
-func reannounceTorrent(ctx context.Context, qb *qbittorrent.Client, interval, attempts int, hash string) error {
-	announceOK := false
-	attempt := 0
+// pollReannounce runs queue every interval until ctx is done
+func pollReannounce(ctx context.Context, interval time.Duration, queue func() error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
-	time.Sleep(time.Duration(interval) * time.Millisecond)
-
-	for attempt < attempts {
-		trackers, err := qb.GetTorrentTrackersCtx(ctx, hash)
-		if err != nil {
-			log.Fatalf("could not get trackers of torrent: %s err: %q", hash, err)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
 		}
 
-		// check if status not working or something else
-		_, working := findTrackerStatus(trackers, 2)
-		if working {
-			announceOK = true
-			break
-		}
+		if err := queue(); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
 
-		if err = qb.ReAnnounceTorrentsCtx(ctx, []string{hash}); err != nil {
-			return err
+			log.Printf("could not queue torrents: %q\n", err)
 		}
-
-		time.Sleep(time.Duration(interval) * time.Millisecond)
-		attempt++
-		continue
 	}
+}
 
-	if !announceOK {
-		log.Println("announce still not ok")
-		return errors.New("announce still not ok")
+// needsReannounce reports whether the torrent is running without a working tracker
+func needsReannounce(t qbittorrent.Torrent) bool {
+	switch t.State {
+	case qbittorrent.TorrentStatePausedDl, qbittorrent.TorrentStatePausedUp, qbittorrent.TorrentStateStoppedDl, qbittorrent.TorrentStateStoppedUp,
+		qbittorrent.TorrentStateError, qbittorrent.TorrentStateMissingFiles:
+		return false
 	}
 
-	return nil
+	return t.Tracker == ""
 }
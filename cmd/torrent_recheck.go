@@ -20,20 +20,31 @@ func RunTorrentRecheck() *cobra.Command {
 	var command = &cobra.Command{
 		Use:   "recheck",
 		Short: "Recheck specified torrent(s)",
-		Long:  `Rechecks torrents indicated by hash(es).`,
+		Long: `Rechecks torrents indicated by hash(es).
+Use - to read newline separated hashes from stdin.`,
 		Example: `  qbt torrent recheck --hashes HASH
   qbt torrent recheck --hashes HASH1,HASH2
+  qbt torrent recheck HASH1 HASH2
+  qbt torrent list --filter errored --output json | jq -r '.[].hash' | qbt torrent recheck -
 `,
 	}
 
 	command.Flags().StringSliceVar(&hashes, "hashes", []string{}, "Add hashes as comma separated list")
 
 	command.RunE = func(cmd *cobra.Command, args []string) error {
+		hashes = append(hashes, args...)
+
+		expanded, err := expandStdin(hashes)
+		if err != nil {
+			return err
+		}
+		hashes = expanded
+
 		if len(hashes) == 0 {
 			return errors.Errorf("no hashes supplied to recheck")
 		}
 
-		err := utils.ValidateHash(hashes)
+		err = utils.ValidateHash(hashes)
 		if err != nil {
 			return errors.Wrap(err, "invalid hashes supplied")
 		}
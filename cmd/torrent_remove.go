@@ -1,9 +1,13 @@
 package cmd
 
 import (
+	"context"
 	"log"
+	"sort"
+	"strings"
 
 	"github.com/ludviglundgren/qbittorrent-cli/internal/config"
+	"github.com/ludviglundgren/qbittorrent-cli/internal/recycle"
 	"github.com/ludviglundgren/qbittorrent-cli/pkg/utils"
 
 	"github.com/autobrr/go-qbittorrent"
@@ -17,6 +21,7 @@ func RunTorrentRemove() *cobra.Command {
 		dryRun          bool
 		removeAll       bool
 		deleteFiles     bool
+		recycleFiles    bool
 		hashes          []string
 		includeCategory []string
 		includeTags     []string
@@ -27,12 +32,20 @@ func RunTorrentRemove() *cobra.Command {
 	var command = &cobra.Command{
 		Use:   "remove",
 		Short: "Removes specified torrent(s)",
-		Long:  `Removes torrents indicated by hash, name or a prefix of either. Whitespace indicates next prefix unless argument is surrounded by quotes`,
+		Long: `Removes torrents indicated by hash, name or a prefix of either. Whitespace indicates next prefix unless argument is surrounded by quotes.
+Use --hashes - to read newline separated hashes from stdin.
+Use --recycle to move the files to the recycle dir from [recycle] in the config instead of deleting them, see qbt recycle.
+Torrents with files that are also used by another torrent, like cross-seeds, are removed without moving their files.
+Files shared by recycled torrents are recycled once, with one of them.`,
+		Example: `  qbt torrent remove --hashes HASH1,HASH2 --delete-files
+  qbt torrent remove --include-category movies --include-tags old --recycle
+  qbt torrent list --filter errored --output json | jq -r '.[].hash' | qbt torrent remove --hashes -`,
 	}
 
 	command.Flags().BoolVar(&dryRun, "dry-run", false, "Display what would be done without actually doing it")
 	command.Flags().BoolVar(&removeAll, "all", false, "Removes all torrents")
 	command.Flags().BoolVar(&deleteFiles, "delete-files", false, "Also delete downloaded files from torrent(s)")
+	command.Flags().BoolVar(&recycleFiles, "recycle", false, "Move downloaded files from torrent(s) to the recycle bin")
 	command.Flags().StringVarP(&filter, "filter", "f", "", "Filter by state: all, active, paused, completed, stalled, errored")
 	command.Flags().StringSliceVar(&hashes, "hashes", []string{}, "Add hashes as comma separated list")
 	command.Flags().StringSliceVarP(&includeCategory, "include-category", "c", []string{}, "Remove torrents from these categories. Comma separated")
@@ -40,14 +53,34 @@ func RunTorrentRemove() *cobra.Command {
 	command.Flags().StringSliceVar(&excludeTags, "exclude-tags", []string{}, "Exclude torrents with provided tags")
 
 	command.RunE = func(cmd *cobra.Command, args []string) error {
+		expanded, err := expandStdin(hashes)
+		if err != nil {
+			return err
+		}
+		hashes = expanded
+
 		if len(hashes) > 0 {
 			if err := utils.ValidateHash(hashes); err != nil {
 				return errors.Wrap(err, "invalid hashes supplied")
 			}
 		}
 
+		if deleteFiles && recycleFiles {
+			return errors.New("--delete-files and --recycle can not be used together")
+		}
+
 		config.InitConfig()
 
+		var bin *recycle.Bin
+		if recycleFiles {
+			b, err := recycleBin(cmd)
+			if err != nil {
+				return err
+			}
+
+			bin = b
+		}
+
 		qbtSettings := qbittorrent.Config{
 			Host:      config.Qbit.Addr,
 			APIKey:    config.Qbit.APIKey,
@@ -122,11 +155,18 @@ func RunTorrentRemove() *cobra.Command {
 				log.Printf("(%d) torrents to be removed\n", len(hashes))
 			}
 
-			err := batchRequests(hashes, func(start, end int) error {
-				return qb.DeleteTorrentsCtx(ctx, hashes[start:end], deleteFiles)
-			})
-			if err != nil {
-				return errors.Wrap(err, "could not delete torrents")
+			if recycleFiles {
+				if err := recycleTorrents(ctx, qb, bin, hashes); err != nil {
+					cmd.SilenceUsage = true
+					return err
+				}
+			} else {
+				err := batchRequests(hashes, func(start, end int) error {
+					return qb.DeleteTorrentsCtx(ctx, hashes[start:end], deleteFiles)
+				})
+				if err != nil {
+					return errors.Wrap(err, "could not delete torrents")
+				}
 			}
 
 			if hashes[0] == "all" {
@@ -143,3 +183,101 @@ func RunTorrentRemove() *cobra.Command {
 
 	return command
 }
+
+// recycleTorrents moves the torrents with hashes to the recycle bin one by one. The hash all recycles all torrents.
+// Torrents with content that is also used by a torrent that is kept are removed without moving their files,
+// and the content shared by recycled torrents is recycled once.
+func recycleTorrents(ctx context.Context, qb *qbittorrent.Client, bin *recycle.Bin, hashes []string) error {
+	all, err := qb.GetTorrentsCtx(ctx, qbittorrent.TorrentFilterOptions{})
+	if err != nil {
+		return errors.Wrap(err, "could not get torrents")
+	}
+
+	torrents := all
+
+	if hashes[0] != "all" {
+		selected := make(map[string]struct{}, len(hashes))
+		for _, hash := range hashes {
+			selected[strings.ToLower(hash)] = struct{}{}
+		}
+
+		torrents = nil
+		for _, t := range all {
+			if _, ok := selected[strings.ToLower(t.Hash)]; ok {
+				torrents = append(torrents, t)
+			}
+		}
+	}
+
+	keepFiles := recycleKeepFiles(all, torrents)
+
+	failed := 0
+
+	for _, t := range torrents {
+		if _, ok := keepFiles[t.Hash]; ok {
+			log.Printf("keeping files of torrent %s %q, they are used by another torrent\n", t.Hash, t.Name)
+
+			if err := qb.DeleteTorrentsCtx(ctx, []string{t.Hash}, false); err != nil {
+				log.Printf("could not remove torrent %s %s: %q\n", t.Hash, t.Name, err)
+				failed++
+				continue
+			}
+
+			log.Printf("removed torrent %s %s without recycling its files\n", t.Hash, t.Name)
+			continue
+		}
+
+		if err := recycleTorrent(ctx, qb, bin, t); err != nil {
+			log.Printf("could not recycle torrent %s %s: %q\n", t.Hash, t.Name, err)
+			failed++
+			continue
+		}
+
+		log.Printf("recycled torrent %s %s\n", t.Hash, t.Name)
+	}
+
+	if failed > 0 {
+		return errors.Errorf("could not recycle %d torrent(s)", failed)
+	}
+
+	return nil
+}
+
+// recycleKeepFiles returns the hashes of the recycled torrents to remove without moving their files: the torrents
+// with content used by a torrent that is kept, and all but one of the recycled torrents sharing content.
+// The torrent with the outermost content path of a group is recycled, so its files include those of the others.
+func recycleKeepFiles(torrents []qbittorrent.Torrent, recycled []qbittorrent.Torrent) map[string]struct{} {
+	keep := crossSeeded(torrents, recycled)
+
+	sorted := append([]qbittorrent.Torrent(nil), recycled...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(contentPath(sorted[i])) < len(contentPath(sorted[j]))
+	})
+
+	var recycledPaths []string
+
+	for _, t := range sorted {
+		if _, ok := keep[t.Hash]; ok {
+			continue
+		}
+
+		p := contentPath(t)
+
+		shared := false
+		for _, r := range recycledPaths {
+			if p == r || strings.HasPrefix(p, r+"/") {
+				shared = true
+				break
+			}
+		}
+
+		if shared {
+			keep[t.Hash] = struct{}{}
+			continue
+		}
+
+		recycledPaths = append(recycledPaths, p)
+	}
+
+	return keep
+}
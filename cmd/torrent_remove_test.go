@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/autobrr/go-qbittorrent"
+)
+
+func Test_recycleKeepFiles(t *testing.T) {
+	torrents := []qbittorrent.Torrent{
+		{Hash: "a", ContentPath: "/data/Movie"},
+		{Hash: "b", ContentPath: "/data/Movie"},
+		{Hash: "c", ContentPath: "/data/Pack/e01.mkv"},
+		{Hash: "d", ContentPath: "/data/Pack"},
+		{Hash: "e", ContentPath: "/data/Show"},
+		{Hash: "f", ContentPath: "/data/Show"},
+		{Hash: "g", ContentPath: "/data/Other"},
+	}
+
+	// f is kept
+	recycled := []qbittorrent.Torrent{torrents[0], torrents[1], torrents[2], torrents[3], torrents[4], torrents[6]}
+
+	var got []string
+	for h := range recycleKeepFiles(torrents, recycled) {
+		got = append(got, h)
+	}
+	sort.Strings(got)
+
+	// b shares with a, c is in d, e shares with the kept f
+	want := []string{"b", "c", "e"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("recycleKeepFiles() = %v, want %v", got, want)
+	}
+}
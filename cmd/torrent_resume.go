@@ -22,10 +22,12 @@ func RunTorrentResume() *cobra.Command {
 	var command = &cobra.Command{
 		Use:   "resume",
 		Short: "Resume specified torrent(s)",
-		Long:  `Resume the torrent(s) indicated by the supplied hash(es), or resume every torrent with --all.`,
+		Long: `Resume the torrent(s) indicated by the supplied hash(es), or resume every torrent with --all.
+Use - to read newline separated hashes from stdin.`,
 		Example: `  qbt torrent resume --all
   qbt torrent resume HASH1 HASH2
-  qbt torrent resume --hashes HASH1,HASH2`,
+  qbt torrent resume --hashes HASH1,HASH2
+  qbt torrent list --output json | jq -r '.[].hash' | qbt torrent resume -`,
 	}
 
 	command.Flags().BoolVar(&resumeAll, "all", false, "resumes all torrents")
@@ -36,6 +38,12 @@ func RunTorrentResume() *cobra.Command {
 		// alongside the --hashes flag.
 		hashes = append(hashes, args...)
 
+		expanded, err := expandStdin(hashes)
+		if err != nil {
+			return err
+		}
+		hashes = expanded
+
 		if resumeAll {
 			hashes = []string{"all"}
 		} else {
@@ -1,11 +1,14 @@
 package cmd
 
 import (
+	"fmt"
 	"log"
 	"strconv"
 	"strings"
 
 	"github.com/ludviglundgren/qbittorrent-cli/internal/config"
+	"github.com/ludviglundgren/qbittorrent-cli/internal/domain"
+	"github.com/ludviglundgren/qbittorrent-cli/internal/trackers"
 	"github.com/ludviglundgren/qbittorrent-cli/pkg/utils"
 
 	"github.com/autobrr/go-qbittorrent"
@@ -22,6 +25,7 @@ func RunTorrentShareLimit() *cobra.Command {
 	}
 
 	command.AddCommand(RunTorrentShareLimitSet())
+	command.AddCommand(RunTorrentShareLimitApply())
 
 	return command
 }
@@ -187,6 +191,221 @@ limit action and mode to their defaults.`,
 	return command
 }
 
+// RunTorrentShareLimitApply cmd to set the share limits of the policies in the config
+func RunTorrentShareLimitApply() *cobra.Command {
+	var (
+		dry         bool
+		concurrency int
+		rate        float64
+	)
+
+	var command = &cobra.Command{
+		Use:   "apply",
+		Short: "Apply share limit policies from config",
+		Long: `Set the share limits of every torrent to the first matching policy from [[share_limits]] in the config,
+followed by the trackers with share limits from [[trackers]]. A policy matches torrents on a tracker on one of its
+domains or their subdomains, in one of its categories and with one of its tags. Empty lists match every torrent.
+
+Limits that are not set in a policy are not changed, and torrents without a matching policy are skipped.
+Only torrents whose limits change are listed.
+
+Trackers are fetched for --concurrency torrents at a time. Use --rate to limit the requests per second to qBittorrent.`,
+		Example: `  qbt torrent share-limit apply --dry-run
+  qbt torrent share-limit apply`,
+	}
+
+	command.Flags().BoolVar(&dry, "dry-run", false, "Run without doing anything")
+	command.Flags().IntVar(&concurrency, "concurrency", trackers.DefaultConcurrency, "Number of torrents to fetch trackers for at the same time")
+	command.Flags().Float64Var(&rate, "rate", 0, "Max requests per second to fetch trackers, 0 is unlimited")
+
+	command.RunE = func(cmd *cobra.Command, args []string) error {
+		if concurrency < 1 {
+			return errors.Errorf("invalid --concurrency: %d", concurrency)
+		}
+
+		config.InitConfig()
+
+		policies, err := shareLimitPolicies(config.ShareLimits, config.Trackers)
+		if err != nil {
+			return err
+		}
+
+		if len(policies) == 0 {
+			return errors.New("no share limit policies in config, add them in [[share_limits]]")
+		}
+
+		qbtSettings := qbittorrent.Config{
+			Host:      config.Qbit.Addr,
+			APIKey:    config.Qbit.APIKey,
+			Username:  config.Qbit.Login,
+			Password:  config.Qbit.Password,
+			BasicUser: config.Qbit.BasicUser,
+			BasicPass: config.Qbit.BasicPass,
+		}
+
+		qb := qbittorrent.NewClient(qbtSettings)
+
+		ctx := cmd.Context()
+
+		if err := qb.LoginCtx(ctx); err != nil {
+			return errors.Wrap(err, "could not login to qbit")
+		}
+
+		torrents, err := qb.GetTorrentsCtx(ctx, qbittorrent.TorrentFilterOptions{})
+		if err != nil {
+			return errors.Wrap(err, "could not get torrents")
+		}
+
+		var domains []string
+		for _, p := range policies {
+			domains = append(domains, p.Domains...)
+		}
+
+		// trackers are only needed when a policy matches on domain
+		urls := make([][]string, len(torrents))
+		if len(domains) > 0 {
+			urls, err = torrentTrackerURLs(ctx, qb, torrents, domains, concurrency, rate)
+			if err != nil {
+				return err
+			}
+		}
+
+		changes := planShareLimits(torrents, urls, policies)
+
+		if len(changes) == 0 {
+			log.Printf("share limits of all (%d) torrents match their policy\n", len(torrents))
+			return nil
+		}
+
+		prefix := ""
+		if dry {
+			prefix = "dry-run: "
+		}
+
+		limits := map[qbittorrent.ShareLimitOptions][]string{}
+
+		for _, c := range changes {
+			log.Printf("%s[%s] %s %q: (%s) -> (%s)\n", prefix, c.Policy, c.Torrent.Hash, c.Torrent.Name, formatShareLimits(c.From), formatShareLimits(c.To))
+
+			limits[c.To] = append(limits[c.To], c.Torrent.Hash)
+		}
+
+		if err := applyShareLimits(ctx, qb, limits, dry); err != nil {
+			return err
+		}
+
+		log.Printf("%schanged share limits of (%d) of (%d) torrents\n", prefix, len(changes), len(torrents))
+
+		return nil
+	}
+
+	return command
+}
+
+// shareLimitPolicies returns the policies from [[share_limits]] followed by the trackers with share limits from [[trackers]]
+func shareLimitPolicies(policies []domain.ShareLimitPolicy, trackers []domain.TrackerConfig) ([]domain.ShareLimitPolicy, error) {
+	var list []domain.ShareLimitPolicy
+
+	for i, p := range policies {
+		if p.Name == "" {
+			p.Name = fmt.Sprintf("share_limits %d", i+1)
+		}
+
+		if !p.ShareLimits.IsSet() {
+			return nil, errors.Errorf("share limit policy %s has no limits", p.Name)
+		}
+
+		if err := validateConfigShareLimits(p.ShareLimits); err != nil {
+			return nil, errors.Wrapf(err, "share limit policy %s", p.Name)
+		}
+
+		list = append(list, p)
+	}
+
+	for _, t := range trackers {
+		if !t.ShareLimits.IsSet() || len(t.Domains) == 0 {
+			continue
+		}
+
+		if err := validateConfigShareLimits(t.ShareLimits); err != nil {
+			return nil, errors.Wrapf(err, "tracker %s in config", t.Domains[0])
+		}
+
+		name := t.Tag
+		if name == "" {
+			name = t.Domains[0]
+		}
+
+		list = append(list, domain.ShareLimitPolicy{Name: name, Domains: t.Domains, ShareLimits: t.ShareLimits})
+	}
+
+	return list, nil
+}
+
+// policyFor returns the first policy that matches the torrent with the tracker urls, or nil
+func policyFor(torrent qbittorrent.Torrent, urls []string, policies []domain.ShareLimitPolicy) *domain.ShareLimitPolicy {
+	for i, p := range policies {
+		if len(p.Categories) > 0 && !containsCategory(p.Categories, torrent.Category) {
+			continue
+		}
+
+		if len(p.Tags) > 0 {
+			if _, ok := validateTag(p.Tags, torrent.Tags); !ok {
+				continue
+			}
+		}
+
+		if len(p.Domains) > 0 {
+			onDomain := false
+			for _, u := range urls {
+				if onHosts(u, p.Domains) {
+					onDomain = true
+					break
+				}
+			}
+
+			if !onDomain {
+				continue
+			}
+		}
+
+		return &policies[i]
+	}
+
+	return nil
+}
+
+// shareLimitChange is a torrent with share limits that change to the limits of a policy
+type shareLimitChange struct {
+	Torrent qbittorrent.Torrent
+	Policy  string
+	From    qbittorrent.ShareLimitOptions
+	To      qbittorrent.ShareLimitOptions
+}
+
+// planShareLimits returns the torrents with share limits that differ from their policy. urls holds the tracker urls per torrent.
+func planShareLimits(torrents []qbittorrent.Torrent, urls [][]string, policies []domain.ShareLimitPolicy) []shareLimitChange {
+	var changes []shareLimitChange
+
+	for i, torrent := range torrents {
+		p := policyFor(torrent, urls[i], policies)
+		if p == nil {
+			continue
+		}
+
+		from := shareLimitOptions(torrent, domain.ShareLimits{})
+		to := shareLimitOptions(torrent, p.ShareLimits)
+
+		if from == to {
+			continue
+		}
+
+		changes = append(changes, shareLimitChange{Torrent: torrent, Policy: p.Name, From: from, To: to})
+	}
+
+	return changes
+}
+
 // validateShareLimits checks that each limit is within qBittorrent's accepted
 // range: -2 (global), -1 (unlimited) or any value >= 0.
 func validateShareLimits(ratioLimit float64, seedingTimeLimit, inactiveSeedingTimeLimit int64) error {
@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"reflect"
 	"testing"
 
+	"github.com/ludviglundgren/qbittorrent-cli/internal/domain"
+
 	"github.com/autobrr/go-qbittorrent"
 )
 
@@ -81,3 +84,73 @@ func Test_formatShareLimits(t *testing.T) {
 		t.Errorf("formatShareLimits() = %v, want %v", got, want)
 	}
 }
+
+func Test_planShareLimits(t *testing.T) {
+	ratio2, ratio1, unlimitedRatio := 2.0, 1.0, -1.0
+	week, unlimited := int64(10080), int64(-1)
+
+	policies, err := shareLimitPolicies(
+		[]domain.ShareLimitPolicy{
+			{Name: "ex-movies", Domains: []string{"example.org"}, Categories: []string{"movies"}, ShareLimits: domain.ShareLimits{Ratio: &ratio2, SeedingTime: &week}},
+			{Name: "keep", Tags: []string{"keep"}, ShareLimits: domain.ShareLimits{Ratio: &unlimitedRatio, SeedingTime: &unlimited}},
+		},
+		[]domain.TrackerConfig{
+			{Domains: []string{"example.org"}, Tag: "EX", ShareLimits: domain.ShareLimits{Ratio: &ratio1}},
+			{Domains: []string{"other.org"}, Tag: "OT"},
+		},
+	)
+	if err != nil {
+		t.Fatalf("shareLimitPolicies() error = %v", err)
+	}
+
+	if len(policies) != 3 {
+		t.Fatalf("shareLimitPolicies() = %d policies, want 3", len(policies))
+	}
+
+	global := qbittorrent.ShareLimitOptions{RatioLimit: -2, SeedingTimeLimit: -2, InactiveSeedingTimeLimit: -2}
+
+	torrents := []qbittorrent.Torrent{
+		{Hash: "a", Category: "movies", RatioLimit: -2, SeedingTimeLimit: -2, InactiveSeedingTimeLimit: -2},
+		{Hash: "b", Category: "movies", RatioLimit: 2, SeedingTimeLimit: 10080, InactiveSeedingTimeLimit: -2},
+		{Hash: "c", Category: "tv", RatioLimit: -2, SeedingTimeLimit: -2, InactiveSeedingTimeLimit: -2},
+		{Hash: "d", Tags: "old, keep", RatioLimit: -2, SeedingTimeLimit: -2, InactiveSeedingTimeLimit: -2},
+		{Hash: "e", Category: "movies", RatioLimit: -2, SeedingTimeLimit: -2, InactiveSeedingTimeLimit: -2},
+	}
+
+	urls := [][]string{
+		{"https://tracker.example.org/pk/announce"},
+		{"https://tracker.example.org/pk/announce"},
+		{"https://dead.org/announce", "https://example.org/pk/announce"},
+		{"https://other.org/announce"},
+		{"https://other.org/announce"},
+	}
+
+	want := []shareLimitChange{
+		{Torrent: torrents[0], Policy: "ex-movies", From: global, To: qbittorrent.ShareLimitOptions{RatioLimit: 2, SeedingTimeLimit: 10080, InactiveSeedingTimeLimit: -2}},
+		{Torrent: torrents[2], Policy: "EX", From: global, To: qbittorrent.ShareLimitOptions{RatioLimit: 1, SeedingTimeLimit: -2, InactiveSeedingTimeLimit: -2}},
+		{Torrent: torrents[3], Policy: "keep", From: global, To: qbittorrent.ShareLimitOptions{RatioLimit: -1, SeedingTimeLimit: -1, InactiveSeedingTimeLimit: -2}},
+	}
+
+	if got := planShareLimits(torrents, urls, policies); !reflect.DeepEqual(got, want) {
+		t.Errorf("planShareLimits() = %+v, want %+v", got, want)
+	}
+}
+
+func Test_shareLimitPolicies_errors(t *testing.T) {
+	invalid := -3.0
+
+	tests := []struct {
+		name     string
+		policies []domain.ShareLimitPolicy
+	}{
+		{name: "no limits", policies: []domain.ShareLimitPolicy{{Name: "empty", Domains: []string{"example.org"}}}},
+		{name: "invalid ratio", policies: []domain.ShareLimitPolicy{{Domains: []string{"example.org"}, ShareLimits: domain.ShareLimits{Ratio: &invalid}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := shareLimitPolicies(tt.policies, nil); err == nil {
+				t.Errorf("shareLimitPolicies() expected error")
+			}
+		})
+	}
+}
@@ -1,11 +1,15 @@
 package cmd
 
 import (
-	"context"
 	"log"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/ludviglundgren/qbittorrent-cli/internal/config"
+	"github.com/ludviglundgren/qbittorrent-cli/internal/issues"
+	"github.com/ludviglundgren/qbittorrent-cli/internal/trackers"
 
 	"github.com/autobrr/go-qbittorrent"
 	"github.com/dustin/go-humanize"
@@ -13,80 +17,6 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var trackerIssues = []string{
-	"tracker is down",
-	"maintenance",
-	"down",
-	"it may be down",
-	"unreachable",
-	"(unreachable)",
-	"bad gateway",
-	"tracker unavailable",
-}
-
-var trackerMessages = []string{
-	"unregistered",
-	"not registered",
-	"torrent not found",
-	"torrent is not found",
-	"unknown torrent",
-	"retitled",
-	"truncated",
-	"torrent is not authorized for use on this tracker",
-	"infohash not found",
-	"not found",
-	"not exist",
-	"unknown",
-	"unknown torrent",
-	"uploaded",
-	"upgraded",
-	"season pack",
-	"packs are available",
-	"pack is available",
-	"internal available",
-	"season pack out",
-	"dead",
-	"dupe",
-	"complete season uploaded",
-	"problem with",
-	"specifically banned",
-	"trump",
-	"trumped",
-	"nuked",
-	"i'm sorry dave, i can't do that",
-	"problem with description",
-	"problem with file",
-	"problem with pack",
-	"specifically banned",
-	"other",
-	"torrent has been deleted",
-}
-
-//var DefaultTags = []string{
-//	"Not Working",
-//	"Unregistered",
-//	"Tracker Down",
-//	//"Duplicate",
-//}
-
-type DefaultTag string
-
-const (
-	DefaultTagUnregistered DefaultTag = "Unregistered"
-	DefaultTagNotWorking   DefaultTag = "Not Working"
-)
-
-func (t DefaultTag) String() string {
-	switch t {
-	case DefaultTagUnregistered:
-		return "Unregistered"
-	case DefaultTagNotWorking:
-		return "Not Working"
-	}
-
-	return ""
-}
-
 // RunTorrentTag cmd for torrent tag operations
 func RunTorrentTag() *cobra.Command {
 	var command = &cobra.Command{
@@ -96,14 +26,15 @@ func RunTorrentTag() *cobra.Command {
 	}
 
 	command.AddCommand(RunTorrentTagNotWorking())
+	command.AddCommand(RunTorrentTagHardlinks())
+	command.AddCommand(RunTorrentTagTrackers())
 
 	return command
 }
 
 type tagData struct {
-	Hashes     []string
-	HashTagMap map[string][]string
-	TotalSize  uint64
+	Hashes    []string
+	TotalSize uint64
 }
 
 // RunTorrentTagNotWorking tag torrents
@@ -112,24 +43,151 @@ func RunTorrentTagNotWorking() *cobra.Command {
 		dryRun          bool
 		tagUnregistered bool
 		tagNotWorking   bool
+		classes         []string
+		concurrency     int
+		rate            float64
+		action          string
+		actionClasses   []string
+		deleteFiles     bool
+		category        string
+		reannounce      bool
+		detections      int
+		interval        time.Duration
+		stateFile       string
 		//size            bool
 	)
 
 	var command = &cobra.Command{
-		Use:     "issues",
-		Short:   "tag torrents with issues",
-		Long:    `Tag torrents that may have broken trackers or be unregistered`,
-		Example: `  qbt torrent tag issues --unregistered --not-working`,
+		Use:   "issues",
+		Short: "tag torrents with issues",
+		Long: `Tag torrents that may have broken trackers or be unregistered.
+
+Tracker messages are matched against the patterns of every issue class, and torrents are tagged with the tag of
+the class. The tag is removed again when the tracker message no longer matches. Built-in classes are unregistered,
+not-working, banned-client, passkey-invalid and rate-limited. The broad unregistered and not-working classes only
+match when no other enabled class matches, so "Unknown passkey" is passkey-invalid and not unregistered when
+--passkey-invalid is set.
+
+Add patterns, change tags and add classes in [issues.classes.<name>] in the config, and add patterns for a tracker
+domain in [[issues.trackers]]. Patterns are case-insensitive regex. A report grouped by tracker and issue is printed.
+
+Trackers are fetched for --concurrency torrents at a time. Use --rate to limit the requests per second to qBittorrent.
+
+Use --action to remove, pause or set --category on torrents with an --action-class issue, unregistered by default.
+The action is only taken after --detections consecutive detections at least --interval apart, so a short tracker
+outage does not remove torrents. Detections are saved in --state-file between runs, and a torrent without the issue
+starts over, like a torrent with a tracker message that also matches a class that is not an --action-class. Use --reannounce to reannounce torrents that are not due yet. With --delete-files the files are only
+deleted when no other torrent, like a cross-seed, uses them.`,
+		Example: `  qbt torrent tag issues --unregistered --not-working
+  qbt torrent tag issues --class banned-client,passkey-invalid --dry-run
+  qbt torrent tag issues --class all --concurrency 20 --rate 50
+  qbt torrent tag issues --action remove --delete-files --detections 3 --interval 6h --reannounce`,
 	}
 
 	command.Flags().BoolVar(&dryRun, "dry-run", false, "Dry run, do not tag torrents")
 	command.Flags().BoolVar(&tagUnregistered, "unregistered", false, "tag unregistered")
 	command.Flags().BoolVar(&tagNotWorking, "not-working", false, "tag not working torrents")
+	command.Flags().StringSliceVar(&classes, "class", []string{}, "tag issue classes, comma separated, or all")
+	command.Flags().IntVar(&concurrency, "concurrency", trackers.DefaultConcurrency, "Number of torrents to fetch trackers for at the same time")
+	command.Flags().Float64Var(&rate, "rate", 0, "Max requests per second to fetch trackers, 0 is unlimited")
+	command.Flags().StringVar(&action, "action", "", "Action for torrents with issues: remove, pause or category")
+	command.Flags().StringSliceVar(&actionClasses, "action-class", []string{issues.ClassUnregistered}, "Issue classes to take the action on, comma separated, or all")
+	command.Flags().BoolVar(&deleteFiles, "delete-files", false, "Also delete the files with --action remove, unless another torrent uses them")
+	command.Flags().StringVar(&category, "category", DefaultIssuesCategory, "Category to set with --action category")
+	command.Flags().BoolVar(&reannounce, "reannounce", false, "Reannounce torrents with issues that are not due for the action yet")
+	command.Flags().IntVar(&detections, "detections", 3, "Consecutive detections before the action is taken")
+	command.Flags().DurationVar(&interval, "interval", time.Hour, "Min time between counted detections")
+	command.Flags().StringVar(&stateFile, "state-file", "", "File to save detections between runs. Defaults to issues.state_file in the config, or qbt-issues-state.json next to the config")
 	//command.Flags().BoolVar(&size, "size", false, "collect size per tag")
 
 	command.RunE = func(cmd *cobra.Command, args []string) error {
 		config.InitConfig()
 
+		classifier, err := issues.NewClassifier(config.Issues)
+		if err != nil {
+			return errors.Wrap(err, "could not load issue classes")
+		}
+
+		if tagUnregistered {
+			classes = append(classes, issues.ClassUnregistered)
+		}
+
+		if tagNotWorking {
+			classes = append(classes, issues.ClassNotWorking)
+		}
+
+		enabled, err := enabledIssueClasses(classifier, classes)
+		if err != nil {
+			return err
+		}
+
+		var actions *issueActions
+
+		if action != "" {
+			switch action {
+			case IssueActionRemove, IssueActionPause, IssueActionCategory:
+			default:
+				return errors.Errorf("invalid --action: %s", action)
+			}
+
+			if deleteFiles && action != IssueActionRemove {
+				return errors.New("--delete-files can only be used with --action remove")
+			}
+
+			if action == IssueActionCategory && category == "" {
+				return errors.New("--category can not be empty with --action category")
+			}
+
+			if detections < 1 {
+				return errors.Errorf("invalid --detections: %d", detections)
+			}
+
+			if interval < 0 {
+				return errors.Errorf("invalid --interval: %s", interval)
+			}
+
+			actionEnabled, err := enabledIssueClasses(classifier, actionClasses)
+			if err != nil {
+				return err
+			}
+
+			// the action classes are tagged too
+			names := make([]string, 0, len(enabled)+len(actionEnabled))
+			for _, class := range append(enabled, actionEnabled...) {
+				names = append(names, class.Name)
+			}
+
+			enabled, _ = enabledIssueClasses(classifier, names)
+
+			if stateFile == "" {
+				stateFile = config.Issues.StateFile
+			}
+			if stateFile == "" {
+				stateFile = filepath.Join(config.Dir(), issuesStateFile)
+			}
+
+			actions = &issueActions{
+				Action:      action,
+				Classes:     actionEnabled,
+				DeleteFiles: deleteFiles,
+				Category:    category,
+				Reannounce:  reannounce,
+				Detections:  detections,
+				Interval:    interval,
+				DryRun:      dryRun,
+			}
+		} else if deleteFiles || reannounce {
+			return errors.New("--delete-files and --reannounce can only be used with --action")
+		}
+
+		if concurrency < 1 {
+			return errors.Errorf("invalid --concurrency: %d", concurrency)
+		}
+
+		if rate < 0 {
+			return errors.Errorf("invalid --rate: %v", rate)
+		}
+
 		qbtSettings := qbittorrent.Config{
 			Host:      config.Qbit.Addr,
 			APIKey:    config.Qbit.APIKey,
@@ -141,7 +199,7 @@ func RunTorrentTagNotWorking() *cobra.Command {
 
 		qb := qbittorrent.NewClient(qbtSettings)
 
-		ctx := context.Background()
+		ctx := cmd.Context()
 
 		if err := qb.LoginCtx(ctx); err != nil {
 			return errors.Wrap(err, "could not login to qbit")
@@ -154,184 +212,247 @@ func RunTorrentTagNotWorking() *cobra.Command {
 
 		var totalSize uint64
 
-		unregisteredTorrents := &tagData{
-			Hashes:    []string{},
-			TotalSize: 0,
-		}
-
-		notWorkingTorrents := &tagData{
-			Hashes:    []string{},
-			TotalSize: 0,
-		}
+		report := newIssueReport(enabled)
 
-		removeTaggedTorrents := &tagData{
-			Hashes: []string{},
-			HashTagMap: map[string][]string{
-				DefaultTagUnregistered.String(): {},
-				DefaultTagNotWorking.String():   {},
-			},
-			TotalSize: 0,
+		results, err := fetchTrackers(ctx, qb, torrents, concurrency, rate)
+		if err != nil {
+			return errors.Wrap(err, "could not get trackers")
 		}
 
 		// process each torrent and check if tags should be added or removed
-		for _, torrent := range torrents {
-			totalSize += uint64(torrent.Size)
+		for _, res := range results {
+			totalSize += uint64(res.Torrent.Size)
 
-			trackers, err := qb.GetTorrentTrackersCtx(ctx, torrent.Hash)
-			if err != nil {
-
-				return errors.Wrapf(err, "could not get trackers for torrent: %s", torrent.Hash)
+			if res.Err != nil {
+				return errors.Wrapf(res.Err, "could not get trackers for torrent: %s", res.Torrent.Hash)
 			}
 
-			processTorrentTags(torrent, trackers, removeTaggedTorrents, unregisteredTorrents, notWorkingTorrents, tagUnregistered, tagUnregistered)
+			processTorrentTags(res.Torrent, res.Trackers, classifier, enabled, report)
 		}
 
 		log.Printf("total torrents (%d) with a total size of: %s\n", len(torrents), humanize.Bytes(totalSize))
 
-		// remove tags from torrents that should not have certain tags
-		if dryRun {
-			log.Printf("dry-run: clearing defualt tags from torrents\n")
-		} else {
-			log.Printf("clearing defualt tags from torrents\n")
+		report.print()
 
-			for tag, hashes := range removeTaggedTorrents.HashTagMap {
-				err := batchRequests(hashes, func(start, end int) error {
-					return qb.RemoveTagsCtx(ctx, hashes[start:end], tag)
+		for _, class := range enabled {
+			// remove tags from torrents that no longer have the issue
+			untag := report.Untag[class.Name]
+
+			if dryRun {
+				log.Printf("dry-run: clearing tag %s from (%d) torrents\n", class.Tag, len(untag))
+			} else if len(untag) > 0 {
+				err := batchRequests(untag, func(start, end int) error {
+					return qb.RemoveTagsCtx(ctx, untag[start:end], class.Tag)
 				})
 				if err != nil {
-					return errors.Wrapf(err, "could not remove tag %s from torrents %v", tag, hashes)
+					return errors.Wrapf(err, "could not remove tag %s from torrents %v", class.Tag, untag)
 				}
 
-				log.Printf("successfully cleared tags from (%d) torrents\n", len(hashes))
+				log.Printf("successfully cleared tag %s from (%d) torrents\n", class.Tag, len(untag))
 			}
-		}
 
-		// --unregistered add tag unregistered
-		if tagUnregistered {
-			countUnregisteredTorrents := len(unregisteredTorrents.Hashes)
+			found := report.Tag[class.Name]
+			count := len(found.Hashes)
 
-			log.Printf("reclaimable space (%s) from (%d) unregistered torrents\n", humanize.Bytes(unregisteredTorrents.TotalSize), countUnregisteredTorrents)
+			log.Printf("reclaimable space (%s) from (%d) %s torrents\n", humanize.Bytes(found.TotalSize), count, class.Name)
 
 			if dryRun {
-				log.Printf("dry-run: tagging (%d) unregistered torrents\n", countUnregisteredTorrents)
-				log.Printf("dry-run: successfully tagged (%d) unregistered torrents\n", countUnregisteredTorrents)
-			} else {
-				log.Printf("tagging (%d) unregistered torrents\n", countUnregisteredTorrents)
+				log.Printf("dry-run: tagging (%d) %s torrents\n", count, class.Name)
+				log.Printf("dry-run: successfully tagged (%d) %s torrents\n", count, class.Name)
+				continue
+			}
 
-				err := batchRequests(unregisteredTorrents.Hashes, func(start, end int) error {
-					return qb.AddTagsCtx(ctx, unregisteredTorrents.Hashes[start:end], DefaultTagUnregistered.String())
-				})
-				if err != nil {
+			if count == 0 {
+				continue
+			}
 
-					return errors.Wrapf(err, "could not add tag %s to torrents %v", DefaultTagUnregistered, unregisteredTorrents.Hashes)
-				}
+			log.Printf("tagging (%d) %s torrents\n", count, class.Name)
 
-				log.Printf("successfully tagged (%d) unregistered torrents\n", countUnregisteredTorrents)
+			err := batchRequests(found.Hashes, func(start, end int) error {
+				return qb.AddTagsCtx(ctx, found.Hashes[start:end], class.Tag)
+			})
+			if err != nil {
+				return errors.Wrapf(err, "could not add tag %s to torrents %v", class.Tag, found.Hashes)
 			}
-		}
 
-		if tagNotWorking {
-			countNotWorkingTorrents := len(notWorkingTorrents.Hashes)
+			log.Printf("successfully tagged (%d) %s torrents\n", count, class.Name)
+		}
 
-			log.Printf("reclaimable space (%s) from (%d) not working torrents\n", humanize.Bytes(notWorkingTorrents.TotalSize), countNotWorkingTorrents)
+		if actions == nil {
+			return nil
+		}
 
-			if dryRun {
-				log.Printf("dry-run: tagging (%d) not working torrents\n", countNotWorkingTorrents)
-				log.Printf("dry-run: successfully tagged (%d) not working torrents\n", countNotWorkingTorrents)
-			} else {
-				log.Printf("tagging (%d) not working torrents\n", len(notWorkingTorrents.Hashes))
+		state, err := issues.LoadState(stateFile)
+		if err != nil {
+			return err
+		}
 
-				err := batchRequests(notWorkingTorrents.Hashes, func(start, end int) error {
-					return qb.AddTagsCtx(ctx, notWorkingTorrents.Hashes[start:end], DefaultTagNotWorking.String())
-				})
-				if err != nil {
-					return errors.Wrapf(err, "could not add tag %s to torrents %v", DefaultTagNotWorking, notWorkingTorrents.Hashes)
-				}
+		if err := actions.run(ctx, qb, torrents, state, report); err != nil {
+			return err
+		}
 
-				log.Printf("successfully tagged (%d) not working torrents\n", countNotWorkingTorrents)
-			}
+		if dryRun {
+			log.Printf("dry-run: not saving detections to %s\n", stateFile)
+			return nil
 		}
 
-		return nil
+		return state.Save()
 	}
 
 	return command
 }
 
-func processTorrentTags(torrent qbittorrent.Torrent, trackers []qbittorrent.TorrentTracker, removeTaggedTorrents *tagData, unregisteredTorrents *tagData, notWorkingTorrents *tagData, tagUnregistered bool, tagNotWorking bool) bool {
-	var isUnregistered bool
-	var isNotWorking bool
-	//var isTrackerDown bool
+// enabledIssueClasses returns the classes by name, or all classes for all
+func enabledIssueClasses(classifier *issues.Classifier, names []string) ([]issues.Class, error) {
+	var enabled []issues.Class
+	seen := map[string]struct{}{}
 
-	// check for our default tags to first clear
-	if torrent.Tags != "" {
-		torrentTags := strings.Split(torrent.Tags, ", ")
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
 
-		for _, tag := range torrentTags {
-			if strings.Contains(tag, DefaultTagUnregistered.String()) {
-				isUnregistered = true
-			}
+		if name == "all" {
+			return classifier.Classes(), nil
+		}
 
-			if strings.Contains(tag, DefaultTagNotWorking.String()) {
-				isNotWorking = true
+		class, ok := classifier.Class(name)
+		if !ok {
+			var available []string
+			for _, c := range classifier.Classes() {
+				available = append(available, c.Name)
 			}
+
+			return nil, errors.Errorf("unknown issue class: %s. Available classes: %s", name, strings.Join(available, ", "))
 		}
+
+		if _, ok := seen[class.Name]; ok {
+			continue
+		}
+		seen[class.Name] = struct{}{}
+
+		enabled = append(enabled, class)
+	}
+
+	return enabled, nil
+}
+
+// issueReport collects the torrents to tag and untag per issue class, and the torrents with issues per tracker
+type issueReport struct {
+	// Tag holds the torrents to tag per class
+	Tag map[string]*tagData
+	// Untag holds the torrents to remove the class tag from
+	Untag map[string][]string
+	// Trackers holds the torrents with an issue per tracker domain and class, tagged or not
+	Trackers map[string]map[string]*tagData
+	// Found holds the torrents with an issue per class, tagged or not
+	Found map[string][]qbittorrent.Torrent
+	// Matched holds the names of the classes each tracker message of a torrent matched per hash, enabled or not
+	Matched map[string][][]string
+}
+
+func newIssueReport(enabled []issues.Class) *issueReport {
+	r := &issueReport{
+		Tag:      map[string]*tagData{},
+		Untag:    map[string][]string{},
+		Trackers: map[string]map[string]*tagData{},
+		Found:    map[string][]qbittorrent.Torrent{},
+		Matched:  map[string][][]string{},
+	}
+
+	for _, class := range enabled {
+		r.Tag[class.Name] = &tagData{Hashes: []string{}}
+	}
+
+	return r
+}
+
+// print logs the torrents with issues grouped by tracker and class
+func (r *issueReport) print() {
+	domains := make([]string, 0, len(r.Trackers))
+	for d := range r.Trackers {
+		domains = append(domains, d)
 	}
+	sort.Strings(domains)
 
-	foundTrackerUnregistered := false
-	foundTrackerNotWorking := false
+	for _, d := range domains {
+		log.Printf("%s:\n", d)
+
+		names := make([]string, 0, len(r.Trackers[d]))
+		for name := range r.Trackers[d] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			data := r.Trackers[d][name]
+			log.Printf("  %s: (%d) torrents with a total size of: %s\n", name, len(data.Hashes), humanize.Bytes(data.TotalSize))
+		}
+	}
+}
+
+// processTorrentTags matches the tracker messages of the torrent against the enabled classes and adds the torrent to the report
+func processTorrentTags(torrent qbittorrent.Torrent, trackers []qbittorrent.TorrentTracker, classifier *issues.Classifier, enabled []issues.Class, report *issueReport) {
+	var torrentTags []string
+	if torrent.Tags != "" {
+		torrentTags = strings.Split(torrent.Tags, ", ")
+	}
+
+	// found holds the tracker domain per class with an issue
+	found := map[string]string{}
 
 	for _, tracker := range trackers {
 		if tracker.Status == qbittorrent.TrackerStatusDisabled {
 			continue
 		}
 
-		//if tracker.Url == "** [DHT] **" || tracker.Url == "** [PeX] **" || tracker.Url == "** [LSD] **" {
-		//	continue
-		//}
-		lowerTrackerMessage := strings.ToLower(tracker.Message)
-
-		if tagUnregistered {
-			for _, msg := range trackerMessages {
-				if strings.Contains(lowerTrackerMessage, msg) {
-					//isUnregistered = true
-					foundTrackerUnregistered = true
-					break
-				}
+		names := classifier.Match(tracker.Url, tracker.Message, enabled)
+		for _, name := range names {
+			if _, ok := found[name]; !ok {
+				found[name] = issues.Domain(tracker.Url)
 			}
 		}
 
-		if tagNotWorking {
-			for _, msg := range trackerIssues {
-				if strings.Contains(lowerTrackerMessage, msg) {
-					//isNotWorking = true
-					foundTrackerNotWorking = true
-					break
-				}
-			}
+		if len(names) > 0 {
+			report.Matched[torrent.Hash] = append(report.Matched[torrent.Hash], names)
 		}
 	}
 
-	// if initial status was isNotWorking and the tracker message changed we need to clear the tag for the hash
-	if isNotWorking && !foundTrackerNotWorking {
-		removeTaggedTorrents.HashTagMap[DefaultTagNotWorking.String()] = append(removeTaggedTorrents.HashTagMap[DefaultTagUnregistered.String()], torrent.Hash)
-	}
+	for _, class := range enabled {
+		// check for the class tag to first clear
+		tagged := false
+		for _, tag := range torrentTags {
+			if tag == class.Tag {
+				tagged = true
+				break
+			}
+		}
 
-	if isUnregistered && !foundTrackerUnregistered {
-		removeTaggedTorrents.HashTagMap[DefaultTagUnregistered.String()] = append(unregisteredTorrents.HashTagMap[DefaultTagUnregistered.String()], torrent.Hash)
-	}
+		d, hasIssue := found[class.Name]
 
-	// found new torrent to tag
-	if !isUnregistered && foundTrackerUnregistered {
-		unregisteredTorrents.TotalSize += uint64(torrent.Size)
-		unregisteredTorrents.Hashes = append(unregisteredTorrents.Hashes, torrent.Hash)
-	}
+		// if the tracker message changed we need to clear the tag for the hash
+		if tagged && !hasIssue {
+			report.Untag[class.Name] = append(report.Untag[class.Name], torrent.Hash)
+		}
 
-	// found new torrent to tag
-	if !isNotWorking && foundTrackerNotWorking {
-		notWorkingTorrents.TotalSize += uint64(torrent.Size)
-		notWorkingTorrents.Hashes = append(notWorkingTorrents.Hashes, torrent.Hash)
-	}
+		if !hasIssue {
+			continue
+		}
+
+		if report.Trackers[d] == nil {
+			report.Trackers[d] = map[string]*tagData{}
+		}
+		if report.Trackers[d][class.Name] == nil {
+			report.Trackers[d][class.Name] = &tagData{}
+		}
 
-	return true
+		report.Trackers[d][class.Name].Hashes = append(report.Trackers[d][class.Name].Hashes, torrent.Hash)
+		report.Trackers[d][class.Name].TotalSize += uint64(torrent.Size)
+
+		report.Found[class.Name] = append(report.Found[class.Name], torrent)
+
+		// found new torrent to tag
+		if !tagged {
+			report.Tag[class.Name].Hashes = append(report.Tag[class.Name].Hashes, torrent.Hash)
+			report.Tag[class.Name].TotalSize += uint64(torrent.Size)
+		}
+	}
 }
@@ -0,0 +1,302 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ludviglundgren/qbittorrent-cli/internal/issues"
+
+	"github.com/autobrr/go-qbittorrent"
+	"github.com/pkg/errors"
+)
+
+// Actions for torrents with issues
+const (
+	IssueActionRemove   = "remove"
+	IssueActionPause    = "pause"
+	IssueActionCategory = "category"
+)
+
+const (
+	DefaultIssuesCategory = "issues"
+	issuesStateFile       = "qbt-issues-state.json"
+)
+
+// issueActions is the action to take on torrents with an issue of one of the Classes
+// after Detections consecutive detections at least Interval apart
+type issueActions struct {
+	Action      string
+	Classes     []issues.Class
+	DeleteFiles bool
+	Category    string
+	Reannounce  bool
+	Detections  int
+	Interval    time.Duration
+	DryRun      bool
+}
+
+// plan counts the detections of the torrents in the report and returns the torrents that are due for the action,
+// and the torrents to reannounce because they are not due yet. Torrents without the issue start over, like torrents
+// where every tracker message with the class also matched a class without the action.
+func (a issueActions) plan(state *issues.State, report *issueReport, now time.Time) ([]qbittorrent.Torrent, []string) {
+	var (
+		due        []qbittorrent.Torrent
+		reannounce []string
+	)
+
+	seenDue := map[string]struct{}{}
+	seenReannounce := map[string]struct{}{}
+
+	actionClasses := make(map[string]struct{}, len(a.Classes))
+	for _, class := range a.Classes {
+		actionClasses[class.Name] = struct{}{}
+	}
+
+	// other returns the first class without the action that a tracker message with the class also matched,
+	// or an empty string when some tracker message only matched classes with the action
+	other := func(hash, class string) string {
+		conflict := ""
+		for _, names := range report.Matched[hash] {
+			if !slices.Contains(names, class) {
+				continue
+			}
+
+			found := ""
+			for _, name := range names {
+				if _, ok := actionClasses[name]; !ok {
+					found = name
+					break
+				}
+			}
+
+			if found == "" {
+				return ""
+			}
+			if conflict == "" {
+				conflict = found
+			}
+		}
+
+		return conflict
+	}
+
+	for _, class := range a.Classes {
+		detected := map[string]struct{}{}
+
+		for _, torrent := range report.Found[class.Name] {
+			if name := other(torrent.Hash, class.Name); name != "" {
+				log.Printf("torrent %s %q has %s and %s, skipping action\n", torrent.Hash, torrent.Name, class.Name, name)
+				continue
+			}
+
+			hash := strings.ToLower(torrent.Hash)
+			detected[hash] = struct{}{}
+
+			count, counted := state.Observe(hash, class.Name, now, a.Interval)
+
+			if count >= a.Detections {
+				if _, ok := seenDue[hash]; !ok {
+					seenDue[hash] = struct{}{}
+					due = append(due, torrent)
+				}
+
+				continue
+			}
+
+			log.Printf("torrent %s %q has %s: detection (%d/%d)\n", torrent.Hash, torrent.Name, class.Name, count, a.Detections)
+
+			if a.Reannounce && counted {
+				if _, ok := seenReannounce[hash]; !ok {
+					seenReannounce[hash] = struct{}{}
+					reannounce = append(reannounce, torrent.Hash)
+				}
+			}
+		}
+
+		state.ResetMissing(class.Name, detected)
+	}
+
+	return due, reannounce
+}
+
+// run reannounces the torrents that are not due yet and takes the action on the due torrents
+func (a issueActions) run(ctx context.Context, qb *qbittorrent.Client, torrents []qbittorrent.Torrent, state *issues.State, report *issueReport) error {
+	due, reannounce := a.plan(state, report, time.Now())
+
+	if len(reannounce) > 0 {
+		if a.DryRun {
+			log.Printf("dry-run: reannouncing (%d) torrents\n", len(reannounce))
+		} else {
+			err := batchRequests(reannounce, func(start, end int) error {
+				return qb.ReAnnounceTorrentsCtx(ctx, reannounce[start:end])
+			})
+			if err != nil {
+				return errors.Wrap(err, "could not reannounce torrents")
+			}
+
+			log.Printf("reannounced (%d) torrents\n", len(reannounce))
+		}
+	}
+
+	if len(due) == 0 {
+		log.Printf("found no torrents due for action %s\n", a.Action)
+		return nil
+	}
+
+	hashes := make([]string, 0, len(due))
+	for _, torrent := range due {
+		hashes = append(hashes, torrent.Hash)
+
+		if a.DryRun {
+			log.Printf("dry-run: %s torrent %s %q\n", a.Action, torrent.Hash, torrent.Name)
+		} else {
+			log.Printf("%s torrent %s %q\n", a.Action, torrent.Hash, torrent.Name)
+		}
+	}
+
+	var err error
+
+	switch a.Action {
+	case IssueActionRemove:
+		err = a.remove(ctx, qb, torrents, due)
+
+	case IssueActionPause:
+		if a.DryRun {
+			log.Printf("dry-run: pausing (%d) torrents\n", len(hashes))
+			break
+		}
+
+		err = batchRequests(hashes, func(start, end int) error {
+			return qb.PauseCtx(ctx, hashes[start:end])
+		})
+
+	case IssueActionCategory:
+		err = setTorrentsCategory(ctx, qb, hashes, a.Category, a.DryRun)
+	}
+
+	if err != nil {
+		return errors.Wrapf(err, "could not %s torrents", a.Action)
+	}
+
+	// the action is done so the torrents start over if they show up again
+	for _, hash := range hashes {
+		for _, class := range a.Classes {
+			state.Reset(hash, class.Name)
+		}
+	}
+
+	log.Printf("successfully took action %s on (%d) torrents\n", a.Action, len(hashes))
+
+	return nil
+}
+
+// remove deletes the torrents, with their files when DeleteFiles is set and no other torrent uses the files
+func (a issueActions) remove(ctx context.Context, qb *qbittorrent.Client, torrents []qbittorrent.Torrent, due []qbittorrent.Torrent) error {
+	shared := crossSeeded(torrents, due)
+
+	var withFiles, withoutFiles []string
+
+	for _, torrent := range due {
+		if _, ok := shared[torrent.Hash]; a.DeleteFiles && !ok {
+			withFiles = append(withFiles, torrent.Hash)
+			continue
+		}
+
+		if a.DeleteFiles {
+			log.Printf("keeping files of torrent %s %q, they are used by another torrent\n", torrent.Hash, torrent.Name)
+		}
+
+		withoutFiles = append(withoutFiles, torrent.Hash)
+	}
+
+	if a.DryRun {
+		log.Printf("dry-run: removing (%d) torrents with files and (%d) torrents without files\n", len(withFiles), len(withoutFiles))
+		return nil
+	}
+
+	for _, group := range []struct {
+		hashes      []string
+		deleteFiles bool
+	}{
+		{hashes: withFiles, deleteFiles: true},
+		{hashes: withoutFiles, deleteFiles: false},
+	} {
+		err := batchRequests(group.hashes, func(start, end int) error {
+			return qb.DeleteTorrentsCtx(ctx, group.hashes[start:end], group.deleteFiles)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setTorrentsCategory sets the category of the torrents and creates it first when missing
+func setTorrentsCategory(ctx context.Context, qb *qbittorrent.Client, hashes []string, category string, dry bool) error {
+	categories, err := qb.GetCategoriesCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "could not get categories")
+	}
+
+	if _, ok := categories[category]; !ok {
+		if dry {
+			log.Printf("dry-run: creating category %s\n", category)
+		} else if err := qb.CreateCategoryCtx(ctx, category, ""); err != nil {
+			return errors.Wrapf(err, "could not create category %s", category)
+		}
+	}
+
+	if dry {
+		log.Printf("dry-run: setting category %s on (%d) torrents\n", category, len(hashes))
+		return nil
+	}
+
+	return batchRequests(hashes, func(start, end int) error {
+		return qb.SetCategoryCtx(ctx, hashes[start:end], category)
+	})
+}
+
+// crossSeeded returns the hashes of the removed torrents with content that is also used by a torrent that is kept
+func crossSeeded(torrents []qbittorrent.Torrent, removed []qbittorrent.Torrent) map[string]struct{} {
+	remove := map[string]struct{}{}
+	for _, t := range removed {
+		remove[t.Hash] = struct{}{}
+	}
+
+	var kept []string
+	for _, t := range torrents {
+		if _, ok := remove[t.Hash]; !ok {
+			kept = append(kept, contentPath(t))
+		}
+	}
+
+	shared := map[string]struct{}{}
+
+	for _, t := range removed {
+		p := contentPath(t)
+
+		for _, k := range kept {
+			if k == p || strings.HasPrefix(k, p+"/") || strings.HasPrefix(p, k+"/") {
+				shared[t.Hash] = struct{}{}
+				break
+			}
+		}
+	}
+
+	return shared
+}
+
+// contentPath returns the path of the torrent content with forward slashes
+func contentPath(t qbittorrent.Torrent) string {
+	p := t.ContentPath
+	if p == "" {
+		p = filepath.Join(t.SavePath, t.Name)
+	}
+
+	return cleanSavePath(strings.ReplaceAll(p, "\\", "/"))
+}
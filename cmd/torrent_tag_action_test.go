@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ludviglundgren/qbittorrent-cli/internal/issues"
+
+	"github.com/autobrr/go-qbittorrent"
+)
+
+func Test_issueActions_plan(t *testing.T) {
+	state, err := issues.LoadState(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := issueActions{
+		Action:     IssueActionPause,
+		Classes:    []issues.Class{{Name: issues.ClassUnregistered}},
+		Reannounce: true,
+		Detections: 2,
+		Interval:   time.Hour,
+	}
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	reportWith := func(hashes ...string) *issueReport {
+		r := newIssueReport(a.Classes)
+		for _, h := range hashes {
+			r.Found[issues.ClassUnregistered] = append(r.Found[issues.ClassUnregistered], qbittorrent.Torrent{Hash: h})
+		}
+		return r
+	}
+
+	hashes := func(torrents []qbittorrent.Torrent) []string {
+		var list []string
+		for _, t := range torrents {
+			list = append(list, t.Hash)
+		}
+		return list
+	}
+
+	steps := []struct {
+		name           string
+		found          []string
+		after          time.Duration
+		wantDue        []string
+		wantReannounce []string
+	}{
+		{name: "first detection", found: []string{"a", "b"}, after: 0, wantDue: nil, wantReannounce: []string{"a", "b"}},
+		{name: "too soon", found: []string{"a", "b"}, after: 10 * time.Minute, wantDue: nil, wantReannounce: nil},
+		{name: "b recovered", found: []string{"a"}, after: 30 * time.Minute, wantDue: nil, wantReannounce: nil},
+		{name: "a due, b starts over", found: []string{"a", "b"}, after: time.Hour, wantDue: []string{"a"}, wantReannounce: []string{"b"}},
+	}
+	for _, step := range steps {
+		due, reannounce := a.plan(state, reportWith(step.found...), now.Add(step.after))
+
+		if got := hashes(due); !reflect.DeepEqual(got, step.wantDue) {
+			t.Errorf("%s: plan() due = %v, want %v", step.name, got, step.wantDue)
+		}
+		if !reflect.DeepEqual(reannounce, step.wantReannounce) {
+			t.Errorf("%s: plan() reannounce = %v, want %v", step.name, reannounce, step.wantReannounce)
+		}
+	}
+}
+
+func Test_issueActions_plan_otherClass(t *testing.T) {
+	state, err := issues.LoadState(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := issueActions{
+		Action:     IssueActionRemove,
+		Classes:    []issues.Class{{Name: issues.ClassUnregistered}},
+		Detections: 1,
+	}
+
+	r := newIssueReport(a.Classes)
+	r.Found[issues.ClassUnregistered] = []qbittorrent.Torrent{{Hash: "a"}, {Hash: "b"}}
+	// a has one tracker message "Unknown passkey" that matched both classes
+	r.Matched["a"] = [][]string{{issues.ClassPasskeyInvalid, issues.ClassUnregistered}}
+	// b is unregistered on one tracker and down on another
+	r.Matched["b"] = [][]string{{issues.ClassUnregistered}, {issues.ClassNotWorking}}
+
+	due, _ := a.plan(state, r, time.Now())
+
+	var got []string
+	for _, torrent := range due {
+		got = append(got, torrent.Hash)
+	}
+	if want := []string{"b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("plan() due = %v, want %v", got, want)
+	}
+}
+
+func Test_crossSeeded(t *testing.T) {
+	torrents := []qbittorrent.Torrent{
+		{Hash: "a", ContentPath: "/data/Movie"},
+		{Hash: "b", ContentPath: "/data/Movie/"},
+		{Hash: "c", ContentPath: "/data/Show"},
+		{Hash: "d", ContentPath: "/data/Show"},
+		{Hash: "e", SavePath: "/data", Name: "Pack"},
+		{Hash: "f", ContentPath: "/data/Pack/e01.mkv"},
+		{Hash: "g", ContentPath: `D:\data\Other`},
+	}
+
+	removed := []qbittorrent.Torrent{torrents[0], torrents[2], torrents[3], torrents[4], torrents[6]}
+
+	shared := crossSeeded(torrents, removed)
+
+	var got []string
+	for h := range shared {
+		got = append(got, h)
+	}
+	sort.Strings(got)
+
+	// a shares with b, e contains f, c and d are both removed
+	want := []string{"a", "e"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("crossSeeded() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ludviglundgren/qbittorrent-cli/internal/config"
+	"github.com/ludviglundgren/qbittorrent-cli/internal/fs"
+
+	"github.com/autobrr/go-qbittorrent"
+	"github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// DefaultTagNoHardlinks is added to torrents without hardlinked files
+const DefaultTagNoHardlinks = "noHL"
+
+// RunTorrentTagHardlinks cmd to tag torrents without hardlinks
+func RunTorrentTagHardlinks() *cobra.Command {
+	var (
+		dryRun   bool
+		tag      string
+		category string
+		pathMap  map[string]string
+	)
+
+	var command = &cobra.Command{
+		Use:   "hardlinks",
+		Short: "Tag torrents without hardlinks",
+		Long: `Tag completed torrents where no file has a hardlink, like torrents no longer imported into a media library.
+
+The files of every torrent are read from the save path and file list in qBittorrent. The tag is removed again
+from torrents that got hardlinked. Torrents with no files found on disk are skipped.
+
+Use --path-map when qbt sees the files at another path than qBittorrent, like with docker.
+The longest matching qBittorrent path is replaced with the local path.`,
+		Example: `  qbt torrent tag hardlinks --dry-run
+  qbt torrent tag hardlinks --category tv --tag noHL
+  qbt torrent tag hardlinks --path-map /downloads=/mnt/data/downloads`,
+	}
+
+	command.Flags().BoolVar(&dryRun, "dry-run", false, "Dry run, do not tag torrents")
+	command.Flags().StringVar(&tag, "tag", DefaultTagNoHardlinks, "Tag for torrents without hardlinks")
+	command.Flags().StringVarP(&category, "category", "c", "", "Only check torrents with category")
+	command.Flags().StringToStringVar(&pathMap, "path-map", map[string]string{}, "Map a qBittorrent path to a local path, like /downloads=/mnt/data. Can be repeated")
+
+	command.RunE = func(cmd *cobra.Command, args []string) error {
+		if strings.TrimSpace(tag) == "" {
+			return errors.New("--tag can not be empty")
+		}
+
+		config.InitConfig()
+
+		qbtSettings := qbittorrent.Config{
+			Host:      config.Qbit.Addr,
+			APIKey:    config.Qbit.APIKey,
+			Username:  config.Qbit.Login,
+			Password:  config.Qbit.Password,
+			BasicUser: config.Qbit.BasicUser,
+			BasicPass: config.Qbit.BasicPass,
+		}
+
+		qb := qbittorrent.NewClient(qbtSettings)
+
+		ctx := cmd.Context()
+
+		if err := qb.LoginCtx(ctx); err != nil {
+			return errors.Wrap(err, "could not login to qbit")
+		}
+
+		torrents, err := qb.GetTorrentsCtx(ctx, qbittorrent.TorrentFilterOptions{Category: category})
+		if err != nil {
+			return errors.Wrap(err, "could not get torrents")
+		}
+
+		noHardlinks := &tagData{Hashes: []string{}}
+		var untag []string
+
+		for _, torrent := range torrents {
+			if torrent.Progress < 1 {
+				continue
+			}
+
+			files, err := qb.GetFilesInformationCtx(ctx, torrent.Hash)
+			if err != nil {
+				return errors.Wrapf(err, "could not get files for torrent: %s", torrent.Hash)
+			}
+
+			if files == nil {
+				continue
+			}
+
+			savePath := mapPath(torrent.SavePath, pathMap)
+
+			linked, checked, err := hasHardlinks(savePath, *files)
+			if err != nil {
+				return errors.Wrapf(err, "could not check hardlinks for torrent: %s", torrent.Hash)
+			}
+
+			if checked == 0 {
+				log.Printf("no files found on disk for torrent %s %s in %s, skipping\n", torrent.Hash, torrent.Name, savePath)
+				continue
+			}
+
+			_, tagged := validateTag([]string{tag}, torrent.Tags)
+
+			if !linked && !tagged {
+				noHardlinks.Hashes = append(noHardlinks.Hashes, torrent.Hash)
+				noHardlinks.TotalSize += uint64(torrent.Size)
+			}
+
+			if linked && tagged {
+				untag = append(untag, torrent.Hash)
+			}
+		}
+
+		count := len(noHardlinks.Hashes)
+
+		log.Printf("found (%d) new torrent(s) without hardlinks with a total size of: %s\n", count, humanize.Bytes(noHardlinks.TotalSize))
+
+		if dryRun {
+			log.Printf("dry-run: tagging (%d) torrents with %s\n", count, tag)
+			log.Printf("dry-run: clearing %s from (%d) hardlinked torrents\n", tag, len(untag))
+			return nil
+		}
+
+		if count > 0 {
+			err := batchRequests(noHardlinks.Hashes, func(start, end int) error {
+				return qb.AddTagsCtx(ctx, noHardlinks.Hashes[start:end], tag)
+			})
+			if err != nil {
+				return errors.Wrapf(err, "could not add tag %s to torrents %v", tag, noHardlinks.Hashes)
+			}
+
+			log.Printf("successfully tagged (%d) torrents with %s\n", count, tag)
+		}
+
+		if len(untag) > 0 {
+			err := batchRequests(untag, func(start, end int) error {
+				return qb.RemoveTagsCtx(ctx, untag[start:end], tag)
+			})
+			if err != nil {
+				return errors.Wrapf(err, "could not remove tag %s from torrents %v", tag, untag)
+			}
+
+			log.Printf("successfully cleared %s from (%d) hardlinked torrents\n", tag, len(untag))
+		}
+
+		return nil
+	}
+
+	return command
+}
+
+// hasHardlinks reports whether a downloaded file of the torrent in savePath has more than one link,
+// and the number of files found on disk
+func hasHardlinks(savePath string, files qbittorrent.TorrentFiles) (bool, int, error) {
+	checked := 0
+
+	for _, f := range files {
+		if f.Priority == FilePrioritySkip {
+			continue
+		}
+
+		links, err := fs.LinkCount(filepath.Join(savePath, filepath.FromSlash(f.Name)))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return false, checked, err
+		}
+
+		checked++
+
+		if links > 1 {
+			return true, checked, nil
+		}
+	}
+
+	return false, checked, nil
+}
+
+// mapPath replaces the longest matching prefix of path from pathMap, matched on whole folders
+func mapPath(path string, pathMap map[string]string) string {
+	path = cleanSavePath(path)
+
+	var from, to string
+
+	for k, v := range pathMap {
+		k = cleanSavePath(k)
+
+		if path != k && !strings.HasPrefix(path, k+"/") && !strings.HasPrefix(path, k+"\\") {
+			continue
+		}
+
+		if len(k) > len(from) {
+			from, to = k, v
+		}
+	}
+
+	if from == "" {
+		return path
+	}
+
+	return cleanSavePath(to) + path[len(from):]
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/autobrr/go-qbittorrent"
+)
+
+func Test_mapPath(t *testing.T) {
+	pathMap := map[string]string{
+		"/downloads":        "/mnt/data/downloads",
+		"/downloads/movies": "/mnt/movies/",
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "prefix", path: "/downloads/tv", want: "/mnt/data/downloads/tv"},
+		{name: "exact", path: "/downloads/", want: "/mnt/data/downloads"},
+		{name: "longest prefix", path: "/downloads/movies/4k", want: "/mnt/movies/4k"},
+		{name: "same prefix other folder", path: "/downloads2", want: "/downloads2"},
+		{name: "not mapped", path: "/data", want: "/data"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mapPath(tt.path, pathMap); got != tt.want {
+				t.Errorf("mapPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_hasHardlinks(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{"Show/e01.mkv", "Show/e02.mkv", "Show/e03.mkv"} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Link(filepath.Join(dir, "Show", "e02.mkv"), filepath.Join(dir, "library.mkv")); err != nil {
+		t.Skipf("hardlinks not supported: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		files       qbittorrent.TorrentFiles
+		wantLinked  bool
+		wantChecked int
+	}{
+		{
+			name:        "no hardlinks",
+			files:       qbittorrent.TorrentFiles{{Name: "Show/e01.mkv", Priority: 1}, {Name: "Show/e03.mkv", Priority: 1}},
+			wantLinked:  false,
+			wantChecked: 2,
+		},
+		{
+			name:        "hardlinked file",
+			files:       qbittorrent.TorrentFiles{{Name: "Show/e01.mkv", Priority: 1}, {Name: "Show/e02.mkv", Priority: 1}},
+			wantLinked:  true,
+			wantChecked: 2,
+		},
+		{
+			name:        "skipped hardlinked file",
+			files:       qbittorrent.TorrentFiles{{Name: "Show/e01.mkv", Priority: 1}, {Name: "Show/e02.mkv", Priority: 0}},
+			wantLinked:  false,
+			wantChecked: 1,
+		},
+		{
+			name:        "missing files",
+			files:       qbittorrent.TorrentFiles{{Name: "Show/e04.mkv", Priority: 1}},
+			wantLinked:  false,
+			wantChecked: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			linked, checked, err := hasHardlinks(dir, tt.files)
+			if err != nil {
+				t.Fatalf("hasHardlinks() error = %v", err)
+			}
+			if linked != tt.wantLinked || checked != tt.wantChecked {
+				t.Errorf("hasHardlinks() = %v, %v, want %v, %v", linked, checked, tt.wantLinked, tt.wantChecked)
+			}
+		})
+	}
+}
@@ -1,62 +1,127 @@
 package cmd
 
 import (
+	"reflect"
 	"testing"
 
+	"github.com/ludviglundgren/qbittorrent-cli/internal/domain"
+	"github.com/ludviglundgren/qbittorrent-cli/internal/issues"
+
 	"github.com/autobrr/go-qbittorrent"
 )
 
 func Test_processTorrentTags(t *testing.T) {
-	type args struct {
-		torrent              qbittorrent.Torrent
-		trackers             []qbittorrent.TorrentTracker
-		removeTaggedTorrents *tagData
-		unregisteredTorrents *tagData
-		notWorkingTorrents   *tagData
-		tagUnregistered      bool
-		tagNotWorking        bool
+	classifier, err := issues.NewClassifier(domain.IssuesConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enabled, err := enabledIssueClasses(classifier, []string{issues.ClassUnregistered, issues.ClassNotWorking})
+	if err != nil {
+		t.Fatal(err)
 	}
+
 	tests := []struct {
-		name string
-		args args
-		want bool
+		name      string
+		torrent   qbittorrent.Torrent
+		trackers  []qbittorrent.TorrentTracker
+		wantTag   map[string][]string
+		wantUntag map[string][]string
 	}{
 		{
-			name: "test1",
-			args: args{
-				torrent: qbittorrent.Torrent{Hash: "1111", Tracker: "", Tags: "Unregistered"},
-				trackers: []qbittorrent.TorrentTracker{
-					{Url: "http://test.local", Status: 2, NumPeers: 1, NumSeeds: 1, NumLeechers: 0, NumDownloaded: 1, Message: "OK"},
-				},
-				removeTaggedTorrents: &tagData{Hashes: []string{}, HashTagMap: map[string][]string{DefaultTagUnregistered.String(): {}, DefaultTagNotWorking.String(): {}}},
-				unregisteredTorrents: &tagData{Hashes: []string{}},
-				notWorkingTorrents:   &tagData{Hashes: []string{}},
-				tagUnregistered:      true,
-				tagNotWorking:        true,
+			name:    "working tracker clears tag",
+			torrent: qbittorrent.Torrent{Hash: "1111", Tracker: "", Tags: "Unregistered"},
+			trackers: []qbittorrent.TorrentTracker{
+				{Url: "http://test.local", Status: 2, NumPeers: 1, NumSeeds: 1, NumLeechers: 0, NumDownloaded: 1, Message: "OK"},
+			},
+			wantTag:   map[string][]string{},
+			wantUntag: map[string][]string{issues.ClassUnregistered: {"1111"}},
+		},
+		{
+			name:    "unregistered",
+			torrent: qbittorrent.Torrent{Hash: "1111", Tracker: "", Tags: ""},
+			trackers: []qbittorrent.TorrentTracker{
+				{Url: "http://test.local", Status: 1, NumPeers: 1, NumSeeds: 1, NumLeechers: 0, NumDownloaded: 1, Message: "Unregistered torrent"},
+			},
+			wantTag:   map[string][]string{issues.ClassUnregistered: {"1111"}},
+			wantUntag: map[string][]string{},
+		},
+		{
+			name:    "not working",
+			torrent: qbittorrent.Torrent{Hash: "1111", Tracker: "", Tags: "other"},
+			trackers: []qbittorrent.TorrentTracker{
+				{Url: "http://test.local", Status: 4, Message: "Tracker is down"},
+			},
+			wantTag:   map[string][]string{issues.ClassNotWorking: {"1111"}},
+			wantUntag: map[string][]string{},
+		},
+		{
+			name:    "already tagged",
+			torrent: qbittorrent.Torrent{Hash: "1111", Tracker: "", Tags: "Not Working, Unregistered"},
+			trackers: []qbittorrent.TorrentTracker{
+				{Url: "http://test.local", Status: 4, Message: "Tracker is down"},
 			},
-			want: true,
+			wantTag:   map[string][]string{},
+			wantUntag: map[string][]string{issues.ClassUnregistered: {"1111"}},
 		},
 		{
-			name: "test",
-			args: args{
-				torrent: qbittorrent.Torrent{Hash: "1111", Tracker: "", Tags: ""},
-				trackers: []qbittorrent.TorrentTracker{
-					{Url: "http://test.local", Status: 1, NumPeers: 1, NumSeeds: 1, NumLeechers: 0, NumDownloaded: 1, Message: "Unregistered torrent"},
-				},
-				removeTaggedTorrents: &tagData{Hashes: []string{}, HashTagMap: map[string][]string{DefaultTagUnregistered.String(): {}, DefaultTagNotWorking.String(): {}}},
-				unregisteredTorrents: &tagData{Hashes: []string{}},
-				notWorkingTorrents:   &tagData{Hashes: []string{}},
-				tagUnregistered:      true,
-				tagNotWorking:        true,
+			name:    "disabled tracker",
+			torrent: qbittorrent.Torrent{Hash: "1111", Tracker: "", Tags: ""},
+			trackers: []qbittorrent.TorrentTracker{
+				{Url: "http://test.local", Status: qbittorrent.TrackerStatusDisabled, Message: "Unregistered torrent"},
 			},
-			want: true,
+			wantTag:   map[string][]string{},
+			wantUntag: map[string][]string{},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := processTorrentTags(tt.args.torrent, tt.args.trackers, tt.args.removeTaggedTorrents, tt.args.unregisteredTorrents, tt.args.notWorkingTorrents, tt.args.tagUnregistered, tt.args.tagNotWorking); got != tt.want {
-				t.Errorf("processTorrent() = %v, want %v", got, tt.want)
+			report := newIssueReport(enabled)
+
+			processTorrentTags(tt.torrent, tt.trackers, classifier, enabled, report)
+
+			gotTag := map[string][]string{}
+			for name, data := range report.Tag {
+				if len(data.Hashes) > 0 {
+					gotTag[name] = data.Hashes
+				}
+			}
+
+			if !reflect.DeepEqual(gotTag, tt.wantTag) {
+				t.Errorf("processTorrentTags() tag = %v, want %v", gotTag, tt.wantTag)
+			}
+			if !reflect.DeepEqual(report.Untag, tt.wantUntag) {
+				t.Errorf("processTorrentTags() untag = %v, want %v", report.Untag, tt.wantUntag)
 			}
 		})
 	}
 }
+
+func Test_enabledIssueClasses(t *testing.T) {
+	classifier, err := issues.NewClassifier(domain.IssuesConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := enabledIssueClasses(classifier, []string{"Rate-Limited", "unregistered", "rate-limited"})
+	if err != nil {
+		t.Fatalf("enabledIssueClasses() error = %v", err)
+	}
+
+	var names []string
+	for _, c := range got {
+		names = append(names, c.Name)
+	}
+
+	if want := []string{issues.ClassRateLimited, issues.ClassUnregistered}; !reflect.DeepEqual(names, want) {
+		t.Errorf("enabledIssueClasses() = %v, want %v", names, want)
+	}
+
+	if all, _ := enabledIssueClasses(classifier, []string{"all"}); len(all) != len(classifier.Classes()) {
+		t.Errorf("enabledIssueClasses() all = %d classes, want %d", len(all), len(classifier.Classes()))
+	}
+
+	if _, err := enabledIssueClasses(classifier, []string{"missing"}); err == nil {
+		t.Errorf("enabledIssueClasses() expected error for unknown class")
+	}
+}
@@ -0,0 +1,427 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ludviglundgren/qbittorrent-cli/internal/config"
+	"github.com/ludviglundgren/qbittorrent-cli/internal/domain"
+	"github.com/ludviglundgren/qbittorrent-cli/internal/trackers"
+
+	"github.com/autobrr/go-qbittorrent"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// RunTorrentTagTrackers cmd to tag torrents by tracker
+func RunTorrentTagTrackers() *cobra.Command {
+	var (
+		dryRun         bool
+		setCategory    bool
+		setShareLimits bool
+		concurrency    int
+		rate           float64
+	)
+
+	var command = &cobra.Command{
+		Use:   "trackers",
+		Short: "Tag torrents by tracker",
+		Long: `Tag torrents with the tag of their tracker from [[trackers]] in the config, and remove the tags of other
+trackers. The tracker is the current tracker of the torrent, or the first tracker on a configured domain when the
+torrent has no working tracker. Domains match their subdomains too.
+
+Use --set-category to also set the category of the tracker, and --set-share-limits to also set its share limits.
+
+Trackers are fetched for --concurrency torrents at a time. Use --rate to limit the requests per second to qBittorrent.`,
+		Example: `  qbt torrent tag trackers --dry-run
+  qbt torrent tag trackers --set-category --set-share-limits`,
+	}
+
+	command.Flags().BoolVar(&dryRun, "dry-run", false, "Run without doing anything")
+	command.Flags().BoolVar(&setCategory, "set-category", false, "Also set the category of the tracker")
+	command.Flags().BoolVar(&setShareLimits, "set-share-limits", false, "Also set the share limits of the tracker")
+	command.Flags().IntVar(&concurrency, "concurrency", trackers.DefaultConcurrency, "Number of torrents to fetch trackers for at the same time")
+	command.Flags().Float64Var(&rate, "rate", 0, "Max requests per second to fetch trackers, 0 is unlimited")
+
+	command.RunE = func(cmd *cobra.Command, args []string) error {
+		if concurrency < 1 {
+			return errors.Errorf("invalid --concurrency: %d", concurrency)
+		}
+
+		config.InitConfig()
+
+		if len(config.Trackers) == 0 {
+			return errors.New("no trackers in config, add them in [[trackers]]")
+		}
+
+		if err := validateTrackerConfigs(config.Trackers); err != nil {
+			return err
+		}
+
+		qbtSettings := qbittorrent.Config{
+			Host:      config.Qbit.Addr,
+			APIKey:    config.Qbit.APIKey,
+			Username:  config.Qbit.Login,
+			Password:  config.Qbit.Password,
+			BasicUser: config.Qbit.BasicUser,
+			BasicPass: config.Qbit.BasicPass,
+		}
+
+		qb := qbittorrent.NewClient(qbtSettings)
+
+		ctx := cmd.Context()
+
+		if err := qb.LoginCtx(ctx); err != nil {
+			return errors.Wrap(err, "could not login to qbit")
+		}
+
+		torrents, err := qb.GetTorrentsCtx(ctx, qbittorrent.TorrentFilterOptions{})
+		if err != nil {
+			return errors.Wrap(err, "could not get torrents")
+		}
+
+		matched, err := matchTorrentTrackers(ctx, qb, torrents, config.Trackers, concurrency, rate)
+		if err != nil {
+			return err
+		}
+
+		plan := newTrackerTagPlan(config.Trackers)
+
+		for i, torrent := range torrents {
+			plan.add(torrent, matched[i], setCategory, setShareLimits)
+		}
+
+		return plan.apply(ctx, qb, dryRun)
+	}
+
+	return command
+}
+
+func validateTrackerConfigs(configs []domain.TrackerConfig) error {
+	for i, c := range configs {
+		if len(c.Domains) == 0 {
+			return errors.Errorf("tracker %d in config has no domains", i+1)
+		}
+
+		if c.Tag == "" {
+			return errors.Errorf("tracker %s in config has no tag", c.Domains[0])
+		}
+
+		if err := validateConfigShareLimits(c.ShareLimits); err != nil {
+			return errors.Wrapf(err, "tracker %s in config", c.Domains[0])
+		}
+	}
+
+	return nil
+}
+
+// validateConfigShareLimits validates the limits that are set
+func validateConfigShareLimits(limits domain.ShareLimits) error {
+	ratio, seedingTime, inactiveSeedingTime := float64(-2), int64(-2), int64(-2)
+
+	if limits.Ratio != nil {
+		ratio = *limits.Ratio
+	}
+	if limits.SeedingTime != nil {
+		seedingTime = *limits.SeedingTime
+	}
+	if limits.InactiveSeedingTime != nil {
+		inactiveSeedingTime = *limits.InactiveSeedingTime
+	}
+
+	return validateShareLimits(ratio, seedingTime, inactiveSeedingTime)
+}
+
+// matchTorrentTrackers returns the tracker config of every torrent, or nil
+func matchTorrentTrackers(ctx context.Context, qb *qbittorrent.Client, torrents []qbittorrent.Torrent, configs []domain.TrackerConfig, concurrency int, rate float64) ([]*domain.TrackerConfig, error) {
+	var domains []string
+	for _, c := range configs {
+		domains = append(domains, c.Domains...)
+	}
+
+	urls, err := torrentTrackerURLs(ctx, qb, torrents, domains, concurrency, rate)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make([]*domain.TrackerConfig, len(torrents))
+
+	for i := range torrents {
+		for _, u := range urls[i] {
+			if c := trackerConfigFor(u, configs); c != nil {
+				matched[i] = c
+				break
+			}
+		}
+	}
+
+	return matched, nil
+}
+
+// torrentTrackerURLs returns the tracker urls of every torrent. That is the current tracker when it is on one of
+// the domains, or else all trackers of the torrent so a tracker that is not working is found too.
+func torrentTrackerURLs(ctx context.Context, qb *qbittorrent.Client, torrents []qbittorrent.Torrent, domains []string, concurrency int, rate float64) ([][]string, error) {
+	urls := make([][]string, len(torrents))
+
+	var (
+		fetch []qbittorrent.Torrent
+		index []int
+	)
+
+	for i, torrent := range torrents {
+		if torrent.Tracker != "" && onHosts(torrent.Tracker, domains) {
+			urls[i] = []string{torrent.Tracker}
+			continue
+		}
+
+		fetch = append(fetch, torrent)
+		index = append(index, i)
+	}
+
+	if len(fetch) == 0 {
+		return urls, nil
+	}
+
+	results, err := fetchTrackers(ctx, qb, fetch, concurrency, rate)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get trackers")
+	}
+
+	for j, res := range results {
+		if res.Err != nil {
+			return nil, errors.Wrapf(res.Err, "could not get trackers for torrent: %s", res.Torrent.Hash)
+		}
+
+		for _, tracker := range res.Trackers {
+			if !strings.HasPrefix(tracker.Url, "** [") {
+				urls[index[j]] = append(urls[index[j]], tracker.Url)
+			}
+		}
+	}
+
+	return urls, nil
+}
+
+// trackerConfigFor returns the first config with a domain of the tracker url, or nil
+func trackerConfigFor(trackerURL string, configs []domain.TrackerConfig) *domain.TrackerConfig {
+	if trackerURL == "" {
+		return nil
+	}
+
+	for i := range configs {
+		if onHosts(trackerURL, configs[i].Domains) {
+			return &configs[i]
+		}
+	}
+
+	return nil
+}
+
+// shareLimitOptions returns the share limits of the torrent with the limits that are set replaced.
+// Ratios are rounded to 2 decimals like qBittorrent stores them, so they compare equal after they are set.
+func shareLimitOptions(torrent qbittorrent.Torrent, limits domain.ShareLimits) qbittorrent.ShareLimitOptions {
+	opts := qbittorrent.ShareLimitOptions{
+		RatioLimit:               roundRatio(torrent.RatioLimit),
+		SeedingTimeLimit:         torrent.SeedingTimeLimit,
+		InactiveSeedingTimeLimit: torrent.InactiveSeedingTimeLimit,
+		ShareLimitAction:         torrent.ShareLimitAction,
+		ShareLimitsMode:          torrent.ShareLimitsMode,
+	}
+
+	if limits.Ratio != nil {
+		opts.RatioLimit = roundRatio(*limits.Ratio)
+	}
+	if limits.SeedingTime != nil {
+		opts.SeedingTimeLimit = *limits.SeedingTime
+	}
+	if limits.InactiveSeedingTime != nil {
+		opts.InactiveSeedingTimeLimit = *limits.InactiveSeedingTime
+	}
+
+	return opts
+}
+
+// roundRatio rounds the ratio to 2 decimals the same way it is formatted when setting share limits
+func roundRatio(ratio float64) float64 {
+	rounded, err := strconv.ParseFloat(strconv.FormatFloat(ratio, 'f', 2, 64), 64)
+	if err != nil {
+		return ratio
+	}
+
+	return rounded
+}
+
+// trackerTagPlan holds the hashes to tag, untag, set the category and share limits of
+type trackerTagPlan struct {
+	// tags holds every configured tracker tag
+	tags map[string]struct{}
+
+	Tag         map[string][]string
+	Untag       map[string][]string
+	Category    map[string][]string
+	ShareLimits map[qbittorrent.ShareLimitOptions][]string
+}
+
+func newTrackerTagPlan(configs []domain.TrackerConfig) *trackerTagPlan {
+	p := &trackerTagPlan{
+		tags:        map[string]struct{}{},
+		Tag:         map[string][]string{},
+		Untag:       map[string][]string{},
+		Category:    map[string][]string{},
+		ShareLimits: map[qbittorrent.ShareLimitOptions][]string{},
+	}
+
+	for _, c := range configs {
+		p.tags[c.Tag] = struct{}{}
+	}
+
+	return p
+}
+
+// add plans the changes for the torrent with the tracker config c, or nil when it is on no configured tracker
+func (p *trackerTagPlan) add(torrent qbittorrent.Torrent, c *domain.TrackerConfig, setCategory, setShareLimits bool) {
+	tagged := false
+
+	if torrent.Tags != "" {
+		for _, tag := range strings.Split(torrent.Tags, ", ") {
+			if c != nil && tag == c.Tag {
+				tagged = true
+				continue
+			}
+
+			// stale tag of another tracker
+			if _, ok := p.tags[tag]; ok {
+				p.Untag[tag] = append(p.Untag[tag], torrent.Hash)
+			}
+		}
+	}
+
+	if c == nil {
+		return
+	}
+
+	if !tagged {
+		p.Tag[c.Tag] = append(p.Tag[c.Tag], torrent.Hash)
+	}
+
+	if setCategory && c.Category != "" && torrent.Category != c.Category {
+		p.Category[c.Category] = append(p.Category[c.Category], torrent.Hash)
+	}
+
+	if setShareLimits && c.ShareLimits.IsSet() {
+		p.addShareLimits(torrent, c.ShareLimits)
+	}
+}
+
+// addShareLimits plans the share limits for the torrent when they change
+func (p *trackerTagPlan) addShareLimits(torrent qbittorrent.Torrent, limits domain.ShareLimits) {
+	opts := shareLimitOptions(torrent, limits)
+
+	if opts != shareLimitOptions(torrent, domain.ShareLimits{}) {
+		p.ShareLimits[opts] = append(p.ShareLimits[opts], torrent.Hash)
+	}
+}
+
+func (p *trackerTagPlan) apply(ctx context.Context, qb *qbittorrent.Client, dry bool) error {
+	for _, tag := range sortedKeys(p.Untag) {
+		hashes := p.Untag[tag]
+
+		if dry {
+			log.Printf("dry-run: removing tag %s from (%d) torrents\n", tag, len(hashes))
+			continue
+		}
+
+		err := batchRequests(hashes, func(start, end int) error {
+			return qb.RemoveTagsCtx(ctx, hashes[start:end], tag)
+		})
+		if err != nil {
+			return errors.Wrapf(err, "could not remove tag %s", tag)
+		}
+
+		log.Printf("removed tag %s from (%d) torrents\n", tag, len(hashes))
+	}
+
+	for _, tag := range sortedKeys(p.Tag) {
+		hashes := p.Tag[tag]
+
+		if dry {
+			log.Printf("dry-run: tagging (%d) torrents with %s\n", len(hashes), tag)
+			continue
+		}
+
+		err := batchRequests(hashes, func(start, end int) error {
+			return qb.AddTagsCtx(ctx, hashes[start:end], tag)
+		})
+		if err != nil {
+			return errors.Wrapf(err, "could not add tag %s", tag)
+		}
+
+		log.Printf("tagged (%d) torrents with %s\n", len(hashes), tag)
+	}
+
+	for _, category := range sortedKeys(p.Category) {
+		hashes := p.Category[category]
+
+		if err := setTorrentsCategory(ctx, qb, hashes, category, dry); err != nil {
+			return errors.Wrapf(err, "could not set category %s", category)
+		}
+
+		if !dry {
+			log.Printf("set category %s on (%d) torrents\n", category, len(hashes))
+		}
+	}
+
+	if err := applyShareLimits(ctx, qb, p.ShareLimits, dry); err != nil {
+		return err
+	}
+
+	if len(p.Tag)+len(p.Untag)+len(p.Category)+len(p.ShareLimits) == 0 {
+		log.Println("all torrents are up to date")
+	}
+
+	return nil
+}
+
+// applyShareLimits sets the share limits on the torrents, in a batch per limits
+func applyShareLimits(ctx context.Context, qb *qbittorrent.Client, limits map[qbittorrent.ShareLimitOptions][]string, dry bool) error {
+	keys := make([]qbittorrent.ShareLimitOptions, 0, len(limits))
+	for opts := range limits {
+		keys = append(keys, opts)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return formatShareLimits(keys[i]) < formatShareLimits(keys[j])
+	})
+
+	for _, opts := range keys {
+		hashes := limits[opts]
+
+		if dry {
+			log.Printf("dry-run: setting share limits (%s) on (%d) torrents\n", formatShareLimits(opts), len(hashes))
+			continue
+		}
+
+		err := batchRequests(hashes, func(start, end int) error {
+			return qb.SetTorrentShareLimitCtx(ctx, hashes[start:end], opts)
+		})
+		if err != nil {
+			return errors.Wrapf(err, "could not set share limits (%s)", formatShareLimits(opts))
+		}
+
+		log.Printf("set share limits (%s) on (%d) torrents\n", formatShareLimits(opts), len(hashes))
+	}
+
+	return nil
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
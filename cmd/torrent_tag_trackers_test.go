@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ludviglundgren/qbittorrent-cli/internal/domain"
+
+	"github.com/autobrr/go-qbittorrent"
+)
+
+func Test_trackerConfigFor(t *testing.T) {
+	configs := []domain.TrackerConfig{
+		{Domains: []string{"tracker.example.org", "example.net"}, Tag: "EX"},
+		{Domains: []string{"other.org"}, Tag: "OT"},
+	}
+
+	tests := []struct {
+		name    string
+		url     string
+		wantTag string
+	}{
+		{name: "domain", url: "https://tracker.example.org/pk/announce", wantTag: "EX"},
+		{name: "second domain subdomain", url: "udp://announce.example.net:1337", wantTag: "EX"},
+		{name: "other", url: "https://other.org/announce", wantTag: "OT"},
+		{name: "not configured", url: "https://example.org/announce", wantTag: ""},
+		{name: "empty", url: "", wantTag: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tag string
+			if c := trackerConfigFor(tt.url, configs); c != nil {
+				tag = c.Tag
+			}
+			if tag != tt.wantTag {
+				t.Errorf("trackerConfigFor() = %q, want %q", tag, tt.wantTag)
+			}
+		})
+	}
+}
+
+func Test_trackerTagPlan_add(t *testing.T) {
+	ratio := 2.0
+	seedingTime := int64(10080)
+
+	configs := []domain.TrackerConfig{
+		{Domains: []string{"example.org"}, Tag: "EX", Category: "ex", ShareLimits: domain.ShareLimits{Ratio: &ratio, SeedingTime: &seedingTime}},
+		{Domains: []string{"other.org"}, Tag: "OT"},
+	}
+
+	plan := newTrackerTagPlan(configs)
+
+	torrents := []struct {
+		torrent qbittorrent.Torrent
+		config  *domain.TrackerConfig
+	}{
+		// new torrent
+		{torrent: qbittorrent.Torrent{Hash: "a", Tags: "", RatioLimit: -2, SeedingTimeLimit: -2, InactiveSeedingTimeLimit: -2}, config: &configs[0]},
+		// up to date
+		{torrent: qbittorrent.Torrent{Hash: "b", Tags: "EX, movies", Category: "ex", RatioLimit: 2, SeedingTimeLimit: 10080, InactiveSeedingTimeLimit: -2}, config: &configs[0]},
+		// moved to another tracker
+		{torrent: qbittorrent.Torrent{Hash: "c", Tags: "EX", Category: "ex", RatioLimit: 2, SeedingTimeLimit: 10080}, config: &configs[1]},
+		// no longer on a configured tracker
+		{torrent: qbittorrent.Torrent{Hash: "d", Tags: "OT, movies"}, config: nil},
+	}
+
+	for _, tt := range torrents {
+		plan.add(tt.torrent, tt.config, true, true)
+	}
+
+	if want := map[string][]string{"EX": {"a"}, "OT": {"c"}}; !reflect.DeepEqual(plan.Tag, want) {
+		t.Errorf("Tag = %v, want %v", plan.Tag, want)
+	}
+
+	if want := map[string][]string{"EX": {"c"}, "OT": {"d"}}; !reflect.DeepEqual(plan.Untag, want) {
+		t.Errorf("Untag = %v, want %v", plan.Untag, want)
+	}
+
+	if want := map[string][]string{"ex": {"a"}}; !reflect.DeepEqual(plan.Category, want) {
+		t.Errorf("Category = %v, want %v", plan.Category, want)
+	}
+
+	wantLimits := map[qbittorrent.ShareLimitOptions][]string{
+		{RatioLimit: 2, SeedingTimeLimit: 10080, InactiveSeedingTimeLimit: -2}: {"a"},
+	}
+	if !reflect.DeepEqual(plan.ShareLimits, wantLimits) {
+		t.Errorf("ShareLimits = %v, want %v", plan.ShareLimits, wantLimits)
+	}
+}
+
+func Test_shareLimitOptions_roundsRatio(t *testing.T) {
+	ratio := 1.256
+
+	// qBittorrent stores the ratio set with 2 decimals
+	torrent := qbittorrent.Torrent{RatioLimit: 1.26, SeedingTimeLimit: -2, InactiveSeedingTimeLimit: -2}
+
+	got := shareLimitOptions(torrent, domain.ShareLimits{Ratio: &ratio})
+	if got != shareLimitOptions(torrent, domain.ShareLimits{}) {
+		t.Errorf("shareLimitOptions() = %+v, want the limits of the torrent", got)
+	}
+	if got.RatioLimit != 1.26 {
+		t.Errorf("shareLimitOptions() ratio = %v, want 1.26", got.RatioLimit)
+	}
+}
@@ -1,10 +1,15 @@
 package cmd
 
 import (
+	"context"
 	"log"
+	"net"
+	"net/url"
+	"regexp"
 	"strings"
 
 	"github.com/ludviglundgren/qbittorrent-cli/internal/config"
+	"github.com/ludviglundgren/qbittorrent-cli/internal/trackers"
 
 	"github.com/autobrr/go-qbittorrent"
 	"github.com/pkg/errors"
@@ -16,9 +21,12 @@ func RunTorrentTracker() *cobra.Command {
 	var command = &cobra.Command{
 		Use:   "tracker",
 		Short: "Torrent tracker subcommand",
-		Long:  `Do various torrent category operations`,
+		Long:  `Do various torrent tracker operations`,
 	}
 
+	command.AddCommand(RunTorrentTrackerList())
+	command.AddCommand(RunTorrentTrackerAdd())
+	command.AddCommand(RunTorrentTrackerRemove())
 	command.AddCommand(RunTorrentTrackerEdit())
 
 	return command
@@ -27,26 +35,60 @@ func RunTorrentTracker() *cobra.Command {
 // RunTorrentTrackerEdit cmd for torrent tracker operations
 func RunTorrentTrackerEdit() *cobra.Command {
 	var command = &cobra.Command{
-		Use:     "edit",
-		Short:   "Edit torrent tracker",
-		Long:    `Edit tracker for torrents via hashes`,
-		Example: `  qbt torrent tracker edit --old url.old/test --new url.com/test`,
+		Use:   "edit",
+		Short: "Edit torrent tracker",
+		Long: `Replace every tracker url containing --old with --new, on every tier and also trackers that are not the current
+tracker of the torrent. The whole url is replaced with --new, so trackers on several tiers that contain --old become
+one tracker. Use --host or --regex to keep the rest of each url, like the passkey.
+
+With --regex --old is a regex and --new the replacement, where $1 is the first group. With --host --old and --new
+are hosts, and only the host of trackers on --old is replaced so the passkey in the path is kept. A port in --new
+replaces the port of the tracker.
+
+When the torrent already has the new url the old tracker is removed instead.
+
+Trackers are fetched for --concurrency torrents at a time. Use --rate to limit the requests per second to qBittorrent.`,
+		Example: `  qbt torrent tracker edit --old url.old/test --new url.com/test
+  qbt torrent tracker edit --host --old tracker.old.org --new tracker.new.org
+  qbt torrent tracker edit --regex --old '^http://(tracker\.example\.org)/' --new 'https://$1/'`,
 	}
 
 	var (
-		dry    bool
-		oldURL string
-		newURL string
+		dry         bool
+		oldURL      string
+		newURL      string
+		regex       bool
+		host        bool
+		concurrency int
+		rate        float64
 	)
 
 	command.Flags().BoolVar(&dry, "dry-run", false, "Run without doing anything")
 	command.Flags().StringVar(&oldURL, "old", "", "Old tracker URL to replace")
 	command.Flags().StringVar(&newURL, "new", "", "New tracker URL")
+	command.Flags().BoolVar(&regex, "regex", false, "Match --old as regex and replace it with --new")
+	command.Flags().BoolVar(&host, "host", false, "Replace the tracker host --old with --new and keep the rest of the url")
+	command.Flags().IntVar(&concurrency, "concurrency", trackers.DefaultConcurrency, "Number of torrents to fetch trackers for at the same time")
+	command.Flags().Float64Var(&rate, "rate", 0, "Max requests per second to fetch trackers, 0 is unlimited")
 
 	command.MarkFlagRequired("old")
 	command.MarkFlagRequired("new")
+	command.MarkFlagsMutuallyExclusive("regex", "host")
 
 	command.RunE = func(cmd *cobra.Command, args []string) error {
+		rewrite, err := newTrackerRewrite(oldURL, newURL, regex, host)
+		if err != nil {
+			return err
+		}
+
+		if concurrency < 1 {
+			return errors.Errorf("invalid --concurrency: %d", concurrency)
+		}
+
+		if rate < 0 {
+			return errors.Errorf("invalid --rate: %v", rate)
+		}
+
 		config.InitConfig()
 
 		qbtSettings := qbittorrent.Config{
@@ -68,39 +110,165 @@ func RunTorrentTrackerEdit() *cobra.Command {
 
 		torrents, err := qb.GetTorrentsCtx(ctx, qbittorrent.TorrentFilterOptions{})
 		if err != nil {
-			errors.Wrap(err, "could not get torrents")
+			return errors.Wrap(err, "could not get torrents")
 		}
 
-		var torrentsToUpdate []qbittorrent.Torrent
+		results, err := fetchTrackers(ctx, qb, torrents, concurrency, rate)
+		if err != nil {
+			return errors.Wrap(err, "could not get trackers")
+		}
 
-		for _, torrent := range torrents {
-			if strings.Contains(torrent.Tracker, oldURL) {
-				torrentsToUpdate = append(torrentsToUpdate, torrent)
+		var edits []trackerEdit
+
+		for _, res := range results {
+			if res.Err != nil {
+				return errors.Wrapf(res.Err, "could not get trackers for torrent: %s", res.Torrent.Hash)
 			}
+
+			edits = append(edits, planTrackerEdits(res.Torrent, res.Trackers, rewrite)...)
 		}
 
-		if len(torrentsToUpdate) == 0 {
+		if len(edits) == 0 {
 			log.Printf("found no torrents with tracker %q\n", oldURL)
 			return nil
 		}
 
-		for i, torrent := range torrentsToUpdate {
+		for i, edit := range edits {
+			if edit.remove {
+				// the torrent already has the new url so editing would fail
+				if dry {
+					log.Printf("dry-run: [%d/%d] removing tracker %s already replaced by %s for torrent %s %q\n", i+1, len(edits), edit.url, edit.newURL, edit.torrent.Hash, edit.torrent.Name)
+					continue
+				}
+
+				log.Printf("[%d/%d] removing tracker %s already replaced by %s for torrent %s %q\n", i+1, len(edits), edit.url, edit.newURL, edit.torrent.Hash, edit.torrent.Name)
+
+				if err := qb.RemoveTrackersCtx(ctx, edit.torrent.Hash, edit.url); err != nil {
+					return errors.Wrapf(err, "could not remove tracker for torrent: %s", edit.torrent.Hash)
+				}
+
+				continue
+			}
+
 			if dry {
-				log.Printf("dry-run: [%d/%d] updating tracker for torrent %s %q\n", i+1, len(torrentsToUpdate), torrent.Hash, torrent.Name)
+				log.Printf("dry-run: [%d/%d] updating tracker %s to %s for torrent %s %q\n", i+1, len(edits), edit.url, edit.newURL, edit.torrent.Hash, edit.torrent.Name)
 
 			} else {
-				log.Printf("[%d/%d] updating tracker for torrent %s %q\n", i+1, len(torrentsToUpdate), torrent.Hash, torrent.Name)
+				log.Printf("[%d/%d] updating tracker %s to %s for torrent %s %q\n", i+1, len(edits), edit.url, edit.newURL, edit.torrent.Hash, edit.torrent.Name)
 
-				if err := qb.EditTrackerCtx(ctx, torrent.Hash, torrent.Tracker, newURL); err != nil {
-					return errors.Wrapf(err, "could not edit tracker for torrent: %s", torrent.Hash)
+				if err := qb.EditTrackerCtx(ctx, edit.torrent.Hash, edit.url, edit.newURL); err != nil {
+					return errors.Wrapf(err, "could not edit tracker for torrent: %s", edit.torrent.Hash)
 				}
 			}
 		}
 
-		log.Printf("successfully updated tracker for (%d) torrents\n", len(torrentsToUpdate))
+		log.Printf("successfully updated (%d) trackers\n", len(edits))
 
 		return nil
 	}
 
 	return command
 }
+
+// trackerRewrite returns the new url for a tracker url, and false when the url does not match
+type trackerRewrite func(trackerURL string) (string, bool)
+
+// newTrackerRewrite returns a rewrite that replaces urls containing oldURL with newURL, or with regex the matches of
+// oldURL with the expansion of newURL, or with host the host oldURL with newURL
+func newTrackerRewrite(oldURL, newURL string, regex, host bool) (trackerRewrite, error) {
+	switch {
+	case regex:
+		re, err := regexp.Compile(oldURL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid --old regex: %s", oldURL)
+		}
+
+		return func(trackerURL string) (string, bool) {
+			if !re.MatchString(trackerURL) {
+				return "", false
+			}
+
+			return re.ReplaceAllString(trackerURL, newURL), true
+		}, nil
+
+	case host:
+		if strings.Contains(oldURL, "/") || strings.Contains(newURL, "/") {
+			return nil, errors.New("--old and --new must be hosts with --host")
+		}
+
+		return func(trackerURL string) (string, bool) {
+			u, err := url.Parse(trackerURL)
+			if err != nil || !strings.EqualFold(u.Hostname(), oldURL) {
+				return "", false
+			}
+
+			if port := u.Port(); port != "" && !strings.Contains(newURL, ":") {
+				u.Host = net.JoinHostPort(newURL, port)
+			} else {
+				u.Host = newURL
+			}
+
+			return u.String(), true
+		}, nil
+
+	default:
+		return func(trackerURL string) (string, bool) {
+			if !strings.Contains(trackerURL, oldURL) {
+				return "", false
+			}
+
+			return newURL, true
+		}, nil
+	}
+}
+
+type trackerEdit struct {
+	torrent qbittorrent.Torrent
+	url     string
+	newURL  string
+	// remove is set when the torrent already has newURL
+	remove bool
+}
+
+// planTrackerEdits returns the edits of the trackers of the torrent the rewrite matches. DHT, PeX and LSD are skipped.
+func planTrackerEdits(torrent qbittorrent.Torrent, trackers []qbittorrent.TorrentTracker, rewrite trackerRewrite) []trackerEdit {
+	existing := map[string]struct{}{}
+	for _, tracker := range trackers {
+		existing[tracker.Url] = struct{}{}
+	}
+
+	var edits []trackerEdit
+
+	for _, tracker := range trackers {
+		if strings.HasPrefix(tracker.Url, "** [") {
+			continue
+		}
+
+		newURL, ok := rewrite(tracker.Url)
+		if !ok || newURL == tracker.Url {
+			continue
+		}
+
+		_, exists := existing[newURL]
+		existing[newURL] = struct{}{}
+
+		edits = append(edits, trackerEdit{torrent: torrent, url: tracker.Url, newURL: newURL, remove: exists})
+	}
+
+	return edits
+}
+
+// fetchTrackers gets the trackers of the torrents with at most concurrency requests at a time and logs the progress
+func fetchTrackers(ctx context.Context, qb *qbittorrent.Client, torrents []qbittorrent.Torrent, concurrency int, rate float64) ([]trackers.Result, error) {
+	step := max(len(torrents)/10, 1)
+
+	return trackers.Fetch(ctx, qb, torrents, trackers.Options{
+		Concurrency: concurrency,
+		Rate:        rate,
+		OnProgress: func(done, total int) {
+			if done%step == 0 || done == total {
+				log.Printf("fetched trackers for [%d/%d] torrents\n", done, total)
+			}
+		},
+	})
+}
@@ -0,0 +1,513 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/ludviglundgren/qbittorrent-cli/internal/config"
+	"github.com/ludviglundgren/qbittorrent-cli/internal/issues"
+	"github.com/ludviglundgren/qbittorrent-cli/internal/trackers"
+	"github.com/ludviglundgren/qbittorrent-cli/pkg/utils"
+
+	"github.com/autobrr/go-qbittorrent"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// RunTorrentTrackerList cmd to list the trackers of torrents
+func RunTorrentTrackerList() *cobra.Command {
+	var (
+		hashes      []string
+		category    string
+		tag         string
+		hosts       []string
+		output      string
+		concurrency int
+		rate        float64
+	)
+
+	var command = &cobra.Command{
+		Use:   "list",
+		Short: "List torrent trackers",
+		Long: `List the trackers of torrents with their status, message and peers. Trackers are listed in tier order.
+DHT, PeX and LSD are not listed. Without hashes every torrent is listed, or the torrents in --category and with --tag.
+Use - to read newline separated hashes from stdin.
+
+Use --host to only list trackers on the hosts and their subdomains.`,
+		Example: `  qbt torrent tracker list HASH
+  qbt torrent tracker list --category movies --host tracker.example.org
+  qbt torrent tracker list --output json`,
+	}
+
+	command.Flags().StringSliceVar(&hashes, "hashes", []string{}, "Torrent hashes, as comma separated list")
+	command.Flags().StringVarP(&category, "category", "c", "", "Filter by category")
+	command.Flags().StringVarP(&tag, "tag", "t", "", "Filter by tag")
+	command.Flags().StringSliceVar(&hosts, "host", []string{}, "Only list trackers on these hosts, comma separated")
+	command.Flags().StringVar(&output, "output", "", "Print as [formatted text (default), json]")
+	command.Flags().IntVar(&concurrency, "concurrency", trackers.DefaultConcurrency, "Number of torrents to fetch trackers for at the same time")
+	command.Flags().Float64Var(&rate, "rate", 0, "Max requests per second to fetch trackers, 0 is unlimited")
+
+	command.RunE = func(cmd *cobra.Command, args []string) error {
+		expanded, err := expandStdin(append(hashes, args...))
+		if err != nil {
+			return err
+		}
+		hashes = expanded
+
+		if len(hashes) > 0 {
+			if err := utils.ValidateHash(hashes); err != nil {
+				return errors.Wrap(err, "invalid hashes supplied")
+			}
+		}
+
+		if concurrency < 1 {
+			return errors.Errorf("invalid --concurrency: %d", concurrency)
+		}
+
+		config.InitConfig()
+
+		qbtSettings := qbittorrent.Config{
+			Host:      config.Qbit.Addr,
+			APIKey:    config.Qbit.APIKey,
+			Username:  config.Qbit.Login,
+			Password:  config.Qbit.Password,
+			BasicUser: config.Qbit.BasicUser,
+			BasicPass: config.Qbit.BasicPass,
+		}
+
+		qb := qbittorrent.NewClient(qbtSettings)
+
+		ctx := cmd.Context()
+
+		if err := qb.LoginCtx(ctx); err != nil {
+			return errors.Wrap(err, "could not login to qbit")
+		}
+
+		results, err := selectTrackerTorrents(ctx, qb, hashes, category, tag, concurrency, rate)
+		if err != nil {
+			return err
+		}
+
+		var list []torrentTrackers
+
+		for _, res := range results {
+			entry := torrentTrackers{Hash: res.Torrent.Hash, Name: res.Torrent.Name, Trackers: []trackerInfo{}}
+
+			for _, tracker := range res.Trackers {
+				if strings.HasPrefix(tracker.Url, "** [") || !onHosts(tracker.Url, hosts) {
+					continue
+				}
+
+				entry.Trackers = append(entry.Trackers, trackerInfo{
+					URL:         tracker.Url,
+					Status:      trackerStatus(tracker.Status),
+					Message:     tracker.Message,
+					NumPeers:    tracker.NumPeers,
+					NumSeeds:    tracker.NumSeeds,
+					NumLeechers: tracker.NumLeechers,
+				})
+			}
+
+			if len(hosts) > 0 && len(entry.Trackers) == 0 {
+				continue
+			}
+
+			list = append(list, entry)
+		}
+
+		switch output {
+		case "json":
+			if list == nil {
+				list = []torrentTrackers{}
+			}
+
+			res, err := json.Marshal(list)
+			if err != nil {
+				return errors.Wrap(err, "could not marshal trackers to json")
+			}
+			fmt.Println(string(res))
+
+		default:
+			if len(list) == 0 {
+				log.Println("found no torrents")
+				return nil
+			}
+
+			tmpl, err := template.New("trackers").Parse(torrentTrackersTemplate)
+			if err != nil {
+				return errors.Wrap(err, "could not parse template")
+			}
+
+			if err := tmpl.Execute(os.Stdout, list); err != nil {
+				return errors.Wrap(err, "could not print trackers")
+			}
+		}
+
+		return nil
+	}
+
+	return command
+}
+
+var torrentTrackersTemplate = `{{ range .}}
+[*] {{.Name}}
+    Hash: {{.Hash}}
+{{- range .Trackers}}
+    [{{.Status}}] {{.URL}} Peers: {{.NumPeers}} Seeds: {{.NumSeeds}} Leechers: {{.NumLeechers}}
+{{- if .Message}}
+        {{.Message}}
+{{- end}}
+{{- end}}
+{{end}}
+`
+
+type torrentTrackers struct {
+	Hash     string        `json:"hash"`
+	Name     string        `json:"name"`
+	Trackers []trackerInfo `json:"trackers"`
+}
+
+type trackerInfo struct {
+	URL         string `json:"url"`
+	Status      string `json:"status"`
+	Message     string `json:"message"`
+	NumPeers    int    `json:"num_peers"`
+	NumSeeds    int    `json:"num_seeds"`
+	NumLeechers int    `json:"num_leechers"`
+}
+
+func trackerStatus(status qbittorrent.TrackerStatus) string {
+	switch status {
+	case qbittorrent.TrackerStatusDisabled:
+		return "Disabled"
+	case qbittorrent.TrackerStatusNotContacted:
+		return "Not contacted"
+	case qbittorrent.TrackerStatusOK:
+		return "Working"
+	case qbittorrent.TrackerStatusUpdating:
+		return "Updating"
+	case qbittorrent.TrackerStatusNotWorking:
+		return "Not working"
+	case qbittorrent.TrackerStatusTrackerError:
+		return "Tracker error"
+	case qbittorrent.TrackerStatusUnreachable:
+		return "Unreachable"
+	default:
+		return fmt.Sprintf("Unknown (%d)", status)
+	}
+}
+
+// RunTorrentTrackerAdd cmd to add trackers to torrents
+func RunTorrentTrackerAdd() *cobra.Command {
+	var (
+		dry         bool
+		all         bool
+		hashes      []string
+		category    string
+		tag         string
+		urls        []string
+		concurrency int
+		rate        float64
+	)
+
+	var command = &cobra.Command{
+		Use:   "add",
+		Short: "Add trackers to torrents",
+		Long: `Add the --url trackers to the torrents by hashes, in --category or with --tag, or to every torrent with --all.
+Torrents that already have a tracker are skipped for it. Use - to read newline separated hashes from stdin.`,
+		Example: `  qbt torrent tracker add HASH --url https://tracker.example.org/announce
+  qbt torrent tracker add --category movies --url udp://tracker.example.org:1337 --dry-run`,
+	}
+
+	command.Flags().BoolVar(&dry, "dry-run", false, "Run without doing anything")
+	command.Flags().BoolVar(&all, "all", false, "Add trackers to all torrents")
+	command.Flags().StringSliceVar(&hashes, "hashes", []string{}, "Torrent hashes, as comma separated list")
+	command.Flags().StringVarP(&category, "category", "c", "", "Add trackers to torrents in category")
+	command.Flags().StringVarP(&tag, "tag", "t", "", "Add trackers to torrents with tag")
+	command.Flags().StringSliceVar(&urls, "url", []string{}, "Tracker urls to add, comma separated")
+	command.Flags().IntVar(&concurrency, "concurrency", trackers.DefaultConcurrency, "Number of torrents to fetch trackers for at the same time")
+	command.Flags().Float64Var(&rate, "rate", 0, "Max requests per second to fetch trackers, 0 is unlimited")
+
+	command.MarkFlagRequired("url")
+
+	command.RunE = func(cmd *cobra.Command, args []string) error {
+		expanded, err := expandStdin(append(hashes, args...))
+		if err != nil {
+			return err
+		}
+		hashes = expanded
+
+		if err := validateTrackerSelection(all, hashes, category, tag); err != nil {
+			return err
+		}
+
+		if concurrency < 1 {
+			return errors.Errorf("invalid --concurrency: %d", concurrency)
+		}
+
+		config.InitConfig()
+
+		qbtSettings := qbittorrent.Config{
+			Host:      config.Qbit.Addr,
+			APIKey:    config.Qbit.APIKey,
+			Username:  config.Qbit.Login,
+			Password:  config.Qbit.Password,
+			BasicUser: config.Qbit.BasicUser,
+			BasicPass: config.Qbit.BasicPass,
+		}
+
+		qb := qbittorrent.NewClient(qbtSettings)
+
+		ctx := cmd.Context()
+
+		if err := qb.LoginCtx(ctx); err != nil {
+			return errors.Wrap(err, "could not login to qbit")
+		}
+
+		results, err := selectTrackerTorrents(ctx, qb, hashes, category, tag, concurrency, rate)
+		if err != nil {
+			return err
+		}
+
+		var count int
+
+		for _, res := range results {
+			existing := map[string]struct{}{}
+			for _, tracker := range res.Trackers {
+				existing[tracker.Url] = struct{}{}
+			}
+
+			var add []string
+			for _, u := range urls {
+				if _, ok := existing[u]; !ok {
+					add = append(add, u)
+				}
+			}
+
+			if len(add) == 0 {
+				continue
+			}
+
+			count++
+
+			if dry {
+				log.Printf("dry-run: adding trackers %v to torrent %s %q\n", add, res.Torrent.Hash, res.Torrent.Name)
+				continue
+			}
+
+			log.Printf("adding trackers %v to torrent %s %q\n", add, res.Torrent.Hash, res.Torrent.Name)
+
+			if err := qb.AddTrackersCtx(ctx, res.Torrent.Hash, strings.Join(add, "\n")); err != nil {
+				return errors.Wrapf(err, "could not add trackers to torrent: %s", res.Torrent.Hash)
+			}
+		}
+
+		log.Printf("successfully added trackers to (%d) torrents\n", count)
+
+		return nil
+	}
+
+	return command
+}
+
+// RunTorrentTrackerRemove cmd to remove trackers from torrents
+func RunTorrentTrackerRemove() *cobra.Command {
+	var (
+		dry         bool
+		all         bool
+		hashes      []string
+		category    string
+		tag         string
+		urls        []string
+		hosts       []string
+		concurrency int
+		rate        float64
+	)
+
+	var command = &cobra.Command{
+		Use:   "remove",
+		Short: "Remove trackers from torrents",
+		Long: `Remove the --url trackers, or every tracker on the --host hosts and their subdomains, from the torrents by hashes,
+in --category or with --tag, or from every torrent with --all. Use - to read newline separated hashes from stdin.
+
+Torrents are skipped when it would remove all of their trackers.`,
+		Example: `  qbt torrent tracker remove HASH --url https://tracker.example.org/announce
+  qbt torrent tracker remove --all --host tracker.dead.org --dry-run`,
+	}
+
+	command.Flags().BoolVar(&dry, "dry-run", false, "Run without doing anything")
+	command.Flags().BoolVar(&all, "all", false, "Remove trackers from all torrents")
+	command.Flags().StringSliceVar(&hashes, "hashes", []string{}, "Torrent hashes, as comma separated list")
+	command.Flags().StringVarP(&category, "category", "c", "", "Remove trackers from torrents in category")
+	command.Flags().StringVarP(&tag, "tag", "t", "", "Remove trackers from torrents with tag")
+	command.Flags().StringSliceVar(&urls, "url", []string{}, "Tracker urls to remove, comma separated")
+	command.Flags().StringSliceVar(&hosts, "host", []string{}, "Remove trackers on these hosts, comma separated")
+	command.Flags().IntVar(&concurrency, "concurrency", trackers.DefaultConcurrency, "Number of torrents to fetch trackers for at the same time")
+	command.Flags().Float64Var(&rate, "rate", 0, "Max requests per second to fetch trackers, 0 is unlimited")
+
+	command.MarkFlagsOneRequired("url", "host")
+
+	command.RunE = func(cmd *cobra.Command, args []string) error {
+		expanded, err := expandStdin(append(hashes, args...))
+		if err != nil {
+			return err
+		}
+		hashes = expanded
+
+		if err := validateTrackerSelection(all, hashes, category, tag); err != nil {
+			return err
+		}
+
+		if concurrency < 1 {
+			return errors.Errorf("invalid --concurrency: %d", concurrency)
+		}
+
+		config.InitConfig()
+
+		qbtSettings := qbittorrent.Config{
+			Host:      config.Qbit.Addr,
+			APIKey:    config.Qbit.APIKey,
+			Username:  config.Qbit.Login,
+			Password:  config.Qbit.Password,
+			BasicUser: config.Qbit.BasicUser,
+			BasicPass: config.Qbit.BasicPass,
+		}
+
+		qb := qbittorrent.NewClient(qbtSettings)
+
+		ctx := cmd.Context()
+
+		if err := qb.LoginCtx(ctx); err != nil {
+			return errors.Wrap(err, "could not login to qbit")
+		}
+
+		results, err := selectTrackerTorrents(ctx, qb, hashes, category, tag, concurrency, rate)
+		if err != nil {
+			return err
+		}
+
+		var count int
+
+		for _, res := range results {
+			remove, total := matchTrackers(res.Trackers, urls, hosts)
+			if len(remove) == 0 {
+				continue
+			}
+
+			if len(remove) == total {
+				log.Printf("skipping torrent %s %q, it would have no trackers left\n", res.Torrent.Hash, res.Torrent.Name)
+				continue
+			}
+
+			count++
+
+			if dry {
+				log.Printf("dry-run: removing trackers %v from torrent %s %q\n", remove, res.Torrent.Hash, res.Torrent.Name)
+				continue
+			}
+
+			log.Printf("removing trackers %v from torrent %s %q\n", remove, res.Torrent.Hash, res.Torrent.Name)
+
+			if err := qb.RemoveTrackersCtx(ctx, res.Torrent.Hash, strings.Join(remove, "|")); err != nil {
+				return errors.Wrapf(err, "could not remove trackers from torrent: %s", res.Torrent.Hash)
+			}
+		}
+
+		log.Printf("successfully removed trackers from (%d) torrents\n", count)
+
+		return nil
+	}
+
+	return command
+}
+
+// matchTrackers returns the tracker urls that are in urls or on hosts, and the number of trackers without DHT, PeX and LSD
+func matchTrackers(list []qbittorrent.TorrentTracker, urls, hosts []string) ([]string, int) {
+	var (
+		matched []string
+		total   int
+	)
+
+	for _, tracker := range list {
+		if strings.HasPrefix(tracker.Url, "** [") {
+			continue
+		}
+
+		total++
+
+		match := len(hosts) > 0 && onHosts(tracker.Url, hosts)
+		for _, u := range urls {
+			if tracker.Url == u {
+				match = true
+				break
+			}
+		}
+
+		if match {
+			matched = append(matched, tracker.Url)
+		}
+	}
+
+	return matched, total
+}
+
+// onHosts reports whether the tracker is on one of the hosts or their subdomains, or true without hosts
+func onHosts(trackerURL string, hosts []string) bool {
+	if len(hosts) == 0 {
+		return true
+	}
+
+	d := issues.Domain(trackerURL)
+	if d == "" {
+		return false
+	}
+
+	for _, h := range hosts {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if d == h || strings.HasSuffix(d, "."+h) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validateTrackerSelection(all bool, hashes []string, category, tag string) error {
+	if !all && len(hashes) == 0 && category == "" && tag == "" {
+		return errors.New("no torrents specified: provide hash(es) as arguments or with --hashes, use --category, --tag or --all")
+	}
+
+	if len(hashes) > 0 {
+		if err := utils.ValidateHash(hashes); err != nil {
+			return errors.Wrap(err, "invalid hashes supplied")
+		}
+	}
+
+	return nil
+}
+
+// selectTrackerTorrents gets the torrents by hashes, category and tag with their trackers
+func selectTrackerTorrents(ctx context.Context, qb *qbittorrent.Client, hashes []string, category, tag string, concurrency int, rate float64) ([]trackers.Result, error) {
+	torrents, err := qb.GetTorrentsCtx(ctx, qbittorrent.TorrentFilterOptions{Hashes: hashes, Category: category, Tag: tag})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get torrents")
+	}
+
+	results, err := fetchTrackers(ctx, qb, torrents, concurrency, rate)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get trackers")
+	}
+
+	for _, res := range results {
+		if res.Err != nil {
+			return nil, errors.Wrapf(res.Err, "could not get trackers for torrent: %s", res.Torrent.Hash)
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/autobrr/go-qbittorrent"
+)
+
+func Test_newTrackerRewrite(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldURL  string
+		newURL  string
+		regex   bool
+		host    bool
+		url     string
+		want    string
+		wantOk  bool
+		wantErr bool
+	}{
+		{name: "contains", oldURL: "old.org", newURL: "https://new.org/announce", url: "https://old.org/pk/announce", want: "https://new.org/announce", wantOk: true},
+		{name: "contains no match", oldURL: "old.org", newURL: "https://new.org/announce", url: "https://other.org/announce", wantOk: false},
+		{name: "regex", oldURL: `^http://(tracker\.example\.org)/`, newURL: "https://$1/", regex: true, url: "http://tracker.example.org/pk/announce", want: "https://tracker.example.org/pk/announce", wantOk: true},
+		{name: "regex no match", oldURL: `^http://tracker\.example\.org/`, newURL: "https://x/", regex: true, url: "https://tracker.example.org/pk/announce", wantOk: false},
+		{name: "invalid regex", oldURL: `(`, regex: true, wantErr: true},
+		{name: "host keeps passkey", oldURL: "tracker.old.org", newURL: "tracker.new.org", host: true, url: "https://tracker.old.org/abc123/announce", want: "https://tracker.new.org/abc123/announce", wantOk: true},
+		{name: "host keeps port", oldURL: "Tracker.Old.org", newURL: "tracker.new.org", host: true, url: "http://tracker.old.org:2710/abc123/announce", want: "http://tracker.new.org:2710/abc123/announce", wantOk: true},
+		{name: "host new port", oldURL: "tracker.old.org", newURL: "tracker.new.org:443", host: true, url: "http://tracker.old.org:2710/abc123/announce", want: "http://tracker.new.org:443/abc123/announce", wantOk: true},
+		{name: "host subdomain no match", oldURL: "old.org", newURL: "new.org", host: true, url: "https://tracker.old.org/announce", wantOk: false},
+		{name: "host with path", oldURL: "old.org/announce", newURL: "new.org", host: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rewrite, err := newTrackerRewrite(tt.oldURL, tt.newURL, tt.regex, tt.host)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newTrackerRewrite() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got, ok := rewrite(tt.url)
+			if ok != tt.wantOk || got != tt.want {
+				t.Errorf("rewrite() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func Test_planTrackerEdits(t *testing.T) {
+	rewrite, err := newTrackerRewrite("old.org", "new.org", false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	torrent := qbittorrent.Torrent{Hash: "a"}
+
+	trackers := []qbittorrent.TorrentTracker{
+		{Url: "** [DHT] **"},
+		{Url: "https://other.org/announce", Status: qbittorrent.TrackerStatusOK},
+		{Url: "https://old.org/pk/announce", Status: qbittorrent.TrackerStatusNotWorking},
+		{Url: "http://old.org/pk/announce", Status: qbittorrent.TrackerStatusNotWorking},
+		{Url: "http://new.org/pk/announce", Status: qbittorrent.TrackerStatusOK},
+	}
+
+	want := []trackerEdit{
+		{torrent: torrent, url: "https://old.org/pk/announce", newURL: "https://new.org/pk/announce"},
+		{torrent: torrent, url: "http://old.org/pk/announce", newURL: "http://new.org/pk/announce", remove: true},
+	}
+
+	if got := planTrackerEdits(torrent, trackers, rewrite); !reflect.DeepEqual(got, want) {
+		t.Errorf("planTrackerEdits() = %+v, want %+v", got, want)
+	}
+}
+
+func Test_matchTrackers(t *testing.T) {
+	trackers := []qbittorrent.TorrentTracker{
+		{Url: "** [DHT] **"},
+		{Url: "https://tracker.example.org/pk/announce"},
+		{Url: "udp://open.example.com:1337/announce"},
+		{Url: "https://other.org/announce"},
+	}
+
+	tests := []struct {
+		name      string
+		urls      []string
+		hosts     []string
+		want      []string
+		wantTotal int
+	}{
+		{name: "url", urls: []string{"https://other.org/announce"}, want: []string{"https://other.org/announce"}, wantTotal: 3},
+		{name: "host and subdomains", hosts: []string{"example.org", "Open.Example.com"}, want: []string{"https://tracker.example.org/pk/announce", "udp://open.example.com:1337/announce"}, wantTotal: 3},
+		{name: "no match", urls: []string{"https://other.org"}, hosts: []string{"example.net"}, want: nil, wantTotal: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total := matchTrackers(trackers, tt.urls, tt.hosts)
+			if !reflect.DeepEqual(got, tt.want) || total != tt.wantTotal {
+				t.Errorf("matchTrackers() = %v, %d, want %v, %d", got, total, tt.want, tt.wantTotal)
+			}
+		})
+	}
+}
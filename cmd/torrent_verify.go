@@ -0,0 +1,411 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/ludviglundgren/qbittorrent-cli/internal/config"
+	"github.com/ludviglundgren/qbittorrent-cli/pkg/utils"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/autobrr/go-qbittorrent"
+	"github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// VerifyExitIncomplete is the exit code when data is missing or does not match the torrent
+	VerifyExitIncomplete = 2
+)
+
+// RunTorrentVerify cmd to verify local data against a torrent file
+func RunTorrentVerify() *cobra.Command {
+	var (
+		dataDir       string
+		workers       int
+		addIfComplete bool
+		paused        bool
+		category      string
+		tags          []string
+	)
+
+	var command = &cobra.Command{
+		Use:   "verify",
+		Short: "Verify local data against a torrent file",
+		Long: `Hash the local data of a torrent file without the client and report per-file completion,
+missing, extra and size mismatched files.
+
+--data is the save path, the same as in qBittorrent, so multi-file torrents are expected in <data>/<torrent name>.
+
+Exit codes:
+  0  data is complete
+  1  error
+  2  data is incomplete`,
+		Example: `  qbt torrent verify file.torrent --data /mnt/data/torrents
+  qbt torrent verify file.torrent --data /mnt/data/torrents --add-if-complete --category movies`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return errors.New("requires a torrent file as first argument")
+			}
+
+			return nil
+		},
+	}
+
+	command.Flags().StringVar(&dataDir, "data", "", "Save path with the torrent data (required)")
+	command.Flags().IntVar(&workers, "workers", 0, "Number of pieces to hash in parallel. Defaults to the number of CPUs")
+	command.Flags().BoolVar(&addIfComplete, "add-if-complete", false, "Add torrent to qBittorrent with skip hash check if data is complete")
+	command.Flags().BoolVar(&paused, "paused", false, "Add torrent in paused state. Used with --add-if-complete")
+	command.Flags().StringVar(&category, "category", "", "Add torrent to the specified category. Used with --add-if-complete")
+	command.Flags().StringArrayVar(&tags, "tags", []string{}, "Add tags to torrent. Used with --add-if-complete")
+
+	command.MarkFlagRequired("data")
+
+	command.RunE = func(cmd *cobra.Command, args []string) error {
+		filePath := args[0]
+
+		var err error
+		dataDir, err = utils.ExpandTilde(dataDir)
+		if err != nil {
+			return errors.Wrap(err, "could not read data dir")
+		}
+
+		if workers < 1 {
+			workers = runtime.NumCPU()
+		}
+
+		mi, err := metainfo.LoadFromFile(filePath)
+		if err != nil {
+			return errors.Wrapf(err, "could not parse torrent file: %s", filePath)
+		}
+
+		info, err := mi.UnmarshalInfo()
+		if err != nil {
+			return errors.Wrapf(err, "could not parse torrent info: %s", filePath)
+		}
+
+		log.Printf("verifying %s (%s) in %s with (%d) workers\n", info.BestName(), humanize.Bytes(uint64(info.TotalLength())), dataDir, workers)
+
+		result, err := verifyTorrentData(&info, dataDir, workers)
+		if err != nil {
+			return errors.Wrapf(err, "could not verify data for: %s", filePath)
+		}
+
+		printVerifyResult(result)
+
+		if !result.Complete() {
+			cmd.SilenceUsage = true
+			return &ExitCodeError{
+				Code: VerifyExitIncomplete,
+				Err:  errors.Errorf("data is incomplete: %.2f%% of pieces verified", result.Percent()),
+			}
+		}
+
+		if !addIfComplete {
+			return nil
+		}
+
+		config.InitConfig()
+
+		qbtSettings := qbittorrent.Config{
+			Host:      config.Qbit.Addr,
+			APIKey:    config.Qbit.APIKey,
+			Username:  config.Qbit.Login,
+			Password:  config.Qbit.Password,
+			BasicUser: config.Qbit.BasicUser,
+			BasicPass: config.Qbit.BasicPass,
+		}
+
+		qb := qbittorrent.NewClient(qbtSettings)
+
+		ctx := cmd.Context()
+
+		if err := qb.LoginCtx(ctx); err != nil {
+			return errors.Wrap(err, "could not login to qbit")
+		}
+
+		options := map[string]string{
+			"skip_checking": "true",
+			"savepath":      dataDir,
+			"autoTMM":       "false",
+		}
+		if paused {
+			options["paused"] = "true"
+			options["stopped"] = "true"
+		}
+		if category != "" {
+			options["category"] = category
+		}
+		if len(tags) > 0 {
+			options["tags"] = strings.Join(tags, ",")
+		}
+
+		if _, err := qb.AddTorrentFromFileCtx(ctx, filePath, options); err != nil {
+			return errors.Wrapf(err, "could not add torrent: %s", filePath)
+		}
+
+		log.Printf("successfully added torrent: %s\n", mi.HashInfoBytes().HexString())
+
+		return nil
+	}
+
+	return command
+}
+
+type verifyFile struct {
+	Path       string
+	Length     int64
+	Offset     int64
+	SizeOnDisk int64
+	Missing    bool
+	Padding    bool
+	Verified   int64
+
+	file *os.File
+}
+
+type verifyResult struct {
+	Name           string
+	Files          []*verifyFile
+	ExtraFiles     []string
+	NumPieces      int
+	VerifiedPieces int
+}
+
+// Complete reports whether every piece was verified and all files match in size
+func (r *verifyResult) Complete() bool {
+	for _, f := range r.Files {
+		if !f.Padding && (f.Missing || f.SizeOnDisk != f.Length) {
+			return false
+		}
+	}
+
+	return r.NumPieces > 0 && r.VerifiedPieces == r.NumPieces
+}
+
+// Percent returns the share of verified pieces
+func (r *verifyResult) Percent() float64 {
+	if r.NumPieces == 0 {
+		return 0
+	}
+
+	return float64(r.VerifiedPieces) / float64(r.NumPieces) * 100
+}
+
+// verifyTorrentData hashes the v1 pieces of info against the data in dataDir
+func verifyTorrentData(info *metainfo.Info, dataDir string, workers int) (*verifyResult, error) {
+	if !info.HasV1() {
+		return nil, errors.New("v2-only torrents are not supported, only v1 and hybrid")
+	}
+
+	if info.PieceLength <= 0 {
+		return nil, errors.New("invalid piece length")
+	}
+
+	result := &verifyResult{
+		Name:      info.BestName(),
+		NumPieces: len(info.Pieces) / sha1.Size,
+	}
+
+	root := filepath.Join(dataDir, info.BestName())
+	known := map[string]struct{}{}
+
+	defer func() {
+		for _, f := range result.Files {
+			if f.file != nil {
+				f.file.Close()
+			}
+		}
+	}()
+
+	for fi := range info.UpvertedV1Files() {
+		f := &verifyFile{
+			Length:  fi.Length,
+			Offset:  fi.TorrentOffset,
+			Padding: strings.Contains(fi.Attr, "p"),
+		}
+
+		if info.IsDir() {
+			f.Path = filepath.Join(append([]string{root}, fi.BestPath()...)...)
+		} else {
+			f.Path = root
+		}
+
+		if f.Padding {
+			result.Files = append(result.Files, f)
+			continue
+		}
+
+		known[f.Path] = struct{}{}
+
+		stat, err := os.Stat(f.Path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return nil, errors.Wrapf(err, "could not stat file: %s", f.Path)
+			}
+
+			f.Missing = true
+		} else {
+			f.SizeOnDisk = stat.Size()
+
+			f.file, err = os.Open(f.Path)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not open file: %s", f.Path)
+			}
+		}
+
+		result.Files = append(result.Files, f)
+	}
+
+	if info.IsDir() {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return filepath.SkipDir
+				}
+				return err
+			}
+
+			if d.IsDir() {
+				return nil
+			}
+
+			if _, ok := known[path]; !ok {
+				result.ExtraFiles = append(result.ExtraFiles, path)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not read dir: %s", root)
+		}
+	}
+
+	totalLength := info.TotalLength()
+	verified := make([]bool, result.NumPieces)
+
+	pieces := make(chan int)
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			buf := make([]byte, info.PieceLength)
+
+			for piece := range pieces {
+				offset := int64(piece) * info.PieceLength
+				length := min(info.PieceLength, totalLength-offset)
+
+				if !readPiece(result.Files, buf[:length], offset) {
+					continue
+				}
+
+				sum := sha1.Sum(buf[:length])
+				verified[piece] = bytes.Equal(sum[:], info.Pieces[piece*sha1.Size:(piece+1)*sha1.Size])
+			}
+		}()
+	}
+
+	for piece := 0; piece < result.NumPieces; piece++ {
+		pieces <- piece
+	}
+
+	close(pieces)
+	wg.Wait()
+
+	for piece, ok := range verified {
+		if !ok {
+			continue
+		}
+
+		result.VerifiedPieces++
+
+		pieceStart := int64(piece) * info.PieceLength
+		pieceEnd := min(pieceStart+info.PieceLength, totalLength)
+
+		for _, f := range result.Files {
+			start := max(pieceStart, f.Offset)
+			end := min(pieceEnd, f.Offset+f.Length)
+			if end > start {
+				f.Verified += end - start
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// readPiece reads the piece at offset into buf from the files it spans.
+// It returns false if any part of the piece is missing on disk.
+func readPiece(files []*verifyFile, buf []byte, offset int64) bool {
+	end := offset + int64(len(buf))
+
+	for _, f := range files {
+		start := max(offset, f.Offset)
+		stop := min(end, f.Offset+f.Length)
+		if stop <= start {
+			continue
+		}
+
+		segment := buf[start-offset : stop-offset]
+
+		if f.Padding {
+			clear(segment)
+			continue
+		}
+
+		if f.file == nil || f.SizeOnDisk < stop-f.Offset {
+			return false
+		}
+
+		if _, err := f.file.ReadAt(segment, start-f.Offset); err != nil && err != io.EOF {
+			return false
+		}
+	}
+
+	return true
+}
+
+func printVerifyResult(result *verifyResult) {
+	fmt.Printf("%s\n", result.Name)
+
+	for _, f := range result.Files {
+		if f.Padding {
+			continue
+		}
+
+		status := ""
+		switch {
+		case f.Missing:
+			status = " MISSING"
+		case f.SizeOnDisk != f.Length:
+			status = fmt.Sprintf(" SIZE MISMATCH (%s on disk)", humanize.Bytes(uint64(f.SizeOnDisk)))
+		}
+
+		percent := 100.0
+		if f.Length > 0 {
+			percent = float64(f.Verified) / float64(f.Length) * 100
+		}
+
+		fmt.Printf("  [%6.2f%%] %s (%s)%s\n", percent, f.Path, humanize.Bytes(uint64(f.Length)), status)
+	}
+
+	for _, extra := range result.ExtraFiles {
+		fmt.Printf("  [  extra] %s\n", extra)
+	}
+
+	fmt.Printf("verified (%d/%d) pieces: %.2f%%\n", result.VerifiedPieces, result.NumPieces, result.Percent())
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+func Test_verifyTorrentData(t *testing.T) {
+	dataDir := t.TempDir()
+	root := filepath.Join(dataDir, "release")
+
+	if err := os.MkdirAll(filepath.Join(root, "Sample"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]int{
+		"release.mkv":       70000,
+		"release.nfo":       1200,
+		"Sample/sample.mkv": 30000,
+	}
+	for name, size := range files {
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(i % 251)
+		}
+		if err := os.WriteFile(filepath.Join(root, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info := metainfo.Info{PieceLength: 16384}
+	if err := info.BuildFromFilePath(root); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("complete", func(t *testing.T) {
+		result, err := verifyTorrentData(&info, dataDir, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Complete() {
+			t.Errorf("verifyTorrentData() complete = false, verified %d/%d", result.VerifiedPieces, result.NumPieces)
+		}
+	})
+
+	t.Run("extra file", func(t *testing.T) {
+		if err := os.WriteFile(filepath.Join(root, "extra.txt"), []byte("extra"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(filepath.Join(root, "extra.txt"))
+
+		result, err := verifyTorrentData(&info, dataDir, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.ExtraFiles) != 1 {
+			t.Errorf("verifyTorrentData() extra files = %v, want 1", result.ExtraFiles)
+		}
+	})
+
+	t.Run("corrupt and missing", func(t *testing.T) {
+		if err := os.WriteFile(filepath.Join(root, "release.mkv"), make([]byte, 70000), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Remove(filepath.Join(root, "release.nfo")); err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := verifyTorrentData(&info, dataDir, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Complete() {
+			t.Errorf("verifyTorrentData() complete = true, want false")
+		}
+
+		for _, f := range result.Files {
+			switch filepath.Base(f.Path) {
+			case "release.nfo":
+				if !f.Missing {
+					t.Errorf("release.nfo missing = false, want true")
+				}
+			case "sample.mkv":
+				if f.Verified == 0 {
+					t.Errorf("sample.mkv verified = 0, want > 0")
+				}
+			}
+		}
+	})
+}
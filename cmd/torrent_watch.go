@@ -0,0 +1,544 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"io/fs"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ludviglundgren/qbittorrent-cli/internal/config"
+	"github.com/ludviglundgren/qbittorrent-cli/internal/domain"
+	fsutil "github.com/ludviglundgren/qbittorrent-cli/internal/fs"
+	"github.com/ludviglundgren/qbittorrent-cli/pkg/utils"
+
+	"github.com/autobrr/go-qbittorrent"
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// RunTorrentWatch cmd to add torrents from a watch folder
+func RunTorrentWatch() *cobra.Command {
+	var (
+		dry                bool
+		paused             bool
+		skipHashCheck      bool
+		ignoreRules        bool
+		categoryFromFolder bool
+		savePath           string
+		category           string
+		tags               []string
+		doneDir            string
+		failedDir          string
+		interval           time.Duration
+		settle             time.Duration
+	)
+
+	var command = &cobra.Command{
+		Use:   "watch",
+		Short: "Watch a folder and add new torrents",
+		Long: `Watch a folder and add new .torrent files and .magnet files as they appear.
+
+A .magnet file is a text file with a magnet link on the first non-empty line.
+
+Files in subfolders get the category of the subfolder name, like watch/tv/file.torrent with category tv.
+Subfolders can be mapped to a category, tags, save path and paused state in the config under [watch.folders].
+
+Processed files are moved to the done or failed folder, keeping their subfolder.
+When the max active downloads rule is reached, files are left in place and retried on the next rescan.`,
+		Example: `  qbt torrent watch ~/watch
+  qbt torrent watch ~/watch --tags autoadd --interval 5m
+  qbt torrent watch ~/watch --done-dir ~/watch-done --failed-dir ~/watch-failed`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return errors.New("requires a directory to watch as first argument")
+			}
+
+			return nil
+		},
+	}
+
+	command.Flags().BoolVar(&dry, "dry-run", false, "Run without doing anything")
+	command.Flags().BoolVar(&paused, "paused", false, "Add torrents in paused state")
+	command.Flags().BoolVar(&skipHashCheck, "skip-hash-check", false, "Skip hash check")
+	command.Flags().BoolVar(&ignoreRules, "ignore-rules", false, "Ignore rules from config")
+	command.Flags().BoolVar(&categoryFromFolder, "category-from-folder", true, "Use the subfolder name as category when not mapped in config")
+	command.Flags().StringVar(&savePath, "save-path", "", "Add torrents to the specified path")
+	command.Flags().StringVar(&category, "category", "", "Add torrents to the specified category. Overrides the folder category")
+	command.Flags().StringArrayVar(&tags, "tags", []string{}, "Add tags to torrents")
+	command.Flags().StringVar(&doneDir, "done-dir", "", "Move added files to this dir. Defaults to <dir>/done")
+	command.Flags().StringVar(&failedDir, "failed-dir", "", "Move failed files to this dir. Defaults to <dir>/failed")
+	command.Flags().DurationVar(&interval, "interval", 1*time.Minute, "Rescan the folder on this interval to pick up missed or deferred files")
+	command.Flags().DurationVar(&settle, "settle", 2*time.Second, "Wait until a file has not changed for this long before adding it")
+
+	command.RunE = func(cmd *cobra.Command, args []string) error {
+		config.InitConfig()
+
+		root, err := utils.ExpandTilde(args[0])
+		if err != nil {
+			return errors.Wrap(err, "could not read watch dir")
+		}
+
+		root, err = filepath.Abs(root)
+		if err != nil {
+			return errors.Wrapf(err, "could not read watch dir: %s", args[0])
+		}
+
+		if doneDir == "" {
+			doneDir = config.Watch.DoneDir
+		}
+		if failedDir == "" {
+			failedDir = config.Watch.FailedDir
+		}
+
+		w := &torrentWatcher{
+			root:               root,
+			doneDir:            watchDir(root, doneDir, "done"),
+			failedDir:          watchDir(root, failedDir, "failed"),
+			folders:            config.Watch.Folders,
+			categoryFromFolder: categoryFromFolder,
+			dry:                dry,
+			paused:             paused,
+			skipHashCheck:      skipHashCheck,
+			ignoreRules:        ignoreRules,
+			savePath:           savePath,
+			category:           category,
+			tags:               tags,
+			seen:               map[string]struct{}{},
+		}
+
+		for _, dir := range []string{w.doneDir, w.failedDir} {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return errors.Wrapf(err, "could not create dir: %s", dir)
+			}
+		}
+
+		qbtSettings := qbittorrent.Config{
+			Host:      config.Qbit.Addr,
+			APIKey:    config.Qbit.APIKey,
+			Username:  config.Qbit.Login,
+			Password:  config.Qbit.Password,
+			BasicUser: config.Qbit.BasicUser,
+			BasicPass: config.Qbit.BasicPass,
+		}
+
+		w.qb = qbittorrent.NewClient(qbtSettings)
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if err := w.qb.LoginCtx(ctx); err != nil {
+			return errors.Wrap(err, "could not login to qbit")
+		}
+
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return errors.Wrap(err, "could not create watcher")
+		}
+		defer watcher.Close()
+
+		pending := map[string]time.Time{}
+
+		// scan adds every file to pending and every dir to the watcher
+		scan := func() {
+			err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					log.Printf("could not read %s: %q\n", path, err)
+					return nil
+				}
+
+				if d.IsDir() {
+					if w.skipDir(path) {
+						return filepath.SkipDir
+					}
+
+					if err := watcher.Add(path); err != nil {
+						log.Printf("could not watch dir %s: %q\n", path, err)
+					}
+
+					return nil
+				}
+
+				if isWatchFile(path) {
+					if _, ok := pending[path]; !ok {
+						pending[path] = time.Now()
+					}
+				}
+
+				return nil
+			})
+			if err != nil {
+				log.Printf("could not scan watch dir %s: %q\n", root, err)
+			}
+		}
+
+		log.Printf("watching %s for new torrents\n", root)
+
+		scan()
+
+		rescan := time.NewTicker(interval)
+		defer rescan.Stop()
+
+		tick := time.NewTicker(max(settle/2, 100*time.Millisecond))
+		defer tick.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				log.Println("stopped watching")
+				return nil
+
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return nil
+				}
+
+				if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+					continue
+				}
+
+				stat, err := os.Stat(event.Name)
+				if err != nil {
+					continue
+				}
+
+				if stat.IsDir() {
+					// new subfolder, pick up the dir and anything already in it
+					if !w.skipDir(event.Name) {
+						scan()
+					}
+					continue
+				}
+
+				if isWatchFile(event.Name) {
+					pending[event.Name] = time.Now()
+				}
+
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return nil
+				}
+
+				log.Printf("watcher error: %q\n", err)
+
+			case <-rescan.C:
+				scan()
+
+			case <-tick.C:
+				for path, changed := range pending {
+					if time.Since(changed) < settle {
+						continue
+					}
+
+					delete(pending, path)
+
+					if err := w.process(ctx, path); err != nil {
+						if errors.Is(err, context.Canceled) {
+							return nil
+						}
+
+						log.Printf("could not process %s: %q\n", path, err)
+					}
+				}
+			}
+		}
+	}
+
+	return command
+}
+
+type torrentWatcher struct {
+	qb *qbittorrent.Client
+
+	root               string
+	doneDir            string
+	failedDir          string
+	folders            map[string]domain.WatchFolder
+	categoryFromFolder bool
+
+	dry           bool
+	paused        bool
+	skipHashCheck bool
+	ignoreRules   bool
+	savePath      string
+	category      string
+	tags          []string
+
+	seen map[string]struct{}
+}
+
+// skipDir reports whether dir is the done or failed dir
+func (w *torrentWatcher) skipDir(dir string) bool {
+	return dir == w.doneDir || dir == w.failedDir
+}
+
+// process adds the file at path and moves it to the done or failed dir.
+// Files deferred by rules are left in place.
+func (w *torrentWatcher) process(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err != nil {
+		// already moved or removed
+		return nil
+	}
+
+	if config.Rules.Enabled && !w.ignoreRules {
+		ok, err := checkAddRules(ctx, w.qb)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			log.Printf("deferred %s until next rescan\n", path)
+			return nil
+		}
+	}
+
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil {
+		return err
+	}
+
+	folder := resolveWatchFolder(rel, w.folders, w.categoryFromFolder)
+	options := w.options(folder)
+
+	if config.Rules.Enabled && !w.ignoreRules {
+		ok, err := w.checkFreeSpace(ctx, path, options["savepath"])
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			log.Printf("deferred %s until next rescan\n", path)
+			return nil
+		}
+	}
+
+	if w.dry {
+		// files stay in place on dry-run so only report them once
+		if _, ok := w.seen[path]; ok {
+			return nil
+		}
+		w.seen[path] = struct{}{}
+
+		log.Printf("dry-run: add %s with category %q tags %q\n", path, options["category"], options["tags"])
+		return nil
+	}
+
+	if err := w.add(ctx, path, options); err != nil {
+		if errors.Is(err, context.Canceled) {
+			return err
+		}
+
+		log.Printf("could not add %s: %q\n", path, err)
+
+		dest, moveErr := moveWatchFile(path, w.failedDir, rel)
+		if moveErr != nil {
+			return errors.Wrapf(moveErr, "could not move file to failed dir: %s", path)
+		}
+
+		log.Printf("moved %s to %s\n", path, dest)
+
+		return nil
+	}
+
+	dest, err := moveWatchFile(path, w.doneDir, rel)
+	if err != nil {
+		return errors.Wrapf(err, "could not move file to done dir: %s", path)
+	}
+
+	log.Printf("successfully added %s, moved to %s\n", path, dest)
+
+	return nil
+}
+
+// checkFreeSpace reports whether the torrent file fits above the min_free_space rule.
+// Magnets and unreadable files are checked with a size of 0.
+func (w *torrentWatcher) checkFreeSpace(ctx context.Context, path, savePath string) (bool, error) {
+	rule, err := parseFreeSpaceRule(config.Rules)
+	if err != nil || rule == nil {
+		return true, err
+	}
+
+	guard, err := newSpaceGuard(ctx, w.qb, rule, savePath)
+	if err != nil {
+		return false, err
+	}
+
+	var size int64
+	if !strings.EqualFold(filepath.Ext(path), ".magnet") {
+		if c, err := candidateFromFile(path); err == nil {
+			size = c.Size
+		}
+	}
+
+	return guard.allow(size), nil
+}
+
+func (w *torrentWatcher) add(ctx context.Context, path string, options map[string]string) error {
+	if strings.EqualFold(filepath.Ext(path), ".magnet") {
+		magnet, err := readMagnetFile(path)
+		if err != nil {
+			return err
+		}
+
+		_, err = w.qb.AddTorrentFromUrlCtx(ctx, magnet, options)
+		return err
+	}
+
+	_, err := w.qb.AddTorrentFromFileCtx(ctx, path, options)
+	return err
+}
+
+func (w *torrentWatcher) options(folder domain.WatchFolder) map[string]string {
+	options := map[string]string{}
+
+	if w.paused || folder.Paused {
+		options["paused"] = "true"
+		options["stopped"] = "true"
+	}
+	if w.skipHashCheck {
+		options["skip_checking"] = "true"
+	}
+	if config.Add.Sequential {
+		options["sequentialDownload"] = "true"
+	}
+	if config.Add.FirstLastPiece {
+		options["firstLastPiecePrio"] = "true"
+	}
+
+	savePath := folder.SavePath
+	if w.savePath != "" {
+		savePath = w.savePath
+	}
+	if savePath != "" {
+		options["savepath"] = savePath
+		options["autoTMM"] = "false"
+	}
+
+	category := folder.Category
+	if w.category != "" {
+		category = w.category
+	}
+	if category != "" {
+		options["category"] = category
+	}
+
+	tags := append(append([]string{}, folder.Tags...), w.tags...)
+	if len(tags) > 0 {
+		options["tags"] = strings.Join(tags, ",")
+	}
+
+	return options
+}
+
+// resolveWatchFolder returns the settings for a file at rel path in the watch dir.
+// The deepest subfolder mapped in folders wins. Keys are matched case-insensitively as
+// the config lowercases them. Unmapped files in a subfolder get the top subfolder as category
+// when categoryFromFolder is set.
+func resolveWatchFolder(rel string, folders map[string]domain.WatchFolder, categoryFromFolder bool) domain.WatchFolder {
+	dir := filepath.ToSlash(filepath.Dir(rel))
+	if dir == "." {
+		return domain.WatchFolder{}
+	}
+
+	mapped := make(map[string]domain.WatchFolder, len(folders))
+	for key, folder := range folders {
+		mapped[strings.ToLower(strings.Trim(filepath.ToSlash(key), "/"))] = folder
+	}
+
+	for d := dir; d != "." && d != "/"; d = filepath.ToSlash(filepath.Dir(d)) {
+		if folder, ok := mapped[strings.ToLower(d)]; ok {
+			return folder
+		}
+	}
+
+	if !categoryFromFolder {
+		return domain.WatchFolder{}
+	}
+
+	top, _, _ := strings.Cut(dir, "/")
+
+	return domain.WatchFolder{Category: top}
+}
+
+// watchDir returns dir relative to root, or root/fallback if dir is empty
+func watchDir(root, dir, fallback string) string {
+	if dir == "" {
+		dir = fallback
+	}
+
+	if expanded, err := utils.ExpandTilde(dir); err == nil {
+		dir = expanded
+	}
+
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+
+	return filepath.Clean(dir)
+}
+
+func isWatchFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".torrent", ".magnet":
+		return true
+	}
+
+	return false
+}
+
+// readMagnetFile returns the first non-empty line of a .magnet file
+func readMagnetFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(line, "magnet:") {
+			return "", errors.Errorf("not a magnet link: %s", line)
+		}
+
+		return line, nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", errors.New("no magnet link found")
+}
+
+// moveWatchFile moves path to dir/rel and returns the new path.
+// A timestamp is added to the name if the destination already exists.
+func moveWatchFile(path, dir, rel string) (string, error) {
+	dest := filepath.Join(dir, rel)
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(dest); err == nil {
+		ext := filepath.Ext(dest)
+		dest = strings.TrimSuffix(dest, ext) + "." + time.Now().Format("20060102150405") + ext
+	}
+
+	// the done and failed dirs can be on another filesystem
+	if err := fsutil.Move(path, dest); err != nil {
+		return "", err
+	}
+
+	return dest, nil
+}
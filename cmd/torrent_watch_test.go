@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ludviglundgren/qbittorrent-cli/internal/domain"
+)
+
+func TestResolveWatchFolder(t *testing.T) {
+	folders := map[string]domain.WatchFolder{
+		"tv":       {Category: "series", Tags: []string{"tv"}},
+		"tv/anime": {Category: "anime"},
+		"movies":   {Category: "films", SavePath: "/data/movies", Paused: true},
+	}
+
+	tests := []struct {
+		name               string
+		rel                string
+		categoryFromFolder bool
+		want               domain.WatchFolder
+	}{
+		{name: "root", rel: "file.torrent", categoryFromFolder: true, want: domain.WatchFolder{}},
+		{name: "mapped", rel: "tv/file.torrent", categoryFromFolder: true, want: folders["tv"]},
+		{name: "mapped case-insensitive", rel: "Movies/file.torrent", categoryFromFolder: true, want: folders["movies"]},
+		{name: "deepest mapping wins", rel: "tv/anime/file.torrent", categoryFromFolder: true, want: folders["tv/anime"]},
+		{name: "parent mapping", rel: "tv/hd/file.magnet", categoryFromFolder: true, want: folders["tv"]},
+		{name: "unmapped folder as category", rel: "music/flac/file.torrent", categoryFromFolder: true, want: domain.WatchFolder{Category: "music"}},
+		{name: "unmapped folder", rel: "music/file.torrent", categoryFromFolder: false, want: domain.WatchFolder{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resolveWatchFolder(filepath.FromSlash(tt.rel), folders, tt.categoryFromFolder)
+			if got.Category != tt.want.Category || got.SavePath != tt.want.SavePath || got.Paused != tt.want.Paused || len(got.Tags) != len(tt.want.Tags) {
+				t.Errorf("resolveWatchFolder() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadMagnetFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr bool
+	}{
+		{name: "magnet", content: "magnet:?xt=urn:btih:5dee65101db281ac9c46344cd6b175cdcad53426\n", want: "magnet:?xt=urn:btih:5dee65101db281ac9c46344cd6b175cdcad53426"},
+		{name: "leading blank lines", content: "\n  \nmagnet:?xt=urn:btih:5dee65101db281ac9c46344cd6b175cdcad53426  \n", want: "magnet:?xt=urn:btih:5dee65101db281ac9c46344cd6b175cdcad53426"},
+		{name: "not a magnet", content: "https://example.org/file.torrent\n", wantErr: true},
+		{name: "empty", content: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file.magnet")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := readMagnetFile(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readMagnetFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("readMagnetFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoveWatchFile(t *testing.T) {
+	watchDir := t.TempDir()
+	doneDir := t.TempDir()
+
+	src := filepath.Join(watchDir, "tv", "file.torrent")
+	if err := os.MkdirAll(filepath.Dir(src), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(src, []byte("torrent"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dest, err := moveWatchFile(src, doneDir, filepath.Join("tv", "file.torrent"))
+	if err != nil {
+		t.Fatalf("moveWatchFile() error = %v", err)
+	}
+	if want := filepath.Join(doneDir, "tv", "file.torrent"); dest != want {
+		t.Errorf("moveWatchFile() = %s, want %s", dest, want)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Errorf("moveWatchFile() left the file in the watch dir")
+	}
+
+	// an existing file in the destination is kept
+	if err := os.WriteFile(src, []byte("torrent"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := moveWatchFile(src, doneDir, filepath.Join("tv", "file.torrent"))
+	if err != nil {
+		t.Fatalf("moveWatchFile() error = %v", err)
+	}
+	if second == dest {
+		t.Errorf("moveWatchFile() overwrote %s", dest)
+	}
+	if _, err := os.Stat(dest); err != nil {
+		t.Errorf("moveWatchFile() removed the existing file: %v", err)
+	}
+}
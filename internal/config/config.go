@@ -13,13 +13,19 @@ import (
 )
 
 var (
-	CfgFile    string
-	Config     domain.AppConfig
-	Qbit       domain.QbitConfig
-	Compare    []domain.QbitConfig
-	Reannounce domain.ReannounceSettings
-	Rules      domain.Rules
-	Add        domain.AddConfig
+	CfgFile     string
+	Config      domain.AppConfig
+	Qbit        domain.QbitConfig
+	Compare     []domain.QbitConfig
+	Reannounce  domain.ReannounceSettings
+	Rules       domain.Rules
+	Add         domain.AddConfig
+	Watch       domain.WatchConfig
+	Download    domain.DownloadConfig
+	Recycle     domain.RecycleConfig
+	Issues      domain.IssuesConfig
+	Trackers    []domain.TrackerConfig
+	ShareLimits []domain.ShareLimitPolicy
 )
 
 // InitConfig initialize config
@@ -62,4 +68,15 @@ func InitConfig() {
 	Reannounce = Config.Reannounce
 	Rules = Config.Rules
 	Add = Config.Add
+	Watch = Config.Watch
+	Download = Config.Download
+	Recycle = Config.Recycle
+	Issues = Config.Issues
+	Trackers = Config.Trackers
+	ShareLimits = Config.ShareLimits
+}
+
+// Dir returns the directory of the config file in use
+func Dir() string {
+	return filepath.Dir(viper.ConfigFileUsed())
 }
@@ -11,27 +11,148 @@ type QbitConfig struct {
 	BasicPass string `mapstructure:"basicPass"`
 }
 
+// ReannounceSettings intervals are in milliseconds
 type ReannounceSettings struct {
-	Enabled  bool `mapstructure:"enabled"`
-	Attempts int  `mapstructure:"attempts"`
-	Interval int  `mapstructure:"interval"`
+	Enabled        bool `mapstructure:"enabled"`
+	Attempts       int  `mapstructure:"attempts"`
+	Interval       int  `mapstructure:"interval"`
+	MaxInterval    int  `mapstructure:"max_interval"`
+	DomainInterval int  `mapstructure:"domain_interval"`
+	Workers        int  `mapstructure:"workers"`
 }
 
+// Rules are checked before adding torrents. MinFreeSpace is a size like 50GB,
+// FreeSpaceSource is client or local.
 type Rules struct {
-	Enabled            bool `mapstructure:"enabled"`
-	MaxActiveDownloads int  `mapstructure:"max_active_downloads"`
+	Enabled            bool   `mapstructure:"enabled"`
+	MaxActiveDownloads int    `mapstructure:"max_active_downloads"`
+	MinFreeSpace       string `mapstructure:"min_free_space"`
+	FreeSpaceSource    string `mapstructure:"free_space_source"`
+	FreeSpacePath      string `mapstructure:"free_space_path"`
+	PauseOnLowSpace    bool   `mapstructure:"pause_on_low_space"`
 }
 
 type AddConfig struct {
-	Sequential     bool `mapstructure:"sequential"`
-	FirstLastPiece bool `mapstructure:"first_last_piece"`
+	Sequential     bool                 `mapstructure:"sequential"`
+	FirstLastPiece bool                 `mapstructure:"first_last_piece"`
+	Presets        map[string]AddPreset `mapstructure:"presets"`
+}
+
+// AddPreset is a named set of options for torrent add. Share limits are pointers
+// as 0 is a valid limit, and Sequential and FirstLastPiece so a preset can turn off the [add] defaults.
+type AddPreset struct {
+	Category                 string   `mapstructure:"category"`
+	Tags                     []string `mapstructure:"tags"`
+	SavePath                 string   `mapstructure:"save_path"`
+	Paused                   bool     `mapstructure:"paused"`
+	SkipHashCheck            bool     `mapstructure:"skip_hash_check"`
+	Sequential               *bool    `mapstructure:"sequential"`
+	FirstLastPiece           *bool    `mapstructure:"first_last_piece"`
+	UploadLimit              uint64   `mapstructure:"limit_ul"`
+	DownloadLimit            uint64   `mapstructure:"limit_dl"`
+	StopCondition            string   `mapstructure:"stop_condition"`
+	ContentLayout            string   `mapstructure:"content_layout"`
+	IncludeFiles             []string `mapstructure:"include_files"`
+	ExcludeFiles             []string `mapstructure:"exclude_files"`
+	RatioLimit               *float64 `mapstructure:"ratio_limit"`
+	SeedingTimeLimit         *int64   `mapstructure:"seeding_time_limit"`
+	InactiveSeedingTimeLimit *int64   `mapstructure:"inactive_seeding_time_limit"`
+}
+
+type WatchFolder struct {
+	Category string   `mapstructure:"category"`
+	Tags     []string `mapstructure:"tags"`
+	SavePath string   `mapstructure:"save_path"`
+	Paused   bool     `mapstructure:"paused"`
+}
+
+type WatchConfig struct {
+	DoneDir   string                 `mapstructure:"done_dir"`
+	FailedDir string                 `mapstructure:"failed_dir"`
+	Folders   map[string]WatchFolder `mapstructure:"folders"`
+}
+
+// DownloadHost sets headers and cookies for downloads from a host and its subdomains
+type DownloadHost struct {
+	Host    string            `mapstructure:"host"`
+	Headers map[string]string `mapstructure:"headers"`
+	Cookie  string            `mapstructure:"cookie"`
+}
+
+// DownloadConfig Retries is a pointer so 0 disables retries while unset uses the default
+type DownloadConfig struct {
+	Timeout int            `mapstructure:"timeout"`
+	Retries *int           `mapstructure:"retries"`
+	MaxSize int64          `mapstructure:"max_size"`
+	Hosts   []DownloadHost `mapstructure:"hosts"`
+}
+
+// RecycleConfig Dir is where torrent remove --recycle moves the files of removed torrents
+type RecycleConfig struct {
+	Dir string `mapstructure:"dir"`
+}
+
+// IssueClass tags torrents with a tracker message matching one of the Patterns. Patterns are case-insensitive regex.
+type IssueClass struct {
+	Tag      string   `mapstructure:"tag"`
+	Patterns []string `mapstructure:"patterns"`
+}
+
+// TrackerIssues are extra patterns per issue class for a tracker domain and its subdomains
+type TrackerIssues struct {
+	Domain   string              `mapstructure:"domain"`
+	Patterns map[string][]string `mapstructure:"patterns"`
+}
+
+// IssuesConfig StateFile is where torrent tag issues --action saves detections between runs
+type IssuesConfig struct {
+	StateFile string                `mapstructure:"state_file"`
+	Classes   map[string]IssueClass `mapstructure:"classes"`
+	Trackers  []TrackerIssues       `mapstructure:"trackers"`
+}
+
+// ShareLimits are the share limits of torrents. Limits that are not set are not changed.
+// -2 is the global limit and -1 is unlimited, times are in minutes.
+type ShareLimits struct {
+	Ratio               *float64 `mapstructure:"ratio"`
+	SeedingTime         *int64   `mapstructure:"seeding_time"`
+	InactiveSeedingTime *int64   `mapstructure:"inactive_seeding_time"`
+}
+
+// IsSet reports whether any limit is set
+func (l ShareLimits) IsSet() bool {
+	return l.Ratio != nil || l.SeedingTime != nil || l.InactiveSeedingTime != nil
+}
+
+// TrackerConfig maps the trackers on Domains and their subdomains to a Tag, and optionally a Category and share limits
+type TrackerConfig struct {
+	Domains     []string `mapstructure:"domains"`
+	Tag         string   `mapstructure:"tag"`
+	Category    string   `mapstructure:"category"`
+	ShareLimits `mapstructure:",squash"`
+}
+
+// ShareLimitPolicy sets the share limits of torrents on a tracker on Domains or their subdomains, in one of Categories
+// and with one of Tags. Empty lists match every torrent.
+type ShareLimitPolicy struct {
+	Name        string   `mapstructure:"name"`
+	Domains     []string `mapstructure:"domains"`
+	Categories  []string `mapstructure:"categories"`
+	Tags        []string `mapstructure:"tags"`
+	ShareLimits `mapstructure:",squash"`
 }
 
 type AppConfig struct {
-	Debug      bool               `mapstructure:"debug"`
-	Qbit       QbitConfig         `mapstructure:"qbittorrent"`
-	Reannounce ReannounceSettings `mapstructure:"reannounce"`
-	Rules      Rules              `mapstructure:"rules"`
-	Add        AddConfig          `mapstructure:"add"`
-	Compare    []QbitConfig       `mapstructure:"compare"`
+	Debug       bool               `mapstructure:"debug"`
+	Qbit        QbitConfig         `mapstructure:"qbittorrent"`
+	Reannounce  ReannounceSettings `mapstructure:"reannounce"`
+	Rules       Rules              `mapstructure:"rules"`
+	Add         AddConfig          `mapstructure:"add"`
+	Compare     []QbitConfig       `mapstructure:"compare"`
+	Watch       WatchConfig        `mapstructure:"watch"`
+	Download    DownloadConfig     `mapstructure:"download"`
+	Recycle     RecycleConfig      `mapstructure:"recycle"`
+	Issues      IssuesConfig       `mapstructure:"issues"`
+	Trackers    []TrackerConfig    `mapstructure:"trackers"`
+	ShareLimits []ShareLimitPolicy `mapstructure:"share_limits"`
 }
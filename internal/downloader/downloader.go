@@ -0,0 +1,243 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ludviglundgren/qbittorrent-cli/internal/domain"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultTimeout = 30 * time.Second
+	DefaultRetries = 3
+	DefaultBackoff = 1 * time.Second
+	// DefaultMaxSize is the default torrent file size limit of qBittorrent
+	DefaultMaxSize = 100 << 20
+
+	userAgent = "qbittorrent-cli"
+)
+
+type Options struct {
+	Timeout time.Duration
+	Retries int
+	Backoff time.Duration
+	MaxSize int64
+	Hosts   []domain.DownloadHost
+}
+
+// OptionsFromConfig returns the download options from config, with defaults for unset values.
+// Retries 0 disables retries.
+func OptionsFromConfig(cfg domain.DownloadConfig) Options {
+	opts := Options{
+		Timeout: DefaultTimeout,
+		Retries: DefaultRetries,
+		Backoff: DefaultBackoff,
+		MaxSize: DefaultMaxSize,
+		Hosts:   cfg.Hosts,
+	}
+
+	if cfg.Timeout > 0 {
+		opts.Timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+	if cfg.Retries != nil && *cfg.Retries >= 0 {
+		opts.Retries = *cfg.Retries
+	}
+	if cfg.MaxSize > 0 {
+		opts.MaxSize = cfg.MaxSize
+	}
+
+	return opts
+}
+
+// Result is a downloaded torrent file, or the magnet link the url redirected to
+type Result struct {
+	Data   []byte
+	Magnet string
+}
+
+type Downloader struct {
+	opts   Options
+	client *http.Client
+}
+
+func New(opts Options) *Downloader {
+	return &Downloader{
+		opts: opts,
+		client: &http.Client{
+			Timeout: opts.Timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				// stop at redirects to magnet links and return the redirect response
+				if req.URL.Scheme == "magnet" {
+					return http.ErrUseLastResponse
+				}
+
+				if len(via) >= 10 {
+					return errors.New("stopped after 10 redirects")
+				}
+
+				// headers are copied on redirects, so reset them for the new host
+				setHostHeaders(req, opts.Hosts)
+
+				return nil
+			},
+		},
+	}
+}
+
+// permanentError is not retried
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Download downloads and validates the torrent file at url.
+// Network errors, 429 and 5xx responses are retried with exponential backoff.
+func (d *Downloader) Download(ctx context.Context, url string) (*Result, error) {
+	backoff := d.opts.Backoff
+
+	var err error
+	for attempt := 0; attempt <= d.opts.Retries; attempt++ {
+		if attempt > 0 {
+			log.Printf("[%d/%d] retrying download in %s: %s: %q\n", attempt, d.opts.Retries, backoff, url, err)
+
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(backoff):
+			}
+
+			backoff *= 2
+		}
+
+		var res *Result
+		res, err = d.download(ctx, url)
+		if err == nil {
+			return res, nil
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) || ctx.Err() != nil {
+			return nil, err
+		}
+	}
+
+	return nil, errors.Wrapf(err, "download failed after %d attempts", d.opts.Retries+1)
+}
+
+func (d *Downloader) download(ctx context.Context, url string) (*Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, &permanentError{errors.Wrapf(err, "invalid url: %s", url)}
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+	setHostHeaders(req, d.opts.Hosts)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not download file: %s", url)
+	}
+
+	defer resp.Body.Close()
+
+	if location := resp.Header.Get("Location"); strings.HasPrefix(location, "magnet:") {
+		return &Result{Magnet: location}, nil
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated:
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return nil, errors.Errorf("unexpected status: %d", resp.StatusCode)
+	default:
+		return nil, &permanentError{errors.Errorf("unexpected status: %d", resp.StatusCode)}
+	}
+
+	if d.opts.MaxSize > 0 && resp.ContentLength > d.opts.MaxSize {
+		return nil, &permanentError{errors.Errorf("file is larger than max size of %d bytes", d.opts.MaxSize)}
+	}
+
+	body := io.Reader(resp.Body)
+	if d.opts.MaxSize > 0 {
+		body = io.LimitReader(resp.Body, d.opts.MaxSize+1)
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read download")
+	}
+
+	if d.opts.MaxSize > 0 && int64(len(data)) > d.opts.MaxSize {
+		return nil, &permanentError{errors.Errorf("file is larger than max size of %d bytes", d.opts.MaxSize)}
+	}
+
+	// some sites return a magnet link in the body instead of a redirect
+	if text := strings.TrimSpace(string(data)); strings.HasPrefix(text, "magnet:") && !strings.ContainsAny(text, "\n") {
+		return &Result{Magnet: text}, nil
+	}
+
+	if err := Validate(data); err != nil {
+		return nil, &permanentError{err}
+	}
+
+	return &Result{Data: data}, nil
+}
+
+// Validate checks that data decodes as a torrent file
+func Validate(data []byte) error {
+	mi, err := metainfo.Load(bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrap(err, "not a valid torrent file")
+	}
+
+	if _, err := mi.UnmarshalInfo(); err != nil {
+		return errors.Wrap(err, "not a valid torrent file")
+	}
+
+	return nil
+}
+
+// setHostHeaders sets the configured headers and cookie for the request host.
+// Headers configured for other hosts are removed so they don't leak on redirects.
+func setHostHeaders(req *http.Request, hosts []domain.DownloadHost) {
+	host := strings.ToLower(req.URL.Hostname())
+
+	for _, h := range hosts {
+		for key := range h.Headers {
+			req.Header.Del(key)
+		}
+
+		if h.Cookie != "" {
+			req.Header.Del("Cookie")
+		}
+	}
+
+	for _, h := range hosts {
+		match := strings.ToLower(h.Host)
+		if match == "" || (host != match && !strings.HasSuffix(host, "."+match)) {
+			continue
+		}
+
+		for key, value := range h.Headers {
+			req.Header.Set(key, value)
+		}
+
+		if h.Cookie != "" {
+			req.Header.Set("Cookie", h.Cookie)
+		}
+	}
+}
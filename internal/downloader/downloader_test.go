@@ -0,0 +1,174 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ludviglundgren/qbittorrent-cli/internal/domain"
+)
+
+const testTorrent = "../../test/data/Scikit-learn MachineLearninginPython.pdf-5ba4939a00a9b21629a0ad7d376898b768d997a3.torrent"
+
+func Test_setHostHeaders(t *testing.T) {
+	hosts := []domain.DownloadHost{
+		{Host: "tracker.example.org", Headers: map[string]string{"x-api-key": "key"}, Cookie: "uid=1"},
+	}
+
+	tests := []struct {
+		name       string
+		url        string
+		wantKey    string
+		wantCookie string
+	}{
+		{name: "host", url: "https://tracker.example.org/dl/1", wantKey: "key", wantCookie: "uid=1"},
+		{name: "subdomain", url: "https://dl.tracker.example.org/1", wantKey: "key", wantCookie: "uid=1"},
+		{name: "other host", url: "https://cdn.example.net/1"},
+		{name: "suffix is not a subdomain", url: "https://eviltracker.example.org/1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// headers left from a redirect
+			req.Header.Set("X-Api-Key", "key")
+			req.Header.Set("Cookie", "uid=1")
+
+			setHostHeaders(req, hosts)
+
+			if got := req.Header.Get("X-Api-Key"); got != tt.wantKey {
+				t.Errorf("X-Api-Key = %v, want %v", got, tt.wantKey)
+			}
+			if got := req.Header.Get("Cookie"); got != tt.wantCookie {
+				t.Errorf("Cookie = %v, want %v", got, tt.wantCookie)
+			}
+		})
+	}
+}
+
+func TestDownloader_Download(t *testing.T) {
+	torrentData, err := os.ReadFile(testTorrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	magnet := "magnet:?xt=urn:btih:5ba4939a00a9b21629a0ad7d376898b768d997a3"
+
+	var failures atomic.Int32
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(torrentData)
+	})
+	mux.HandleFunc("/auth", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Cookie") != "uid=1; pass=secret" || r.Header.Get("X-Api-Key") != "key" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write(torrentData)
+	})
+	mux.HandleFunc("/flaky", func(w http.ResponseWriter, r *http.Request) {
+		if failures.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(torrentData)
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, magnet, http.StatusFound)
+	})
+	mux.HandleFunc("/magnet-body", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(magnet + "\n"))
+	})
+	mux.HandleFunc("/html", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html>login</html>"))
+	})
+	mux.HandleFunc("/notfound", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	host := strings.Split(strings.TrimPrefix(server.URL, "http://"), ":")[0]
+
+	tests := []struct {
+		name       string
+		path       string
+		maxSize    int64
+		wantMagnet string
+		wantData   bool
+		wantErr    string
+	}{
+		{name: "ok", path: "/ok", wantData: true},
+		{name: "host headers and cookie", path: "/auth", wantData: true},
+		{name: "retry 5xx", path: "/flaky", wantData: true},
+		{name: "redirect to magnet", path: "/redirect", wantMagnet: magnet},
+		{name: "magnet in body", path: "/magnet-body", wantMagnet: magnet},
+		{name: "not a torrent", path: "/html", wantErr: "not a valid torrent file"},
+		{name: "not found is not retried", path: "/notfound", wantErr: "unexpected status: 404"},
+		{name: "too large", path: "/ok", maxSize: 100, wantErr: "larger than max size"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{
+				Timeout: 5 * time.Second,
+				Retries: 3,
+				Backoff: 10 * time.Millisecond,
+				MaxSize: DefaultMaxSize,
+				Hosts: []domain.DownloadHost{
+					{Host: host, Headers: map[string]string{"x-api-key": "key"}, Cookie: "uid=1; pass=secret"},
+				},
+			}
+			if tt.maxSize > 0 {
+				opts.MaxSize = tt.maxSize
+			}
+
+			got, err := New(opts).Download(context.Background(), server.URL+tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Download() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Download() unexpected error = %v", err)
+			}
+			if got.Magnet != tt.wantMagnet {
+				t.Errorf("Download() magnet = %v, want %v", got.Magnet, tt.wantMagnet)
+			}
+			if (len(got.Data) > 0) != tt.wantData {
+				t.Errorf("Download() data = %d bytes, want data %v", len(got.Data), tt.wantData)
+			}
+		})
+	}
+}
+
+func TestOptionsFromConfig_retries(t *testing.T) {
+	zero, five := 0, 5
+
+	tests := []struct {
+		name    string
+		retries *int
+		want    int
+	}{
+		{name: "unset", retries: nil, want: DefaultRetries},
+		{name: "no retries", retries: &zero, want: 0},
+		{name: "set", retries: &five, want: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OptionsFromConfig(domain.DownloadConfig{Retries: tt.retries}).Retries; got != tt.want {
+				t.Errorf("OptionsFromConfig() retries = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
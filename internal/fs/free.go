@@ -0,0 +1,22 @@
+//go:build !windows
+
+package fs
+
+import "syscall"
+
+// DiskUsage returns the size of the filesystem of path and the bytes available to unprivileged users
+func DiskUsage(path string) (total int64, free int64, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+
+	return int64(stat.Blocks) * int64(stat.Bsize), int64(stat.Bavail) * int64(stat.Bsize), nil
+}
+
+// FreeSpace returns the bytes available to unprivileged users on the filesystem of path
+func FreeSpace(path string) (int64, error) {
+	_, free, err := DiskUsage(path)
+
+	return free, err
+}
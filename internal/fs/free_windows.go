@@ -0,0 +1,25 @@
+package fs
+
+import "golang.org/x/sys/windows"
+
+// DiskUsage returns the size of the volume of path and the bytes available to the current user
+func DiskUsage(path string) (total int64, free int64, err error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var available, size uint64
+	if err := windows.GetDiskFreeSpaceEx(p, &available, &size, nil); err != nil {
+		return 0, 0, err
+	}
+
+	return int64(size), int64(available), nil
+}
+
+// FreeSpace returns the bytes available to the current user on the volume of path
+func FreeSpace(path string) (int64, error) {
+	_, free, err := DiskUsage(path)
+
+	return free, err
+}
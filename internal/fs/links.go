@@ -0,0 +1,24 @@
+//go:build !windows
+
+package fs
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// LinkCount returns the number of hard links to the file at path
+func LinkCount(path string) (uint64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, errors.New("link count not supported")
+	}
+
+	return uint64(stat.Nlink), nil
+}
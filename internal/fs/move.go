@@ -0,0 +1,44 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Move moves the file or directory src to dst, creating the parent directories of dst.
+// Destination must *not* exist. When src can not be renamed, like across filesystems,
+// it is copied and removed instead.
+func Move(src, dst string) error {
+	si, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("destination already exists: %s", dst)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	if si.IsDir() {
+		err = CopyDir(src, dst)
+	} else {
+		err = CopyFile(src, dst)
+	}
+
+	if err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+
+	return os.RemoveAll(src)
+}
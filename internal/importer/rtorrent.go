@@ -192,8 +192,13 @@ func (i *RTorrentImport) Import(opts Options) error {
 		// fill pieces to set as completed
 		newFastResume.FillPieces()
 
-		// Set 20 byte SHA1 hash
-		newFastResume.InfoHash = newFastResume.GetInfoHashSHA1()
+		// Set 20 byte SHA1 hash for v1 and hybrid torrents
+		if newFastResume.HasV1() {
+			newFastResume.InfoHash = newFastResume.GetInfoHashSHA1()
+		}
+
+		// Set 32 byte SHA256 hash for v2 and hybrid torrents
+		newFastResume.InfoHash2 = newFastResume.GetInfoHashSHA256()
 
 		// copy torrent file
 		fastResumeOutFile := filepath.Join(opts.QbitDir, torrentID+".fastresume")
@@ -0,0 +1,328 @@
+package issues
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ludviglundgren/qbittorrent-cli/internal/domain"
+
+	"github.com/pkg/errors"
+)
+
+// Built-in issue classes
+const (
+	ClassUnregistered   = "unregistered"
+	ClassNotWorking     = "not-working"
+	ClassBannedClient   = "banned-client"
+	ClassPasskeyInvalid = "passkey-invalid"
+	ClassRateLimited    = "rate-limited"
+)
+
+// defaultClasses are the built-in classes with their tag and patterns, in the order they are reported.
+// Broad classes have generic substrings, like "unknown" and "down", and are only matched when no other class matches.
+var defaultClasses = []struct {
+	name     string
+	tag      string
+	broad    bool
+	patterns []string
+}{
+	{
+		name:  ClassUnregistered,
+		tag:   "Unregistered",
+		broad: true,
+		patterns: quoteAll(
+			"unregistered",
+			"not registered",
+			"torrent not found",
+			"torrent is not found",
+			"unknown torrent",
+			"retitled",
+			"truncated",
+			"torrent is not authorized for use on this tracker",
+			"infohash not found",
+			"not found",
+			"not exist",
+			"unknown",
+			"uploaded",
+			"upgraded",
+			"season pack",
+			"packs are available",
+			"pack is available",
+			"internal available",
+			"season pack out",
+			"dead",
+			"dupe",
+			"complete season uploaded",
+			"problem with",
+			"specifically banned",
+			"trump",
+			"trumped",
+			"nuked",
+			"i'm sorry dave, i can't do that",
+			"problem with description",
+			"problem with file",
+			"problem with pack",
+			"other",
+			"torrent has been deleted",
+		),
+	},
+	{
+		name:  ClassNotWorking,
+		tag:   "Not Working",
+		broad: true,
+		patterns: quoteAll(
+			"tracker is down",
+			"maintenance",
+			"down",
+			"it may be down",
+			"unreachable",
+			"(unreachable)",
+			"bad gateway",
+			"tracker unavailable",
+		),
+	},
+	{
+		name: ClassBannedClient,
+		tag:  "Banned Client",
+		patterns: []string{
+			`banned client`,
+			`client (is )?(banned|not allowed|not approved|not whitelisted)`,
+			`(unsupported|unapproved|blacklisted) client`,
+		},
+	},
+	{
+		name: ClassPasskeyInvalid,
+		tag:  "Invalid Passkey",
+		patterns: []string{
+			`(invalid|unknown|incorrect|wrong) (passkey|authkey|torrent_pass)`,
+			`(passkey|authkey) (is )?(invalid|not found|incorrect)`,
+		},
+	},
+	{
+		name: ClassRateLimited,
+		tag:  "Rate Limited",
+		patterns: []string{
+			`rate limit`,
+			`too many requests`,
+			`announcing too (fast|often)`,
+			`slow down`,
+		},
+	},
+}
+
+func quoteAll(substrings ...string) []string {
+	patterns := make([]string, 0, len(substrings))
+	for _, s := range substrings {
+		patterns = append(patterns, regexp.QuoteMeta(s))
+	}
+
+	return patterns
+}
+
+// Class is a kind of tracker issue with the tag for its torrents
+type Class struct {
+	Name     string
+	Tag      string
+	broad    bool
+	patterns []*regexp.Regexp
+}
+
+// Classifier matches tracker messages to issue classes
+type Classifier struct {
+	classes []Class
+	// domains holds extra patterns per class for a tracker domain
+	domains map[string]map[string][]*regexp.Regexp
+}
+
+// NewClassifier returns a classifier with the built-in classes and the classes and tracker patterns from config.
+// Patterns from config are added to the built-in patterns of a class, and a tag from config replaces the built-in tag.
+func NewClassifier(cfg domain.IssuesConfig) (*Classifier, error) {
+	c := &Classifier{domains: map[string]map[string][]*regexp.Regexp{}}
+
+	index := map[string]int{}
+
+	for _, d := range defaultClasses {
+		patterns, err := compileAll(d.name, d.patterns)
+		if err != nil {
+			return nil, err
+		}
+
+		index[d.name] = len(c.classes)
+		c.classes = append(c.classes, Class{Name: d.name, Tag: d.tag, broad: d.broad, patterns: patterns})
+	}
+
+	// custom classes are added by name so the order is stable
+	names := make([]string, 0, len(cfg.Classes))
+	for name := range cfg.Classes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		cc := cfg.Classes[name]
+		name = strings.ToLower(name)
+
+		patterns, err := compileAll(name, cc.Patterns)
+		if err != nil {
+			return nil, err
+		}
+
+		if i, ok := index[name]; ok {
+			if cc.Tag != "" {
+				c.classes[i].Tag = cc.Tag
+			}
+
+			c.classes[i].patterns = append(c.classes[i].patterns, patterns...)
+			continue
+		}
+
+		if cc.Tag == "" {
+			return nil, errors.Errorf("issue class %s has no tag", name)
+		}
+
+		index[name] = len(c.classes)
+		c.classes = append(c.classes, Class{Name: name, Tag: cc.Tag, patterns: patterns})
+	}
+
+	for _, t := range cfg.Trackers {
+		d := strings.ToLower(strings.TrimSpace(t.Domain))
+		if d == "" {
+			return nil, errors.New("tracker issues without domain")
+		}
+
+		if c.domains[d] == nil {
+			c.domains[d] = map[string][]*regexp.Regexp{}
+		}
+
+		for name, list := range t.Patterns {
+			name = strings.ToLower(name)
+
+			if _, ok := index[name]; !ok {
+				return nil, errors.Errorf("unknown issue class %s for tracker %s", name, d)
+			}
+
+			patterns, err := compileAll(name, list)
+			if err != nil {
+				return nil, err
+			}
+
+			c.domains[d][name] = append(c.domains[d][name], patterns...)
+		}
+	}
+
+	return c, nil
+}
+
+func compileAll(class string, patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+
+	for _, p := range patterns {
+		re, err := regexp.Compile("(?i)" + p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid pattern for issue class %s: %s", class, p)
+		}
+
+		compiled = append(compiled, re)
+	}
+
+	return compiled, nil
+}
+
+// Classes returns all classes, built-in classes first
+func (c *Classifier) Classes() []Class {
+	return c.classes
+}
+
+// Class returns the class by name
+func (c *Classifier) Class(name string) (Class, bool) {
+	for _, class := range c.classes {
+		if class.Name == strings.ToLower(name) {
+			return class, true
+		}
+	}
+
+	return Class{}, false
+}
+
+// Match returns the names of the classes the tracker message matches, with the patterns of the tracker domain
+// and its parent domains. A message can match more than one class. The broad unregistered and not-working classes
+// are left out when an enabled specific class, like passkey-invalid or a custom class, matches the message.
+// Specific classes that are not enabled never hide a broad class.
+func (c *Classifier) Match(trackerURL, message string, enabled []Class) []string {
+	if message == "" {
+		return nil
+	}
+
+	domainPatterns := c.domainPatterns(Domain(trackerURL))
+
+	var specific, broad []string
+
+	for _, class := range c.classes {
+		if !matchAny(class.patterns, message) && !matchAny(domainPatterns[class.Name], message) {
+			continue
+		}
+
+		if class.broad {
+			broad = append(broad, class.Name)
+		} else {
+			specific = append(specific, class.Name)
+		}
+	}
+
+	for _, name := range specific {
+		for _, class := range enabled {
+			if class.Name == name {
+				return specific
+			}
+		}
+	}
+
+	return append(specific, broad...)
+}
+
+// domainPatterns returns the patterns per class for the host and its parent domains
+func (c *Classifier) domainPatterns(host string) map[string][]*regexp.Regexp {
+	if host == "" || len(c.domains) == 0 {
+		return nil
+	}
+
+	patterns := map[string][]*regexp.Regexp{}
+
+	for d, classes := range c.domains {
+		if host != d && !strings.HasSuffix(host, "."+d) {
+			continue
+		}
+
+		for name, list := range classes {
+			patterns[name] = append(patterns[name], list...)
+		}
+	}
+
+	return patterns
+}
+
+func matchAny(patterns []*regexp.Regexp, message string) bool {
+	for _, re := range patterns {
+		if re.MatchString(message) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Domain returns the lowercase host of the tracker url, or an empty string for DHT, PeX, LSD and invalid urls
+func Domain(trackerURL string) string {
+	if strings.HasPrefix(trackerURL, "**") {
+		return ""
+	}
+
+	u, err := url.Parse(trackerURL)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(u.Hostname())
+}
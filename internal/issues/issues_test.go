@@ -0,0 +1,85 @@
+package issues
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ludviglundgren/qbittorrent-cli/internal/domain"
+)
+
+func TestClassifier_Match(t *testing.T) {
+	classifier, err := NewClassifier(domain.IssuesConfig{
+		Classes: map[string]domain.IssueClass{
+			"Unregistered": {Tag: "Gone", Patterns: []string{`^removed: `}},
+			"h&r":          {Tag: "Hit and Run", Patterns: []string{`hit (and|&) run`}},
+		},
+		Trackers: []domain.TrackerIssues{
+			{Domain: "Tracker.Example.org", Patterns: map[string][]string{"passkey-invalid": {`^err 403$`}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewClassifier() error = %v", err)
+	}
+
+	if c, _ := classifier.Class(ClassUnregistered); c.Tag != "Gone" {
+		t.Errorf("Class() tag = %s, want Gone", c.Tag)
+	}
+
+	unregistered, _ := classifier.Class(ClassUnregistered)
+
+	tests := []struct {
+		name    string
+		url     string
+		message string
+		// enabled is all classes when nil
+		enabled []Class
+		want    []string
+	}{
+		{name: "empty message", url: "https://other.org/announce", message: "", want: nil},
+		{name: "working", url: "https://other.org/announce", message: "OK", want: nil},
+		{name: "built-in", url: "https://other.org/announce", message: "Torrent not registered with this tracker", want: []string{ClassUnregistered}},
+		{name: "config pattern added to built-in", url: "https://other.org/announce", message: "Removed: dupe of 123", want: []string{ClassUnregistered}},
+		{name: "rate limited", url: "https://other.org/announce", message: "Too Many Requests", want: []string{ClassRateLimited}},
+		{name: "banned client", url: "https://other.org/announce", message: "Your client is not allowed here", want: []string{ClassBannedClient}},
+		{name: "custom class", url: "https://other.org/announce", message: "Hit & Run warning", want: []string{"h&r"}},
+		{name: "passkey before unregistered", url: "https://other.org/announce", message: "Unknown passkey", want: []string{ClassPasskeyInvalid}},
+		{name: "passkey not found before unregistered", url: "https://other.org/announce", message: "passkey not found", want: []string{ClassPasskeyInvalid}},
+		{name: "rate limited before not working", url: "https://other.org/announce", message: "Slow down", want: []string{ClassRateLimited}},
+		{name: "passkey not enabled keeps unregistered", url: "https://other.org/announce", message: "Unknown passkey", enabled: []Class{unregistered}, want: []string{ClassPasskeyInvalid, ClassUnregistered}},
+		{name: "rate limited not enabled keeps not working", url: "https://other.org/announce", message: "Slow down", enabled: []Class{unregistered}, want: []string{ClassRateLimited, ClassNotWorking}},
+		{name: "unregistered and not working", url: "https://other.org/announce", message: "torrent not found, tracker is down", want: []string{ClassUnregistered, ClassNotWorking}},
+		{name: "tracker pattern", url: "https://announce.tracker.example.org/announce", message: "err 403", want: []string{ClassPasskeyInvalid}},
+		{name: "tracker pattern other domain", url: "https://other.org/announce", message: "err 403", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enabled := tt.enabled
+			if enabled == nil {
+				enabled = classifier.Classes()
+			}
+
+			if got := classifier.Match(tt.url, tt.message, enabled); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewClassifier_errors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  domain.IssuesConfig
+	}{
+		{name: "invalid pattern", cfg: domain.IssuesConfig{Classes: map[string]domain.IssueClass{"x": {Tag: "X", Patterns: []string{"("}}}}},
+		{name: "custom class without tag", cfg: domain.IssuesConfig{Classes: map[string]domain.IssueClass{"x": {Patterns: []string{"x"}}}}},
+		{name: "tracker without domain", cfg: domain.IssuesConfig{Trackers: []domain.TrackerIssues{{Patterns: map[string][]string{"unregistered": {"x"}}}}}},
+		{name: "tracker unknown class", cfg: domain.IssuesConfig{Trackers: []domain.TrackerIssues{{Domain: "x.org", Patterns: map[string][]string{"missing": {"x"}}}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewClassifier(tt.cfg); err == nil {
+				t.Errorf("NewClassifier() expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package issues
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Detection is a run of consecutive detections of an issue class for a torrent
+type Detection struct {
+	Count int       `json:"count"`
+	First time.Time `json:"first"`
+	Last  time.Time `json:"last"`
+}
+
+// State holds the detections per torrent hash and class between runs
+type State struct {
+	path     string
+	Torrents map[string]map[string]*Detection `json:"torrents"`
+}
+
+// LoadState reads the state file, a missing file is an empty state
+func LoadState(path string) (*State, error) {
+	s := &State{path: path, Torrents: map[string]map[string]*Detection{}}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+
+		return nil, errors.Wrapf(err, "could not read state file: %s", path)
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, errors.Wrapf(err, "could not decode state file: %s", path)
+	}
+
+	if s.Torrents == nil {
+		s.Torrents = map[string]map[string]*Detection{}
+	}
+
+	return s, nil
+}
+
+// Observe records a detection of the class for the torrent and returns the number of consecutive detections.
+// A detection less than interval after the last counted one does not count, so detections are spaced over time.
+func (s *State) Observe(hash, class string, now time.Time, interval time.Duration) (int, bool) {
+	hash = strings.ToLower(hash)
+
+	if s.Torrents[hash] == nil {
+		s.Torrents[hash] = map[string]*Detection{}
+	}
+
+	d := s.Torrents[hash][class]
+	if d == nil {
+		s.Torrents[hash][class] = &Detection{Count: 1, First: now, Last: now}
+		return 1, true
+	}
+
+	if now.Sub(d.Last) < interval {
+		return d.Count, false
+	}
+
+	d.Count++
+	d.Last = now
+
+	return d.Count, true
+}
+
+// Reset clears the detections of the class for the torrent
+func (s *State) Reset(hash, class string) {
+	hash = strings.ToLower(hash)
+
+	delete(s.Torrents[hash], class)
+
+	if len(s.Torrents[hash]) == 0 {
+		delete(s.Torrents, hash)
+	}
+}
+
+// ResetMissing clears the detections of the class for every torrent not in detected,
+// so only consecutive detections are counted
+func (s *State) ResetMissing(class string, detected map[string]struct{}) {
+	for hash := range s.Torrents {
+		if _, ok := detected[hash]; !ok {
+			s.Reset(hash, class)
+		}
+	}
+}
+
+// Save writes the state file
+func (s *State) Save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return errors.Wrapf(err, "could not create state dir: %s", filepath.Dir(s.path))
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "could not encode state")
+	}
+
+	// write to a temp file first so an interrupted run does not leave a broken state
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return errors.Wrapf(err, "could not write state file: %s", tmp)
+	}
+
+	if err := os.Rename(tmp, s.path); err != nil {
+		return errors.Wrapf(err, "could not write state file: %s", s.path)
+	}
+
+	return nil
+}
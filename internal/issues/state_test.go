@@ -0,0 +1,69 @@
+package issues
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestState_Observe(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "issues.json")
+
+	s, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("LoadState() error = %v", err)
+	}
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	interval := time.Hour
+
+	steps := []struct {
+		name        string
+		after       time.Duration
+		wantCount   int
+		wantCounted bool
+	}{
+		{name: "first", after: 0, wantCount: 1, wantCounted: true},
+		{name: "too soon", after: 10 * time.Minute, wantCount: 1, wantCounted: false},
+		{name: "spaced", after: time.Hour, wantCount: 2, wantCounted: true},
+		{name: "spaced again", after: 2 * time.Hour, wantCount: 3, wantCounted: true},
+	}
+	for _, step := range steps {
+		count, counted := s.Observe("ABC", ClassUnregistered, now.Add(step.after), interval)
+		if count != step.wantCount || counted != step.wantCounted {
+			t.Errorf("%s: Observe() = %d, %v, want %d, %v", step.name, count, counted, step.wantCount, step.wantCounted)
+		}
+	}
+
+	s.Observe("def", ClassUnregistered, now, interval)
+
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("LoadState() error = %v", err)
+	}
+
+	if d := loaded.Torrents["abc"][ClassUnregistered]; d == nil || d.Count != 3 || !d.First.Equal(now) {
+		t.Fatalf("loaded detection = %+v, want count 3 first %v", d, now)
+	}
+
+	// def is no longer detected so it is cleared
+	loaded.ResetMissing(ClassUnregistered, map[string]struct{}{"abc": {}})
+
+	if _, ok := loaded.Torrents["def"]; ok {
+		t.Errorf("ResetMissing() kept def")
+	}
+
+	if count, _ := loaded.Observe("abc", ClassUnregistered, now.Add(3*time.Hour), interval); count != 4 {
+		t.Errorf("Observe() after load = %d, want 4", count)
+	}
+
+	loaded.Reset("abc", ClassUnregistered)
+
+	if len(loaded.Torrents) != 0 {
+		t.Errorf("Reset() left %v", loaded.Torrents)
+	}
+}
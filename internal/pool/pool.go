@@ -0,0 +1,78 @@
+package pool
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type Options struct {
+	// Concurrency is the number of calls at the same time, at least 1
+	Concurrency int
+	// Rate is the max number of calls per second, 0 is unlimited
+	Rate float64
+	// OnProgress is called after every call with the number of calls done
+	OnProgress func(done, total int)
+}
+
+// Run calls fn for every index below n with at most Concurrency calls at a time.
+// Indexes not started yet are skipped when ctx is canceled, and the ctx error is returned.
+func Run(ctx context.Context, n int, opts Options, fn func(ctx context.Context, i int)) error {
+	var limit <-chan time.Time
+	if opts.Rate > 0 {
+		// rates above 1e9 per second would round the interval down to 0, which panics
+		ticker := time.NewTicker(max(time.Duration(float64(time.Second)/opts.Rate), time.Nanosecond))
+		defer ticker.Stop()
+
+		limit = ticker.C
+	}
+
+	jobs := make(chan int)
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		done int
+	)
+
+	for range min(max(opts.Concurrency, 1), n) {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				if limit != nil {
+					select {
+					case <-limit:
+					case <-ctx.Done():
+						continue
+					}
+				}
+
+				fn(ctx, i)
+
+				mu.Lock()
+				done++
+				if opts.OnProgress != nil {
+					opts.OnProgress(done, n)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for i := range n {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	return ctx.Err()
+}
@@ -0,0 +1,62 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	var active, maxActive atomic.Int32
+
+	seen := make([]atomic.Bool, 20)
+
+	err := Run(context.Background(), len(seen), Options{Concurrency: 4}, func(ctx context.Context, i int) {
+		n := active.Add(1)
+		defer active.Add(-1)
+
+		for {
+			m := maxActive.Load()
+			if n <= m || maxActive.CompareAndSwap(m, n) {
+				break
+			}
+		}
+
+		time.Sleep(2 * time.Millisecond)
+		seen[i].Store(true)
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if got := maxActive.Load(); got > 4 {
+		t.Errorf("Run() max concurrent calls = %d, want <= 4", got)
+	}
+
+	for i := range seen {
+		if !seen[i].Load() {
+			t.Errorf("Run() did not call %d", i)
+		}
+	}
+}
+
+func TestRun_canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var calls atomic.Int32
+
+	err := Run(ctx, 100, Options{Concurrency: 1}, func(ctx context.Context, i int) {
+		if calls.Add(1) == 3 {
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() error = %v, want %v", err, context.Canceled)
+	}
+
+	if got := calls.Load(); got >= 100 {
+		t.Errorf("Run() calls = %d, want stopped after cancel", got)
+	}
+}
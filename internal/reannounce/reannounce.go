@@ -0,0 +1,345 @@
+package reannounce
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ludviglundgren/qbittorrent-cli/internal/domain"
+
+	"github.com/autobrr/go-qbittorrent"
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultWorkers        = 10
+	DefaultAttempts       = 50
+	DefaultInterval       = 7 * time.Second
+	DefaultMaxInterval    = 1 * time.Minute
+	DefaultDomainInterval = 1 * time.Second
+)
+
+type Status string
+
+const (
+	StatusOK       Status = "ok"
+	StatusFailed   Status = "failed"
+	StatusRemoved  Status = "removed"
+	StatusError    Status = "error"
+	StatusCanceled Status = "canceled"
+)
+
+// Client is the part of the qBittorrent client used to reannounce
+type Client interface {
+	GetTorrentTrackersCtx(ctx context.Context, hash string) ([]qbittorrent.TorrentTracker, error)
+	ReAnnounceTorrentsCtx(ctx context.Context, hashes []string) error
+	DeleteTorrentsCtx(ctx context.Context, hashes []string, deleteFiles bool) error
+}
+
+type Options struct {
+	// Workers is the number of torrents reannounced at the same time
+	Workers  int
+	Attempts int
+	// Interval is the wait before the first check, doubled after every attempt up to MaxInterval
+	Interval    time.Duration
+	MaxInterval time.Duration
+	// DomainInterval is the minimum time between reannounces to the same tracker domain
+	DomainInterval time.Duration
+	// RemoveStalled removes torrents without a working tracker after the last attempt
+	RemoveStalled bool
+	// OnResult is called when a torrent is done
+	OnResult func(Result)
+}
+
+// OptionsFromConfig returns the options from config, with defaults for unset values
+func OptionsFromConfig(cfg domain.ReannounceSettings) Options {
+	opts := Options{
+		Workers:        DefaultWorkers,
+		Attempts:       DefaultAttempts,
+		Interval:       DefaultInterval,
+		MaxInterval:    DefaultMaxInterval,
+		DomainInterval: DefaultDomainInterval,
+	}
+
+	if cfg.Workers > 0 {
+		opts.Workers = cfg.Workers
+	}
+	if cfg.Attempts > 0 {
+		opts.Attempts = cfg.Attempts
+	}
+	if cfg.Interval > 0 {
+		opts.Interval = time.Duration(cfg.Interval) * time.Millisecond
+	}
+	if cfg.MaxInterval > 0 {
+		opts.MaxInterval = time.Duration(cfg.MaxInterval) * time.Millisecond
+	}
+	if cfg.DomainInterval != 0 {
+		// negative disables the limit
+		opts.DomainInterval = time.Duration(cfg.DomainInterval) * time.Millisecond
+	}
+
+	return opts
+}
+
+// Result is the outcome of reannouncing a torrent
+type Result struct {
+	Hash     string `json:"hash"`
+	Name     string `json:"name,omitempty"`
+	Tracker  string `json:"tracker,omitempty"`
+	Status   Status `json:"status"`
+	Attempts int    `json:"attempts"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Supervisor reannounces torrents until a tracker works, with at most Workers torrents at a time
+type Supervisor struct {
+	client  Client
+	opts    Options
+	limiter *domainLimiter
+
+	ctx  context.Context
+	sem  chan struct{}
+	wg   sync.WaitGroup
+	mu   sync.Mutex
+	seen map[string]struct{}
+
+	results []Result
+}
+
+// New returns a supervisor reannouncing with ctx, with defaults for unset options
+func New(ctx context.Context, client Client, opts Options) *Supervisor {
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWorkers
+	}
+	if opts.Attempts <= 0 {
+		opts.Attempts = DefaultAttempts
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.MaxInterval < opts.Interval {
+		opts.MaxInterval = opts.Interval
+	}
+
+	return &Supervisor{
+		ctx:     ctx,
+		client:  client,
+		opts:    opts,
+		limiter: newDomainLimiter(opts.DomainInterval),
+		sem:     make(chan struct{}, opts.Workers),
+		seen:    map[string]struct{}{},
+	}
+}
+
+// Add queues the torrent. Torrents already added are ignored and false is returned.
+func (s *Supervisor) Add(hash, name string) bool {
+	s.mu.Lock()
+	if _, ok := s.seen[hash]; ok {
+		s.mu.Unlock()
+		return false
+	}
+	s.seen[hash] = struct{}{}
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		var res Result
+
+		select {
+		case s.sem <- struct{}{}:
+			res = s.reannounce(s.ctx, hash, name)
+			<-s.sem
+		case <-s.ctx.Done():
+			res = Result{Hash: hash, Name: name, Status: StatusCanceled, Duration: "0s"}
+		}
+
+		s.mu.Lock()
+		s.results = append(s.results, res)
+		s.mu.Unlock()
+
+		if s.opts.OnResult != nil {
+			s.opts.OnResult(res)
+		}
+	}()
+
+	return true
+}
+
+// Has reports whether the torrent has been added
+func (s *Supervisor) Has(hash string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.seen[hash]
+
+	return ok
+}
+
+// Wait waits for all added torrents and returns the results in the order they finished
+func (s *Supervisor) Wait() []Result {
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Result(nil), s.results...)
+}
+
+func (s *Supervisor) reannounce(ctx context.Context, hash, name string) Result {
+	start := time.Now()
+
+	res := Result{Hash: hash, Name: name}
+
+	done := func(status Status, err error) Result {
+		res.Status = status
+		res.Duration = time.Since(start).Round(time.Second).String()
+
+		if err != nil {
+			res.Error = err.Error()
+		}
+
+		return res
+	}
+
+	delay := s.opts.Interval
+
+	for {
+		if err := sleep(ctx, delay); err != nil {
+			return done(StatusCanceled, err)
+		}
+
+		trackers, err := s.client.GetTorrentTrackersCtx(ctx, hash)
+		if err != nil {
+			if ctx.Err() != nil {
+				return done(StatusCanceled, ctx.Err())
+			}
+
+			return done(StatusError, errors.Wrapf(err, "could not get trackers of torrent: %s", hash))
+		}
+
+		if res.Tracker == "" {
+			res.Tracker = TrackerDomain(trackers)
+		}
+
+		if IsWorking(trackers) {
+			log.Printf("[%d/%d] found working tracker for %s\n", res.Attempts, s.opts.Attempts, hash)
+			return done(StatusOK, nil)
+		}
+
+		if res.Attempts >= s.opts.Attempts {
+			break
+		}
+
+		if err := s.limiter.wait(ctx, res.Tracker); err != nil {
+			return done(StatusCanceled, err)
+		}
+
+		res.Attempts++
+
+		log.Printf("[%d/%d] reannounce attempt for %s\n", res.Attempts, s.opts.Attempts, hash)
+
+		if err := s.client.ReAnnounceTorrentsCtx(ctx, []string{hash}); err != nil {
+			if ctx.Err() != nil {
+				return done(StatusCanceled, ctx.Err())
+			}
+
+			return done(StatusError, errors.Wrapf(err, "could not reannounce torrent: %s", hash))
+		}
+
+		delay = min(delay*2, s.opts.MaxInterval)
+	}
+
+	if s.opts.RemoveStalled {
+		log.Printf("announce not ok, removing torrent: %s\n", hash)
+
+		if err := s.client.DeleteTorrentsCtx(ctx, []string{hash}, false); err != nil {
+			return done(StatusError, errors.Wrapf(err, "could not remove torrent: %s", hash))
+		}
+
+		return done(StatusRemoved, nil)
+	}
+
+	return done(StatusFailed, errors.New("no working tracker after last attempt"))
+}
+
+// IsWorking reports whether any tracker has been contacted and is working
+func IsWorking(trackers []qbittorrent.TorrentTracker) bool {
+	for _, t := range trackers {
+		if t.Status == qbittorrent.TrackerStatusOK {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TrackerDomain returns the host of the first tracker. DHT, PeX and LSD entries are skipped.
+func TrackerDomain(trackers []qbittorrent.TorrentTracker) string {
+	for _, t := range trackers {
+		if strings.HasPrefix(t.Url, "**") {
+			continue
+		}
+
+		u, err := url.Parse(t.Url)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+
+		return strings.ToLower(u.Hostname())
+	}
+
+	return ""
+}
+
+// domainLimiter spaces out reannounces to the same tracker domain
+type domainLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next map[string]time.Time
+}
+
+func newDomainLimiter(interval time.Duration) *domainLimiter {
+	return &domainLimiter{interval: interval, next: map[string]time.Time{}}
+}
+
+// wait reserves the next slot for the domain and sleeps until it
+func (l *domainLimiter) wait(ctx context.Context, domain string) error {
+	if l.interval <= 0 || domain == "" {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next[domain]
+	if at.Before(now) {
+		at = now
+	}
+	l.next[domain] = at.Add(l.interval)
+	l.mu.Unlock()
+
+	return sleep(ctx, at.Sub(now))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
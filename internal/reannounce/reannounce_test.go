@@ -0,0 +1,240 @@
+package reannounce
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/autobrr/go-qbittorrent"
+)
+
+// fakeClient reports a working tracker after workingAfter reannounces
+type fakeClient struct {
+	mu           sync.Mutex
+	workingAfter map[string]int
+	announces    map[string]int
+	removed      []string
+	trackerErr   error
+
+	active    atomic.Int32
+	maxActive atomic.Int32
+}
+
+func newFakeClient(workingAfter map[string]int) *fakeClient {
+	return &fakeClient{workingAfter: workingAfter, announces: map[string]int{}}
+}
+
+func (c *fakeClient) GetTorrentTrackersCtx(ctx context.Context, hash string) ([]qbittorrent.TorrentTracker, error) {
+	if c.trackerErr != nil {
+		return nil, c.trackerErr
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status := qbittorrent.TrackerStatusNotWorking
+	if after, ok := c.workingAfter[hash]; ok && c.announces[hash] >= after {
+		status = qbittorrent.TrackerStatusOK
+	}
+
+	return []qbittorrent.TorrentTracker{
+		{Url: "** [DHT] **", Status: qbittorrent.TrackerStatusDisabled},
+		{Url: "https://Tracker.example.org:443/announce", Status: status},
+	}, nil
+}
+
+func (c *fakeClient) ReAnnounceTorrentsCtx(ctx context.Context, hashes []string) error {
+	active := c.active.Add(1)
+	defer c.active.Add(-1)
+
+	for {
+		m := c.maxActive.Load()
+		if active <= m || c.maxActive.CompareAndSwap(m, active) {
+			break
+		}
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, hash := range hashes {
+		c.announces[hash]++
+	}
+
+	return nil
+}
+
+func (c *fakeClient) DeleteTorrentsCtx(ctx context.Context, hashes []string, deleteFiles bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.removed = append(c.removed, hashes...)
+
+	return nil
+}
+
+func results(res []Result) map[string]Result {
+	m := map[string]Result{}
+	for _, r := range res {
+		m[r.Hash] = r
+	}
+
+	return m
+}
+
+func TestSupervisor(t *testing.T) {
+	tests := []struct {
+		name          string
+		removeStalled bool
+		trackerErr    error
+		want          map[string]Status
+		wantAttempts  map[string]int
+		wantRemoved   int
+	}{
+		{
+			name:         "working and failed",
+			want:         map[string]Status{"a": StatusOK, "b": StatusOK, "c": StatusFailed},
+			wantAttempts: map[string]int{"a": 0, "b": 2, "c": 3},
+		},
+		{
+			name:          "remove stalled",
+			removeStalled: true,
+			want:          map[string]Status{"a": StatusOK, "b": StatusOK, "c": StatusRemoved},
+			wantRemoved:   1,
+		},
+		{
+			name:       "tracker error does not stop other torrents",
+			trackerErr: errors.New("unavailable"),
+			want:       map[string]Status{"a": StatusError, "b": StatusError, "c": StatusError},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeClient(map[string]int{"a": 0, "b": 2})
+			client.trackerErr = tt.trackerErr
+
+			s := New(context.Background(), client, Options{
+				Workers:       2,
+				Attempts:      3,
+				Interval:      time.Millisecond,
+				MaxInterval:   2 * time.Millisecond,
+				RemoveStalled: tt.removeStalled,
+			})
+
+			for _, hash := range []string{"a", "b", "c"} {
+				s.Add(hash, "")
+			}
+
+			if s.Add("a", "") {
+				t.Errorf("Add() of tracked hash = true, want false")
+			}
+
+			got := results(s.Wait())
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d results, want %d", len(got), len(tt.want))
+			}
+
+			for hash, status := range tt.want {
+				if got[hash].Status != status {
+					t.Errorf("%s status = %s, want %s (%s)", hash, got[hash].Status, status, got[hash].Error)
+				}
+				if attempts, ok := tt.wantAttempts[hash]; ok && got[hash].Attempts != attempts {
+					t.Errorf("%s attempts = %d, want %d", hash, got[hash].Attempts, attempts)
+				}
+			}
+
+			if len(client.removed) != tt.wantRemoved {
+				t.Errorf("removed = %v, want %d", client.removed, tt.wantRemoved)
+			}
+
+			if tt.trackerErr == nil && got["a"].Tracker != "tracker.example.org" {
+				t.Errorf("tracker = %s, want tracker.example.org", got["a"].Tracker)
+			}
+		})
+	}
+}
+
+func TestSupervisor_workers(t *testing.T) {
+	client := newFakeClient(map[string]int{})
+
+	s := New(context.Background(), client, Options{Workers: 2, Attempts: 2, Interval: time.Millisecond, DomainInterval: -1})
+
+	for _, hash := range []string{"a", "b", "c", "d", "e", "f"} {
+		s.Add(hash, "")
+	}
+
+	s.Wait()
+
+	if got := client.maxActive.Load(); got > 2 {
+		t.Errorf("max concurrent reannounces = %d, want <= 2", got)
+	}
+}
+
+func TestSupervisor_canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := New(ctx, newFakeClient(map[string]int{}), Options{Workers: 1, Attempts: 10, Interval: time.Hour})
+
+	s.Add("a", "")
+	s.Add("b", "")
+
+	cancel()
+
+	for _, r := range s.Wait() {
+		if r.Status != StatusCanceled {
+			t.Errorf("%s status = %s, want %s", r.Hash, r.Status, StatusCanceled)
+		}
+	}
+}
+
+func Test_domainLimiter(t *testing.T) {
+	l := newDomainLimiter(20 * time.Millisecond)
+
+	start := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if err := l.wait(context.Background(), "tracker.example.org"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// other domains are not limited
+	if err := l.wait(context.Background(), "other.example.org"); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("3 waits took %s, want >= 40ms", elapsed)
+	}
+}
+
+func TestTrackerDomain(t *testing.T) {
+	tests := []struct {
+		name     string
+		trackers []qbittorrent.TorrentTracker
+		want     string
+	}{
+		{
+			name:     "skips dht",
+			trackers: []qbittorrent.TorrentTracker{{Url: "** [DHT] **"}, {Url: "udp://Tracker.Example.org:1337/announce"}},
+			want:     "tracker.example.org",
+		},
+		{
+			name:     "no trackers",
+			trackers: []qbittorrent.TorrentTracker{{Url: "** [PeX] **"}},
+			want:     "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TrackerDomain(tt.trackers); got != tt.want {
+				t.Errorf("TrackerDomain() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package recycle
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ludviglundgren/qbittorrent-cli/internal/fs"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// entryFile is the sidecar with the torrent details in every entry
+	entryFile = "entry.json"
+	// dataDir holds the torrent files in every entry, with the same structure as in the save path
+	dataDir = "data"
+)
+
+var ErrNotFound = errors.New("entry not found in recycle bin")
+
+// Entry is a removed torrent in the recycle bin
+type Entry struct {
+	Hash     string   `json:"hash"`
+	Name     string   `json:"name"`
+	Category string   `json:"category"`
+	Tags     []string `json:"tags"`
+	Trackers []string `json:"trackers"`
+	SavePath string   `json:"save_path"`
+	// DownloadPath is set when the files were in the download path of an incomplete torrent instead of the save path
+	DownloadPath string    `json:"download_path,omitempty"`
+	Files        []string  `json:"files"`
+	Size         int64     `json:"size"`
+	DeletedAt    time.Time `json:"deleted_at"`
+	// HasTorrent is set when the .torrent file is in the entry so it can be added again on restore
+	HasTorrent bool `json:"has_torrent"`
+}
+
+// Bin is a recycle bin directory with one entry directory per torrent hash
+type Bin struct {
+	Dir string
+}
+
+func New(dir string) *Bin {
+	return &Bin{Dir: dir}
+}
+
+func (b *Bin) entryDir(hash string) string {
+	return filepath.Join(b.Dir, strings.ToLower(hash))
+}
+
+// TorrentFile returns the path of the .torrent file of the entry
+func (b *Bin) TorrentFile(hash string) string {
+	return filepath.Join(b.entryDir(hash), strings.ToLower(hash)+".torrent")
+}
+
+// filesDir returns the directory the files of the entry are relative to
+func (e Entry) filesDir() string {
+	if e.DownloadPath != "" {
+		return e.DownloadPath
+	}
+
+	return e.SavePath
+}
+
+// Add creates the entry and moves the files of the torrent from the save path, or download path, into it.
+// The .torrent file is saved when torrent is not empty. Files that are not found are skipped,
+// and files that could not be moved are returned with their errors.
+func (b *Bin) Add(entry Entry, torrent []byte) (map[string]error, error) {
+	dir := b.entryDir(entry.Hash)
+
+	if _, err := os.Stat(dir); err == nil {
+		return nil, errors.Errorf("entry already exists: %s", dir)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, dataDir), os.ModePerm); err != nil {
+		return nil, errors.Wrapf(err, "could not create entry: %s", dir)
+	}
+
+	if len(torrent) > 0 {
+		if err := os.WriteFile(b.TorrentFile(entry.Hash), torrent, 0644); err != nil {
+			return nil, errors.Wrap(err, "could not write torrent file")
+		}
+
+		entry.HasTorrent = true
+	}
+
+	if entry.DeletedAt.IsZero() {
+		entry.DeletedAt = time.Now()
+	}
+
+	// the sidecar is written before moving so the files can always be restored
+	if err := writeEntry(dir, entry); err != nil {
+		return nil, err
+	}
+
+	errs := map[string]error{}
+
+	for _, name := range entry.Files {
+		src := filepath.Join(entry.filesDir(), filepath.FromSlash(name))
+		if _, err := os.Lstat(src); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			errs[name] = err
+			continue
+		}
+
+		if err := fs.Move(src, filepath.Join(dir, dataDir, filepath.FromSlash(name))); err != nil {
+			errs[name] = err
+			continue
+		}
+
+		removeEmptyParents(filepath.Dir(src), entry.filesDir())
+	}
+
+	return errs, nil
+}
+
+// List returns the entries sorted by deletion time, oldest first
+func (b *Bin) List() ([]Entry, error) {
+	dirs, err := os.ReadDir(b.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, errors.Wrapf(err, "could not read recycle bin: %s", b.Dir)
+	}
+
+	var entries []Entry
+
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+
+		entry, err := readEntry(filepath.Join(b.Dir, d.Name()))
+		if err != nil {
+			if os.IsNotExist(errors.Cause(err)) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].DeletedAt.Before(entries[j].DeletedAt)
+	})
+
+	return entries, nil
+}
+
+// Get returns the entry of the hash
+func (b *Bin) Get(hash string) (Entry, error) {
+	entry, err := readEntry(b.entryDir(hash))
+	if err != nil {
+		if os.IsNotExist(errors.Cause(err)) {
+			return Entry{}, ErrNotFound
+		}
+
+		return Entry{}, err
+	}
+
+	return entry, nil
+}
+
+// Restore moves the files of the entry back to the save path, or download path. Existing files are never overwritten.
+// The entry is kept so the torrent can be added again, remove it with Remove.
+func (b *Bin) Restore(hash string) (Entry, error) {
+	entry, err := b.Get(hash)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	dir := b.entryDir(hash)
+
+	for _, name := range entry.Files {
+		src := filepath.Join(dir, dataDir, filepath.FromSlash(name))
+		if _, err := os.Lstat(src); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return entry, err
+		}
+
+		dst := filepath.Join(entry.filesDir(), filepath.FromSlash(name))
+		if err := fs.Move(src, dst); err != nil {
+			return entry, errors.Wrapf(err, "could not restore %s", dst)
+		}
+	}
+
+	return entry, nil
+}
+
+// Remove deletes the entry with its files
+func (b *Bin) Remove(hash string) error {
+	dir := b.entryDir(hash)
+
+	if _, err := os.Stat(dir); err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+
+		return err
+	}
+
+	return os.RemoveAll(dir)
+}
+
+func writeEntry(dir string, entry Entry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "could not marshal entry")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, entryFile), data, 0644); err != nil {
+		return errors.Wrap(err, "could not write entry")
+	}
+
+	return nil
+}
+
+func readEntry(dir string) (Entry, error) {
+	data, err := os.ReadFile(filepath.Join(dir, entryFile))
+	if err != nil {
+		return Entry{}, errors.Wrapf(err, "could not read entry: %s", dir)
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return Entry{}, errors.Wrapf(err, "could not parse entry: %s", dir)
+	}
+
+	return entry, nil
+}
+
+// removeEmptyParents removes dir and its parents while they are empty, up to but not including root
+func removeEmptyParents(dir, root string) {
+	root = filepath.Clean(root)
+
+	for dir = filepath.Clean(dir); dir != root && strings.HasPrefix(dir, root+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package recycle
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testHash = "6957bf5272f5b994132458a557864e3ea747489f"
+
+func writeFiles(t *testing.T, root string, names ...string) {
+	t.Helper()
+
+	for _, name := range names {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func exists(p string) bool {
+	_, err := os.Stat(p)
+	return err == nil
+}
+
+func TestBin_AddRestore(t *testing.T) {
+	savePath := t.TempDir()
+	bin := New(filepath.Join(t.TempDir(), "recycle"))
+
+	writeFiles(t, savePath, "Movie/Movie.mkv", "Movie/Sample/sample.mkv", "Other/other.mkv")
+
+	entry := Entry{
+		Hash:     testHash,
+		Name:     "Movie",
+		Category: "movies",
+		Tags:     []string{"hd"},
+		SavePath: savePath,
+		// missing files are skipped
+		Files: []string{"Movie/Movie.mkv", "Movie/Sample/sample.mkv", "Movie/missing.nfo"},
+	}
+
+	errs, err := bin.Add(entry, []byte("torrent"))
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if len(errs) > 0 {
+		t.Fatalf("Add() file errors = %v", errs)
+	}
+
+	if exists(filepath.Join(savePath, "Movie")) {
+		t.Errorf("Add() did not remove the empty torrent folder")
+	}
+	if !exists(filepath.Join(savePath, "Other", "other.mkv")) {
+		t.Errorf("Add() moved files of other torrents")
+	}
+	if !exists(filepath.Join(bin.Dir, testHash, "data", "Movie", "Sample", "sample.mkv")) {
+		t.Errorf("Add() did not keep the structure")
+	}
+
+	if _, err := bin.Add(entry, nil); err == nil {
+		t.Errorf("Add() expected error for existing entry")
+	}
+
+	entries, err := bin.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].Hash != testHash || !entries[0].HasTorrent || !reflect.DeepEqual(entries[0].Tags, []string{"hd"}) {
+		t.Fatalf("List() = %+v", entries)
+	}
+
+	// existing files are never overwritten
+	writeFiles(t, savePath, "Movie/Movie.mkv")
+	if _, err := bin.Restore(testHash); err == nil {
+		t.Fatalf("Restore() expected error for existing file")
+	}
+	os.Remove(filepath.Join(savePath, "Movie", "Movie.mkv"))
+
+	if _, err := bin.Restore(testHash); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	for _, name := range []string{"Movie/Movie.mkv", "Movie/Sample/sample.mkv"} {
+		if !exists(filepath.Join(savePath, filepath.FromSlash(name))) {
+			t.Errorf("Restore() did not restore %s", name)
+		}
+	}
+
+	if err := bin.Remove(testHash); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if err := bin.Remove(testHash); err != ErrNotFound {
+		t.Errorf("Remove() error = %v, want %v", err, ErrNotFound)
+	}
+	if _, err := bin.Get(testHash); err != ErrNotFound {
+		t.Errorf("Get() error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestBin_AddRestore_downloadPath(t *testing.T) {
+	savePath := t.TempDir()
+	downloadPath := t.TempDir()
+	bin := New(filepath.Join(t.TempDir(), "recycle"))
+
+	writeFiles(t, downloadPath, "Movie/Movie.mkv")
+
+	entry := Entry{
+		Hash:         testHash,
+		Name:         "Movie",
+		SavePath:     savePath,
+		DownloadPath: downloadPath,
+		Files:        []string{"Movie/Movie.mkv"},
+	}
+
+	if _, err := bin.Add(entry, nil); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	if !exists(filepath.Join(bin.Dir, testHash, "data", "Movie", "Movie.mkv")) {
+		t.Fatalf("Add() did not move the files from the download path")
+	}
+
+	if _, err := bin.Restore(testHash); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	if !exists(filepath.Join(downloadPath, "Movie", "Movie.mkv")) || exists(filepath.Join(savePath, "Movie")) {
+		t.Errorf("Restore() did not restore to the download path")
+	}
+}
+
+func TestBin_List(t *testing.T) {
+	bin := New(filepath.Join(t.TempDir(), "recycle"))
+
+	entries, err := bin.List()
+	if err != nil || entries != nil {
+		t.Fatalf("List() of missing dir = %v, %v", entries, err)
+	}
+
+	now := time.Now()
+	hashes := []string{"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"}
+
+	if _, err := bin.Add(Entry{Hash: hashes[0], DeletedAt: now}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bin.Add(Entry{Hash: hashes[1], DeletedAt: now.Add(-time.Hour)}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// folders without an entry are skipped
+	if err := os.MkdirAll(filepath.Join(bin.Dir, "other"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err = bin.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Hash)
+	}
+
+	if want := []string{hashes[1], hashes[0]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("List() = %v, want %v", got, want)
+	}
+}
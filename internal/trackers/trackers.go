@@ -0,0 +1,48 @@
+package trackers
+
+import (
+	"context"
+
+	"github.com/ludviglundgren/qbittorrent-cli/internal/pool"
+
+	"github.com/autobrr/go-qbittorrent"
+)
+
+const DefaultConcurrency = 5
+
+// Client is the part of the qBittorrent client used to fetch trackers
+type Client interface {
+	GetTorrentTrackersCtx(ctx context.Context, hash string) ([]qbittorrent.TorrentTracker, error)
+}
+
+// Options holds the number of requests at the same time, the max requests per second and the progress callback
+type Options = pool.Options
+
+// Result is the trackers of a torrent, or the error getting them
+type Result struct {
+	Torrent  qbittorrent.Torrent
+	Trackers []qbittorrent.TorrentTracker
+	Err      error
+}
+
+// Fetch gets the trackers of the torrents with at most Concurrency requests at a time and returns the results
+// in the order of torrents. Errors of single torrents are set in their result, the ctx error is returned when canceled.
+func Fetch(ctx context.Context, client Client, torrents []qbittorrent.Torrent, opts Options) ([]Result, error) {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+
+	results := make([]Result, len(torrents))
+
+	err := pool.Run(ctx, len(torrents), opts, func(ctx context.Context, i int) {
+		t := torrents[i]
+
+		trackers, err := client.GetTorrentTrackersCtx(ctx, t.Hash)
+		results[i] = Result{Torrent: t, Trackers: trackers, Err: err}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
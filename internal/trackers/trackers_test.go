@@ -0,0 +1,123 @@
+package trackers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/autobrr/go-qbittorrent"
+)
+
+type fakeClient struct {
+	active    atomic.Int32
+	maxActive atomic.Int32
+	fail      string
+}
+
+func (c *fakeClient) GetTorrentTrackersCtx(ctx context.Context, hash string) ([]qbittorrent.TorrentTracker, error) {
+	n := c.active.Add(1)
+	defer c.active.Add(-1)
+
+	for {
+		m := c.maxActive.Load()
+		if n <= m || c.maxActive.CompareAndSwap(m, n) {
+			break
+		}
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	if hash == c.fail {
+		return nil, errors.New("failed")
+	}
+
+	return []qbittorrent.TorrentTracker{{Url: "https://" + hash + ".org/announce"}}, nil
+}
+
+func testTorrents(n int) []qbittorrent.Torrent {
+	torrents := make([]qbittorrent.Torrent, n)
+	for i := range torrents {
+		torrents[i] = qbittorrent.Torrent{Hash: strconv.Itoa(i)}
+	}
+
+	return torrents
+}
+
+func TestFetch(t *testing.T) {
+	client := &fakeClient{fail: "3"}
+	torrents := testTorrents(20)
+
+	var (
+		mu       sync.Mutex
+		progress []int
+	)
+
+	results, err := Fetch(context.Background(), client, torrents, Options{
+		Concurrency: 4,
+		OnProgress: func(done, total int) {
+			mu.Lock()
+			progress = append(progress, done)
+			mu.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	if got := client.maxActive.Load(); got > 4 {
+		t.Errorf("Fetch() max concurrent requests = %d, want <= 4", got)
+	}
+
+	for i, r := range results {
+		if r.Torrent.Hash != torrents[i].Hash {
+			t.Fatalf("Fetch() result %d is for %s", i, r.Torrent.Hash)
+		}
+
+		if i == 3 {
+			if r.Err == nil {
+				t.Errorf("Fetch() expected error for torrent 3")
+			}
+			continue
+		}
+
+		if r.Err != nil || len(r.Trackers) != 1 || r.Trackers[0].Url != "https://"+r.Torrent.Hash+".org/announce" {
+			t.Errorf("Fetch() result %d = %+v", i, r)
+		}
+	}
+
+	if len(progress) != 20 || progress[19] != 20 {
+		t.Errorf("Fetch() progress = %v", progress)
+	}
+}
+
+func TestFetch_rate(t *testing.T) {
+	start := time.Now()
+
+	if _, err := Fetch(context.Background(), &fakeClient{}, testTorrents(5), Options{Concurrency: 5, Rate: 100}); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	// 5 requests at 100 per second take at least 50ms
+	if elapsed := time.Since(start); elapsed < 45*time.Millisecond {
+		t.Errorf("Fetch() took %s, want rate limited", elapsed)
+	}
+}
+
+func TestFetch_highRate(t *testing.T) {
+	if _, err := Fetch(context.Background(), &fakeClient{}, testTorrents(5), Options{Concurrency: 5, Rate: 1e10}); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+}
+
+func TestFetch_canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := Fetch(ctx, &fakeClient{}, testTorrents(5), Options{Rate: 1}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Fetch() error = %v, want %v", err, context.Canceled)
+	}
+}
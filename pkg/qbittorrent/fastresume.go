@@ -3,6 +3,7 @@ package qbittorrent
 import (
 	"bufio"
 	"crypto/sha1"
+	"crypto/sha256"
 	"fmt"
 	"log"
 	"os"
@@ -34,6 +35,7 @@ type Fastresume struct {
 	FinishedTime              int64                  `bencode:"finished_time"`
 	HttpSeeds                 []string               `bencode:"httpseeds"`
 	InfoHash                  []byte                 `bencode:"info-hash"`
+	InfoHash2                 []byte                 `bencode:"info-hash2,omitempty"`
 	LastDownload              int64                  `bencode:"last_download"`
 	LastSeenComplete          int64                  `bencode:"last_seen_complete"`
 	LastUpload                int64                  `bencode:"last_upload"`
@@ -198,6 +200,43 @@ func (fr *Fastresume) GetInfoHashSHA1() (hash []byte) {
 	return ab
 }
 
+// GetInfoHashSHA256 returns the 32 byte v2 hash, or nil for v1-only torrents
+func (fr *Fastresume) GetInfoHashSHA256() (hash []byte) {
+	info, ok := fr.TorrentFile["info"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	if version, _ := info["meta version"].(int64); version != 2 {
+		return nil
+	}
+
+	torInfo, _ := bencode.EncodeString(info)
+	h := sha256.New()
+	_, _ = h.Write([]byte(torInfo))
+
+	return h.Sum(nil)
+}
+
+// HasV1 reports whether the torrent has v1 metadata. v2-only torrents have no pieces, files or length in info.
+func (fr *Fastresume) HasV1() bool {
+	info, ok := fr.TorrentFile["info"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	version, _ := info["meta version"].(int64)
+	if version != 2 {
+		return true
+	}
+
+	_, hasPieces := info["pieces"]
+	_, hasFiles := info["files"]
+	_, hasLength := info["length"]
+
+	return hasPieces || hasFiles || hasLength
+}
+
 //func (newstructure *NewTorrentStructure) GetTrackers(trackers interface{}) {
 //	switch strct := trackers.(type) {
 //	case []interface{}:
@@ -0,0 +1,219 @@
+package torrent
+
+import (
+	"bufio"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/zeebo/bencode"
+)
+
+// passkeyParams are the query parameters trackers commonly use for the personal passkey
+var passkeyParams = []string{"passkey", "authkey", "torrent_pass", "pk"}
+
+// passkeySegmentRegex matches a path segment that looks like a passkey, e.g. /<passkey>/announce
+var passkeySegmentRegex = regexp.MustCompile(`^[a-zA-Z0-9]{24,64}$`)
+
+// Trackers returns the announce urls of a raw decoded torrent grouped by tier.
+// The announce-list takes precedence over announce, as described in BEP 12.
+func Trackers(torrent map[string]interface{}) [][]string {
+	var tiers [][]string
+
+	if list, ok := torrent["announce-list"].([]interface{}); ok {
+		for _, rawTier := range list {
+			tierList, ok := rawTier.([]interface{})
+			if !ok {
+				continue
+			}
+
+			var tier []string
+			for _, rawURL := range tierList {
+				if u, ok := rawURL.(string); ok && u != "" {
+					tier = append(tier, u)
+				}
+			}
+
+			if len(tier) > 0 {
+				tiers = append(tiers, tier)
+			}
+		}
+	}
+
+	if len(tiers) == 0 {
+		if announce, ok := torrent["announce"].(string); ok && announce != "" {
+			tiers = append(tiers, []string{announce})
+		}
+	}
+
+	return tiers
+}
+
+// SetTrackers writes the tiers back into announce and announce-list.
+// Empty tiers are dropped and both keys are removed if no trackers are left.
+func SetTrackers(torrent map[string]interface{}, tiers [][]string) {
+	list := make([]interface{}, 0, len(tiers))
+
+	for _, tier := range tiers {
+		tierList := make([]interface{}, 0, len(tier))
+		for _, u := range tier {
+			if u != "" {
+				tierList = append(tierList, u)
+			}
+		}
+
+		if len(tierList) > 0 {
+			list = append(list, tierList)
+		}
+	}
+
+	if len(list) == 0 {
+		delete(torrent, "announce")
+		delete(torrent, "announce-list")
+		return
+	}
+
+	torrent["announce"] = list[0].([]interface{})[0]
+
+	// a single tracker doesn't need an announce-list
+	if len(list) == 1 && len(list[0].([]interface{})) == 1 {
+		delete(torrent, "announce-list")
+		return
+	}
+
+	torrent["announce-list"] = list
+}
+
+// SetInfoField sets a key in the info dict, or removes it when value is nil.
+// Note that any change to the info dict changes the info hash.
+func SetInfoField(torrent map[string]interface{}, key string, value interface{}) {
+	info, ok := torrent["info"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	if value == nil {
+		delete(info, key)
+		return
+	}
+
+	info[key] = value
+}
+
+// ReplacePasskey replaces the passkey in an announce url with passkey, or strips
+// it when passkey is empty. It reports whether a passkey was found.
+func ReplacePasskey(announce string, passkey string) (string, bool) {
+	u, err := url.Parse(announce)
+	if err != nil {
+		return announce, false
+	}
+
+	if replaced, ok := replaceQueryPasskey(announce, passkey); ok {
+		return replaced, true
+	}
+
+	found := false
+
+	segments := strings.Split(u.Path, "/")
+	kept := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		if passkeySegmentRegex.MatchString(segment) {
+			found = true
+
+			if passkey == "" {
+				continue
+			}
+
+			segment = passkey
+		}
+
+		kept = append(kept, segment)
+	}
+
+	if !found {
+		return announce, false
+	}
+
+	u.Path = strings.Join(kept, "/")
+	u.RawPath = ""
+
+	return u.String(), true
+}
+
+// replaceQueryPasskey replaces or strips the passkey query parameters of the announce url and keeps
+// the rest of the url as is. It reports whether a passkey parameter was found.
+func replaceQueryPasskey(announce string, passkey string) (string, bool) {
+	base, query, ok := strings.Cut(announce, "?")
+	if !ok {
+		return announce, false
+	}
+
+	query, fragment, hasFragment := strings.Cut(query, "#")
+
+	found := false
+
+	params := strings.Split(query, "&")
+	kept := make([]string, 0, len(params))
+
+	for _, param := range params {
+		key, _, _ := strings.Cut(param, "=")
+		if k, err := url.QueryUnescape(key); err == nil {
+			key = k
+		}
+
+		isPasskey := false
+		for _, p := range passkeyParams {
+			if key == p {
+				isPasskey = true
+				break
+			}
+		}
+
+		if !isPasskey {
+			kept = append(kept, param)
+			continue
+		}
+
+		found = true
+
+		if passkey != "" {
+			kept = append(kept, key+"="+url.QueryEscape(passkey))
+		}
+	}
+
+	if !found {
+		return announce, false
+	}
+
+	replaced := base
+	if len(kept) > 0 {
+		replaced += "?" + strings.Join(kept, "&")
+	}
+	if hasFragment {
+		replaced += "#" + fragment
+	}
+
+	return replaced, true
+}
+
+// EncodeRaw writes a raw decoded torrent to path
+func EncodeRaw(path string, torrent map[string]interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	bufferedWriter := bufio.NewWriter(file)
+	if err := bencode.NewEncoder(bufferedWriter).Encode(torrent); err != nil {
+		return err
+	}
+
+	return bufferedWriter.Flush()
+}
@@ -0,0 +1,78 @@
+package torrent
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReplacePasskey(t *testing.T) {
+	tests := []struct {
+		name      string
+		announce  string
+		passkey   string
+		want      string
+		wantFound bool
+	}{
+		{name: "path segment replace", announce: "https://tracker.example.org/0123456789abcdef0123456789abcdef/announce", passkey: "fedcba9876543210fedcba9876543210", want: "https://tracker.example.org/fedcba9876543210fedcba9876543210/announce", wantFound: true},
+		{name: "path segment strip", announce: "https://tracker.example.org/0123456789abcdef0123456789abcdef/announce", passkey: "", want: "https://tracker.example.org/announce", wantFound: true},
+		{name: "query param replace", announce: "https://tracker.example.org/announce.php?passkey=0123456789abcdef", passkey: "new", want: "https://tracker.example.org/announce.php?passkey=new", wantFound: true},
+		{name: "query param strip", announce: "https://tracker.example.org/announce.php?passkey=0123456789abcdef", passkey: "", want: "https://tracker.example.org/announce.php", wantFound: true},
+		{name: "query param keeps other params", announce: "https://tracker.example.org/announce.php?uid=1&passkey=0123456789abcdef&b=a%20b&a=1", passkey: "new", want: "https://tracker.example.org/announce.php?uid=1&passkey=new&b=a%20b&a=1", wantFound: true},
+		{name: "query param strip keeps other params", announce: "https://tracker.example.org/announce.php?uid=1&passkey=0123456789abcdef&a=1", passkey: "", want: "https://tracker.example.org/announce.php?uid=1&a=1", wantFound: true},
+		{name: "no passkey", announce: "udp://tracker.opentrackr.org:1337/announce", passkey: "new", want: "udp://tracker.opentrackr.org:1337/announce", wantFound: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := ReplacePasskey(tt.announce, tt.passkey)
+			if got != tt.want {
+				t.Errorf("ReplacePasskey() got = %v, want %v", got, tt.want)
+			}
+			if found != tt.wantFound {
+				t.Errorf("ReplacePasskey() found = %v, want %v", found, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestSetTrackers(t *testing.T) {
+	tests := []struct {
+		name  string
+		tiers [][]string
+		want  map[string]interface{}
+	}{
+		{
+			name:  "single tracker",
+			tiers: [][]string{{"https://a.example.org/announce"}},
+			want:  map[string]interface{}{"announce": "https://a.example.org/announce"},
+		},
+		{
+			name:  "multiple tiers",
+			tiers: [][]string{{"https://a.example.org/announce"}, {"https://b.example.org/announce"}},
+			want: map[string]interface{}{
+				"announce": "https://a.example.org/announce",
+				"announce-list": []interface{}{
+					[]interface{}{"https://a.example.org/announce"},
+					[]interface{}{"https://b.example.org/announce"},
+				},
+			},
+		},
+		{
+			name:  "empty tiers removed",
+			tiers: [][]string{{}, {""}},
+			want:  map[string]interface{}{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := map[string]interface{}{"announce": "old", "announce-list": []interface{}{}}
+			SetTrackers(got, tt.tiers)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SetTrackers() = %v, want %v", got, tt.want)
+			}
+
+			if len(tt.want) > 0 && !reflect.DeepEqual(Trackers(got), tt.tiers) {
+				t.Errorf("Trackers() = %v, want %v", Trackers(got), tt.tiers)
+			}
+		})
+	}
+}
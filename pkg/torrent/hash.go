@@ -0,0 +1,115 @@
+package torrent
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// InfoHashes holds the v1 (SHA-1) and v2 (SHA-256) info hashes of a torrent as lowercase hex.
+// V1 is empty for v2-only torrents and V2 is empty for v1-only torrents. Hybrid torrents have both.
+type InfoHashes struct {
+	V1 string
+	V2 string
+}
+
+// ID returns the id qBittorrent uses for the torrent in the API and BT_backup:
+// the v1 hash, or the v2 hash truncated to 40 characters for v2-only torrents.
+func (h InfoHashes) ID() string {
+	if h.V1 != "" {
+		return h.V1
+	}
+
+	return TruncateV2(h.V2)
+}
+
+// IsHybrid reports whether the torrent has both a v1 and a v2 info hash
+func (h InfoHashes) IsHybrid() bool {
+	return h.V1 != "" && h.V2 != ""
+}
+
+// Matches reports whether hash is the v1 hash, the v2 hash or the truncated v2 hash. Case-insensitive.
+func (h InfoHashes) Matches(hash string) bool {
+	if hash == "" {
+		return false
+	}
+
+	hash = strings.ToLower(hash)
+
+	return hash == h.V1 || hash == h.V2 || (h.V2 != "" && hash == TruncateV2(h.V2))
+}
+
+// TruncateV2 truncates a v2 hash to the 40 characters qBittorrent uses as id for v2-only torrents
+func TruncateV2(hash string) string {
+	if len(hash) > 40 {
+		return hash[:40]
+	}
+
+	return hash
+}
+
+// HashesFromInfoBytes calculates the info hashes from the bencoded info dict
+func HashesFromInfoBytes(infoBytes []byte) (InfoHashes, error) {
+	var info metainfo.Info
+	if err := bencode.Unmarshal(infoBytes, &info); err != nil {
+		return InfoHashes{}, err
+	}
+
+	var h InfoHashes
+
+	if info.HasV1() {
+		sum := sha1.Sum(infoBytes)
+		h.V1 = hex.EncodeToString(sum[:])
+	}
+
+	if info.HasV2() {
+		sum := sha256.Sum256(infoBytes)
+		h.V2 = hex.EncodeToString(sum[:])
+	}
+
+	return h, nil
+}
+
+// HashesFromMetaInfo calculates the info hashes of a torrent file
+func HashesFromMetaInfo(mi *metainfo.MetaInfo) (InfoHashes, error) {
+	return HashesFromInfoBytes(mi.InfoBytes)
+}
+
+// HashesFromFile calculates the info hashes of the torrent file at path
+func HashesFromFile(path string) (InfoHashes, error) {
+	mi, err := metainfo.LoadFromFile(path)
+	if err != nil {
+		return InfoHashes{}, err
+	}
+
+	return HashesFromMetaInfo(mi)
+}
+
+// HashesFromMagnet parses the btih (v1) and btmh (v2) info hashes from a magnet uri
+func HashesFromMagnet(uri string) (InfoHashes, error) {
+	m, err := metainfo.ParseMagnetV2Uri(uri)
+	if err != nil {
+		return InfoHashes{}, err
+	}
+
+	var h InfoHashes
+
+	if m.InfoHash.Ok {
+		h.V1 = m.InfoHash.Value.HexString()
+	}
+
+	if m.V2InfoHash.Ok {
+		h.V2 = m.V2InfoHash.Value.HexString()
+	}
+
+	if h.V1 == "" && h.V2 == "" {
+		return InfoHashes{}, errors.New("no info hash found in magnet uri")
+	}
+
+	return h, nil
+}
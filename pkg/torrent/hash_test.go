@@ -0,0 +1,133 @@
+package torrent
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/anacrolix/torrent/bencode"
+)
+
+func TestHashesFromInfoBytes(t *testing.T) {
+	fileTree := map[string]interface{}{
+		"a.txt": map[string]interface{}{
+			"": map[string]interface{}{"length": 5, "pieces root": strings.Repeat("r", 32)},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		info   map[string]interface{}
+		wantV1 bool
+		wantV2 bool
+	}{
+		{
+			name:   "v1",
+			info:   map[string]interface{}{"name": "a.txt", "length": 5, "piece length": 16384, "pieces": strings.Repeat("p", 20)},
+			wantV1: true,
+		},
+		{
+			name:   "v2",
+			info:   map[string]interface{}{"name": "a.txt", "piece length": 16384, "meta version": 2, "file tree": fileTree},
+			wantV2: true,
+		},
+		{
+			name:   "hybrid",
+			info:   map[string]interface{}{"name": "a.txt", "length": 5, "piece length": 16384, "pieces": strings.Repeat("p", 20), "meta version": 2, "file tree": fileTree},
+			wantV1: true,
+			wantV2: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			infoBytes, err := bencode.Marshal(tt.info)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := HashesFromInfoBytes(infoBytes)
+			if err != nil {
+				t.Fatalf("HashesFromInfoBytes() error = %v", err)
+			}
+
+			sum1 := sha1.Sum(infoBytes)
+			sum2 := sha256.Sum256(infoBytes)
+
+			want := InfoHashes{}
+			if tt.wantV1 {
+				want.V1 = hex.EncodeToString(sum1[:])
+			}
+			if tt.wantV2 {
+				want.V2 = hex.EncodeToString(sum2[:])
+			}
+
+			if got != want {
+				t.Errorf("HashesFromInfoBytes() = %v, want %v", got, want)
+			}
+
+			wantID := want.V1
+			if !tt.wantV1 {
+				wantID = want.V2[:40]
+			}
+			if got.ID() != wantID {
+				t.Errorf("ID() = %v, want %v", got.ID(), wantID)
+			}
+		})
+	}
+}
+
+func TestInfoHashes_Matches(t *testing.T) {
+	h := InfoHashes{
+		V1: "5ba4939a00a9b21629a0ad7d376898b768d997a3",
+		V2: "8d2a3b1f0ee2bd1b6d6f7c8e9a0b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b",
+	}
+
+	tests := []struct {
+		name string
+		hash string
+		want bool
+	}{
+		{name: "v1", hash: "5BA4939A00A9B21629A0AD7D376898B768D997A3", want: true},
+		{name: "v2", hash: h.V2, want: true},
+		{name: "truncated v2", hash: h.V2[:40], want: true},
+		{name: "other", hash: "3eced34cd948e7ea92f31ded3e0fd734274fee4a", want: false},
+		{name: "empty", hash: "", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := h.Matches(tt.hash); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashesFromMagnet(t *testing.T) {
+	v1 := "5ba4939a00a9b21629a0ad7d376898b768d997a3"
+	v2 := "8d2a3b1f0ee2bd1b6d6f7c8e9a0b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b"
+
+	tests := []struct {
+		name    string
+		uri     string
+		want    InfoHashes
+		wantErr bool
+	}{
+		{name: "v1", uri: "magnet:?xt=urn:btih:" + v1 + "&dn=test", want: InfoHashes{V1: v1}},
+		{name: "v2", uri: "magnet:?xt=urn:btmh:1220" + v2 + "&dn=test", want: InfoHashes{V2: v2}},
+		{name: "hybrid", uri: "magnet:?xt=urn:btih:" + v1 + "&xt=urn:btmh:1220" + v2, want: InfoHashes{V1: v1, V2: v2}},
+		{name: "no hash", uri: "magnet:?dn=test", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HashesFromMagnet(tt.uri)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("HashesFromMagnet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("HashesFromMagnet() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
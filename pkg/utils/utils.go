@@ -5,7 +5,9 @@ import (
 	"os/user"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
 var hashRegex = regexp.MustCompile("^[a-fA-F0-9]{40}$")
@@ -38,3 +40,29 @@ func ExpandTilde(path string) (string, error) {
 	}
 	return path, nil
 }
+
+// ParseDuration parses a duration like time.ParseDuration, with an optional leading number of days like 7d or 1d12h
+func ParseDuration(s string) (time.Duration, error) {
+	days, rest, found := strings.Cut(s, "d")
+	if !found {
+		return time.ParseDuration(s)
+	}
+
+	n, err := strconv.Atoi(days)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid duration: %s", s)
+	}
+
+	d := time.Duration(n) * 24 * time.Hour
+
+	if rest != "" {
+		r, err := time.ParseDuration(rest)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration: %s", s)
+		}
+
+		d += r
+	}
+
+	return d, nil
+}
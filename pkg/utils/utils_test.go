@@ -1,6 +1,9 @@
 package utils
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestValidateHash(t *testing.T) {
 	type args struct {
@@ -26,3 +29,30 @@ func TestValidateHash(t *testing.T) {
 		})
 	}
 }
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "hours", s: "36h", want: 36 * time.Hour},
+		{name: "days", s: "7d", want: 7 * 24 * time.Hour},
+		{name: "days and hours", s: "1d12h", want: 36 * time.Hour},
+		{name: "invalid days", s: "xd", wantErr: true},
+		{name: "invalid rest", s: "1dx", wantErr: true},
+		{name: "invalid", s: "week", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDuration(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDuration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}